@@ -0,0 +1,237 @@
+// Package main implements a tool that gets, sets, and watches topic configs, such as
+// bufstream.validate.mode.
+//
+// By default, the tool prints the effective Bufstream configs of every topic given with
+// --topic. Pass --set or --reset to change configs first, and --watch to keep printing
+// configs as they change.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"github.com/spf13/pflag"
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+// defaultPrefixes are the prefixes of the configs printed unless --all is given.
+var defaultPrefixes = []string{"bufstream.", "buf.registry."}
+
+var flags = struct {
+	topics        []string
+	validateMode  string
+	set           []string
+	reset         []string
+	all           bool
+	watch         bool
+	watchInterval time.Duration
+}{}
+
+func main() {
+	// See the app package for the boilerplate we use to set up the producer and
+	// consumer, including bound flags.
+	app.Main(run, app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringSliceVar(
+		&flags.topics,
+		"topics",
+		nil,
+		"Additional topics to get or set configs on, along with --topic.",
+	)
+	flagSet.StringVar(
+		&flags.validateMode,
+		"validate-mode",
+		"",
+		"The validation mode to set, such as reject or dlq. Shorthand for --set bufstream.validate.mode=<mode>.",
+	)
+	flagSet.StringArrayVar(
+		&flags.set,
+		"set",
+		nil,
+		"A name=value config to set, such as bufstream.validate.mode=reject. May be repeated.",
+	)
+	flagSet.StringArrayVar(
+		&flags.reset,
+		"reset",
+		nil,
+		"The name of a config to reset to its default. May be repeated.",
+	)
+	flagSet.BoolVar(
+		&flags.all,
+		"all",
+		false,
+		"If true, print every config instead of only bufstream.* and buf.registry.* configs.",
+	)
+	flagSet.BoolVar(
+		&flags.watch,
+		"watch",
+		false,
+		"If true, keep printing configs whenever they change.",
+	)
+	flagSet.DurationVar(
+		&flags.watchInterval,
+		"watch-interval",
+		5*time.Second,
+		"How often to check for config changes with --watch.",
+	)
+}
+
+func run(ctx context.Context, config app.Config) error {
+	var names []string
+	if config.Kafka.Topic != "" {
+		names = append(names, config.Kafka.Topic)
+	}
+	names = append(names, flags.topics...)
+	if len(names) == 0 {
+		return errors.New("--topic or --topics is required")
+	}
+	changes, err := parseChanges()
+	if err != nil {
+		return err
+	}
+
+	client, err := kafka.NewKafkaClient(config.Kafka, false)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admClient := kadm.NewClient(client)
+
+	if len(changes) > 0 {
+		if err := topics.AlterConfigs(ctx, admClient, changes, names...); err != nil {
+			return err
+		}
+	}
+
+	current, err := describe(ctx, admClient, names)
+	if err != nil {
+		return err
+	}
+	if err := printConfigs(current); err != nil {
+		return err
+	}
+	if !flags.watch {
+		return nil
+	}
+
+	ticker := time.NewTicker(flags.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		next, err := describe(ctx, admClient, names)
+		if err != nil {
+			return err
+		}
+		printChanges(current, next)
+		current = next
+	}
+}
+
+// parseChanges returns the configs to change from --set, --validate-mode, and --reset. Reset
+// configs have nil values.
+func parseChanges() (map[string]*string, error) {
+	changes := make(map[string]*string, len(flags.set)+len(flags.reset))
+	for _, set := range flags.set {
+		name, value, ok := strings.Cut(set, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --set %q: expected name=value", set)
+		}
+		changes[name] = &value
+	}
+	if flags.validateMode != "" {
+		changes[topics.ValidateModeConfig] = &flags.validateMode
+	}
+	for _, name := range flags.reset {
+		if _, ok := changes[name]; ok {
+			return nil, fmt.Errorf("config %s cannot be both set and reset", name)
+		}
+		changes[name] = nil
+	}
+	return changes, nil
+}
+
+// effectiveConfig is the effective value of a config, and where that value comes from.
+type effectiveConfig struct {
+	value  string
+	source string
+}
+
+// describe returns the effective configs to print, keyed by topic and then config name.
+func describe(ctx context.Context, admClient *kadm.Client, names []string) (map[string]map[string]effectiveConfig, error) {
+	described, err := topics.DescribeConfigs(ctx, admClient, names...)
+	if err != nil {
+		return nil, err
+	}
+	configs := make(map[string]map[string]effectiveConfig, len(described))
+	for topic, topicConfigs := range described {
+		configs[topic] = make(map[string]effectiveConfig)
+		for name, config := range topicConfigs {
+			if !flags.all && !hasAnyPrefix(name, defaultPrefixes) {
+				continue
+			}
+			configs[topic][name] = effectiveConfig{
+				value:  config.MaybeValue(),
+				source: config.Source.String(),
+			}
+		}
+	}
+	return configs, nil
+}
+
+func printConfigs(configs map[string]map[string]effectiveConfig) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TOPIC\tNAME\tVALUE\tSOURCE")
+	for _, topic := range slices.Sorted(maps.Keys(configs)) {
+		for _, name := range slices.Sorted(maps.Keys(configs[topic])) {
+			config := configs[topic][name]
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", topic, name, config.value, config.source)
+		}
+	}
+	return writer.Flush()
+}
+
+func printChanges(previous, current map[string]map[string]effectiveConfig) {
+	now := time.Now().Format(time.RFC3339)
+	for _, topic := range slices.Sorted(maps.Keys(current)) {
+		names := slices.Collect(maps.Keys(current[topic]))
+		for name := range previous[topic] {
+			if _, ok := current[topic][name]; !ok {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			before, hadBefore := previous[topic][name]
+			after, hasAfter := current[topic][name]
+			if hadBefore == hasAfter && before == after {
+				continue
+			}
+			fmt.Printf("%s %s %s: %q (%s) -> %q (%s)\n", now, topic, name, before.value, before.source, after.value, after.source)
+		}
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
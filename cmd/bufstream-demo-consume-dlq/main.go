@@ -1,7 +1,9 @@
 // Package main implements the consumer of the demo's DLQ.
 //
 // The consumer will read as many DLQ records it can at once, print what it
-// received, and then loop.
+// received, and then loop. Only the ID of each cart and what was wrong with it are printed,
+// never the cart itself, so that DLQ records can be shown to engineers who must not see
+// customer data.
 package main
 
 import (
@@ -9,34 +11,52 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	dlqv1beta1 "buf.build/gen/go/bufbuild/bufstream/protocolbuffers/go/buf/bufstream/dlq/v1beta1"
 	"buf.build/go/protovalidate"
 	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
 	"github.com/bufbuild/bufstream-demo/pkg/app"
 	"github.com/bufbuild/bufstream-demo/pkg/consume"
-	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/health"
 	"google.golang.org/protobuf/proto"
 )
 
+// maxPollWait is how long Consume waits for records before returning anyway.
+const maxPollWait = 5 * time.Second
+
 func main() {
 	// See the app package for the boilerplate we use to set up the producer and
 	// consumer, including bound flags.
-	app.Main(run)
+	app.Main(run, app.WithPositionFlags())
 }
 
 func run(ctx context.Context, config app.Config) error {
-	client, err := kafka.NewKafkaClient(config.Kafka, true)
+	// If --start is set, we replay the topic without joining the consumer group, so that the
+	// group's committed offsets are left untouched.
+	client, err := consume.NewKafkaClient(config.Kafka, config.Start)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
+	app.OnShutdown(ctx, "close client", func(context.Context) error {
+		client.Close()
+		return nil
+	})
 
 	consumer := consume.NewConsumer(
 		client,
 		config.Kafka.Topic,
 		consume.WithMessageHandler(handleDlqRecord),
+		consume.WithStartPosition[*dlqv1beta1.Record](config.Start),
+		consume.WithEndPosition[*dlqv1beta1.Record](config.End),
+		// Return regularly even if the topic is idle, so that the loop below keeps beating.
+		consume.WithMaxPollWait[*dlqv1beta1.Record](maxPollWait),
 	)
+	app.OnShutdown(ctx, "close consumer", consumer.Close)
+
+	health.RegisterClient(app.Health(ctx), client, config.Kafka.Topic)
+	heartbeat := health.NewHeartbeat(config.LivenessTimeout)
+	app.Health(ctx).Register(health.Liveness, "consume-loop", heartbeat)
 
 	slog.InfoContext(ctx, "starting consume")
 	for {
@@ -44,13 +64,19 @@ func run(ctx context.Context, config app.Config) error {
 		//
 		// Only return error if there is an unexpected system error. Of note, an error is not
 		// returned if the data that the consumer receives is malformed.
-		if err := consumer.Consume(ctx); err != nil {
+		err := consumer.Consume(ctx)
+		heartbeat.Beat()
+		if err != nil {
+			if errors.Is(err, consume.ErrEndReached) {
+				slog.InfoContext(ctx, "reached end position")
+				return nil
+			}
 			return err
 		}
 	}
 }
 
-func handleDlqRecord(_ context.Context, record *dlqv1beta1.Record) error {
+func handleDlqRecord(ctx context.Context, record *dlqv1beta1.Record) error {
 	// Reconstruct the original message: we expect a Cart in this toy example.
 	cart := &demov1.Cart{}
 	if err := proto.Unmarshal(record.GetValue(), cart); err != nil {
@@ -59,7 +85,12 @@ func handleDlqRecord(_ context.Context, record *dlqv1beta1.Record) error {
 
 	// Try to use Protovalidate to determine what was wrong with the cart.
 	if err := protovalidate.Validate(cart); err != nil {
-		slog.Info("DLQ received a cart that failed due to validation errors:", "ID", cart.GetCartId(), "error", err)
+		slog.InfoContext(
+			ctx,
+			"DLQ received a cart that failed due to validation errors:",
+			"ID", cart.GetCartId(),
+			"error", err,
+		)
 		return nil
 	}
 
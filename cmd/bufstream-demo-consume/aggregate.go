@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"buf.build/go/protovalidate"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/aggregate"
+	"github.com/bufbuild/bufstream-demo/pkg/consume"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// aggregator aggregates carts into CategoryRevenues, and produces them to the
+// --aggregate-topic once their windows are complete.
+//
+// Windows are emitted before the offsets of their carts are committed, so that no cart is
+// left uncounted by a crash, restart, or rebalance. The client does not autocommit: the loop
+// commits after emitting complete windows, and only up to the oldest cart that is still in an
+// open window, see consume.WithHeldOffsets. On shutdown and on revoke, every window is emitted
+// first. A window emitted before it is complete is emitted again with the carts that arrive
+// later, so readers of the --aggregate-topic sum the CategoryRevenues of the same window and
+// category.
+type aggregator struct {
+	// mu guards aggregator and held, which are used by both the consume loop and the revoke
+	// hook.
+	mu         sync.Mutex
+	windows    aggregate.Windows
+	aggregator *aggregate.Aggregator
+	producer   *produce.Producer[*demov1.CategoryRevenue]
+	// held holds the carts of each partition that are in windows yet to be emitted, in offset
+	// order.
+	held map[int32][]heldCart
+}
+
+// heldCart is a cart in windows that are yet to be emitted.
+type heldCart struct {
+	offset int64
+	// lastStart is the start of the cart's last window. The cart is released once it is
+	// emitted.
+	lastStart time.Time
+}
+
+// newAggregator provisions the --aggregate-topic, and returns a new aggregator that
+// produces to it.
+func newAggregator(ctx context.Context, client *kgo.Client) (*aggregator, error) {
+	windows := aggregate.Windows{
+		Size:            flags.windowSize,
+		Slide:           flags.windowSlide,
+		AllowedLateness: flags.allowedLateness,
+	}
+	if windows.Slide == 0 {
+		windows.Slide = windows.Size
+	}
+	agg, err := aggregate.NewAggregator(windows)
+	if err != nil {
+		return nil, err
+	}
+	admClient := kadm.NewClient(client)
+	plan, err := topics.NewPlan(ctx, admClient, []topics.Spec{aggregate.TopicSpec(flags.aggregateTopic)})
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.Apply(ctx, admClient, false); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(
+		ctx,
+		"aggregating category revenue",
+		"topic", flags.aggregateTopic,
+		"window_size", windows.Size,
+		"window_slide", windows.Slide,
+		"allowed_lateness", windows.AllowedLateness,
+	)
+	return &aggregator{
+		windows:    windows,
+		aggregator: agg,
+		producer:   produce.NewProducer[*demov1.CategoryRevenue](client, flags.aggregateTopic),
+		held:       make(map[int32][]heldCart),
+	}, nil
+}
+
+// consumerOptions returns the options that hold back the commit of carts in open windows, and
+// that emit every window before the offsets of revoked partitions are committed. Windows hold
+// the carts of every partition, so they are all emitted.
+func (a *aggregator) consumerOptions() []consume.ConsumerOption[*demov1.Cart] {
+	return []consume.ConsumerOption[*demov1.Cart]{
+		consume.WithHeldOffsets[*demov1.Cart](a.heldOffsets),
+		consume.WithOnPartitionsRevoked[*demov1.Cart](func(ctx context.Context, _ []int32) error {
+			return a.emitAll(ctx)
+		}),
+	}
+}
+
+// heldOffsets returns the offset of the oldest cart of each partition that is in a window yet
+// to be emitted.
+func (a *aggregator) heldOffsets() map[int32]int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	offsets := make(map[int32]int64, len(a.held))
+	for partition, carts := range a.held {
+		if len(carts) > 0 {
+			offsets[partition] = carts[0].offset
+		}
+	}
+	return offsets
+}
+
+// handleCart adds a cart to its windows, by the timestamp of its record. Invalid carts, such
+// as those with a zero-quantity line item, are skipped.
+func (a *aggregator) handleCart(ctx context.Context, cart *demov1.Cart) error {
+	record, ok := consume.RecordFromContext(ctx)
+	if !ok {
+		return errors.New("no record in context")
+	}
+	if err := protovalidate.Validate(cart); err != nil {
+		slog.WarnContext(ctx, "skipped invalid cart", "cart_id", cart.GetCartId(), "error", err)
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.aggregator.Add(record.Timestamp, cart); err != nil {
+		if errors.Is(err, aggregate.ErrLate) {
+			slog.WarnContext(
+				ctx,
+				"dropped late cart",
+				"cart_id", cart.GetCartId(),
+				"timestamp", record.Timestamp,
+				"watermark", a.aggregator.Watermark(),
+				"late", a.aggregator.Late(),
+			)
+			return nil
+		}
+		return err
+	}
+	a.held[record.Partition] = append(a.held[record.Partition], heldCart{
+		offset:    record.Offset,
+		lastStart: a.windows.LastStart(record.Timestamp),
+	})
+	return nil
+}
+
+// emitComplete produces the CategoryRevenues of every complete window, and returns true if
+// it released any carts, whose offsets can now be committed.
+func (a *aggregator) emitComplete(ctx context.Context) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	categoryRevenues := a.aggregator.Complete()
+	if len(categoryRevenues) == 0 {
+		return false, nil
+	}
+	if err := a.emit(ctx, categoryRevenues); err != nil {
+		return false, err
+	}
+	// Windows complete in order of their starts, so every window up to the last one emitted
+	// has been emitted.
+	lastEmitted := categoryRevenues[len(categoryRevenues)-1].GetWindowStart().AsTime()
+	for partition, carts := range a.held {
+		a.held[partition] = slices.DeleteFunc(carts, func(cart heldCart) bool {
+			return !cart.lastStart.After(lastEmitted)
+		})
+	}
+	return true, nil
+}
+
+// emitAll produces the CategoryRevenues of every window, complete or not, and releases every
+// cart.
+func (a *aggregator) emitAll(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.emit(ctx, a.aggregator.Flush()); err != nil {
+		return err
+	}
+	clear(a.held)
+	return nil
+}
+
+// emit produces CategoryRevenues, skipping any that are invalid. a.mu must be held.
+func (a *aggregator) emit(ctx context.Context, categoryRevenues []*demov1.CategoryRevenue) error {
+	for _, categoryRevenue := range categoryRevenues {
+		if err := protovalidate.Validate(categoryRevenue); err != nil {
+			slog.ErrorContext(
+				ctx,
+				"skipped invalid category revenue",
+				"window_start", categoryRevenue.GetWindowStart().AsTime(),
+				"category_id", categoryRevenue.GetCategoryId(),
+				"error", err,
+			)
+			continue
+		}
+		// Keyed by category, so that each category's windows stay in order.
+		if err := a.producer.ProduceProtobufMessage(ctx, categoryRevenue.GetCategoryId(), categoryRevenue); err != nil {
+			return err
+		}
+		slog.InfoContext(
+			ctx,
+			"emitted category revenue",
+			"window_start", categoryRevenue.GetWindowStart().AsTime(),
+			"category_id", categoryRevenue.GetCategoryId(),
+			"revenue_cents", categoryRevenue.GetRevenueCents(),
+			"units", categoryRevenue.GetUnits(),
+			"cart_count", categoryRevenue.GetCartCount(),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/consume"
+	"github.com/bufbuild/bufstream-demo/pkg/encryption"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// newFilterOptions returns the options that filter carts with --filter, and route the carts it
+// rejects to --rejected-topic, encrypted with envelope if it is not nil. It returns no options
+// if --filter is not set.
+func newFilterOptions(ctx context.Context, client *kgo.Client, envelope *encryption.Envelope) ([]consume.ConsumerOption[*demov1.Cart], error) {
+	if flags.filter == "" {
+		if flags.rejectedTopic != "" {
+			return nil, errors.New("--rejected-topic requires --filter")
+		}
+		return nil, nil
+	}
+	filter, err := consume.NewFilter[*demov1.Cart](flags.filter)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "filtering carts", "filter", filter.String(), "rejected_topic", flags.rejectedTopic)
+	app.OnShutdown(ctx, "report filtered carts", func(ctx context.Context) error {
+		stats := filter.Stats()
+		slog.InfoContext(ctx, "filtered carts", "matched", stats.Matched, "rejected", stats.Rejected, "failed", stats.Failed)
+		return nil
+	})
+	options := []consume.ConsumerOption[*demov1.Cart]{
+		consume.WithFilter(filter),
+	}
+	if flags.rejectedTopic != "" {
+		var producerOptions []produce.ProducerOption[*demov1.Cart]
+		if envelope != nil {
+			producerOptions = append(producerOptions, produce.WithEncryption[*demov1.Cart](envelope))
+		}
+		producer := produce.NewProducer(client, flags.rejectedTopic, producerOptions...)
+		options = append(options, consume.WithRejectedMessageHandler(func(ctx context.Context, cart *demov1.Cart) error {
+			record, ok := consume.RecordFromContext(ctx)
+			if !ok {
+				return errors.New("no record in context")
+			}
+			// Keep the key and headers, so that the rejected carts are partitioned as they were.
+			return producer.ProduceProtobufMessage(ctx, string(record.Key), cart, record.Headers...)
+		}))
+	}
+	return options, nil
+}
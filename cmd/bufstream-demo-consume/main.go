@@ -2,10 +2,25 @@
 //
 // The consumer will read as many records it can at once, print what it received,
 // and then loop.
+//
+// If --aggregate-topic is set, the consumer instead aggregates carts into the revenue,
+// units, and cart count of each category and product over windows of record time, and
+// produces a CategoryRevenue to that topic for each category once its window is
+// complete. See aggregate.go.
+//
+// If --state-topic is set, the consumer also keeps the units sold of each category in a state
+// store that is mirrored to that topic, so that the totals survive restarts. See state.go.
+//
+// If --filter is set, the consumer only handles carts that the given CEL expression is true
+// for, and produces the rest to --rejected-topic, if it is set. See filter.go.
+//
+// If --encryption-key-file is set, the consumer decrypts encrypted carts with the keys of that
+// file, and encrypts the carts it produces to --rejected-topic. See the encryption package.
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -13,29 +28,185 @@ import (
 	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
 	"github.com/bufbuild/bufstream-demo/pkg/app"
 	"github.com/bufbuild/bufstream-demo/pkg/consume"
-	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/encryption"
+	"github.com/bufbuild/bufstream-demo/pkg/health"
+	"github.com/bufbuild/bufstream-demo/pkg/state"
+	"github.com/spf13/pflag"
+	"github.com/twmb/franz-go/pkg/kgo"
 )
 
+var flags = struct {
+	aggregateTopic    string
+	windowSize        time.Duration
+	windowSlide       time.Duration
+	allowedLateness   time.Duration
+	stateTopic        string
+	balancer          string
+	filter            string
+	rejectedTopic     string
+	encryptionKeyFile string
+}{}
+
+// maxPollWait is how long Consume waits for records before returning anyway.
+const maxPollWait = 5 * time.Second
+
 func main() {
 	// See the app package for the boilerplate we use to set up the producer and
 	// consumer, including bound flags.
-	app.Main(run)
+	app.Main(run, app.WithPositionFlags(), app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&flags.aggregateTopic,
+		"aggregate-topic",
+		"",
+		"A topic to produce windowed CategoryRevenue aggregates to. If empty, carts are only counted.",
+	)
+	flagSet.DurationVar(
+		&flags.windowSize,
+		"window-size",
+		time.Minute,
+		"The length of each aggregation window.",
+	)
+	flagSet.DurationVar(
+		&flags.windowSlide,
+		"window-slide",
+		0,
+		"The time between the starts of hopping windows. If 0, windows are tumbling.",
+	)
+	flagSet.DurationVar(
+		&flags.allowedLateness,
+		"allowed-lateness",
+		10*time.Second,
+		"How long to wait for late records after a window ends before emitting it. Later records are dropped.",
+	)
+	flagSet.StringVar(
+		&flags.stateTopic,
+		"state-topic",
+		"",
+		"A compacted changelog topic to keep the units sold of each category in. If empty, no state is kept.",
+	)
+	flagSet.StringVar(
+		&flags.balancer,
+		"balancer",
+		string(consume.BalancerCooperativeSticky),
+		"How the consumer group assigns partitions: cooperative-sticky, sticky, range, or round-robin.",
+	)
+	flagSet.StringVar(
+		&flags.filter,
+		"filter",
+		"",
+		"A CEL expression over the cart, bound as this, that selects the carts to handle. If empty, every cart is handled.",
+	)
+	flagSet.StringVar(
+		&flags.rejectedTopic,
+		"rejected-topic",
+		"",
+		"A topic to produce the carts rejected by --filter to. If empty, rejected carts are skipped.",
+	)
+	flagSet.StringVar(
+		&flags.encryptionKeyFile,
+		"encryption-key-file",
+		"",
+		"A YAML file of keys to decrypt encrypted carts with. If empty, encrypted carts are malformed data.",
+	)
 }
 
 var cartsHandled = 0
 
 func run(ctx context.Context, config app.Config) error {
-	client, err := kafka.NewKafkaClient(config.Kafka, true)
+	balancer, err := consume.NewBalancer(consume.BalancerName(flags.balancer))
+	if err != nil {
+		return err
+	}
+	var stores *state.Stores
+	if flags.stateTopic != "" {
+		if flags.aggregateTopic != "" {
+			// Each mode handles carts its own way, and only one handler can run.
+			return errors.New("--state-topic cannot be used with --aggregate-topic")
+		}
+		if !config.Start.IsZero() {
+			// State is restored as partitions are assigned by the consumer group, which a replay
+			// does not join.
+			return errors.New("--state-topic cannot be used with --start")
+		}
+		stores, err = state.New(ctx, config.Kafka, flags.stateTopic)
+		if err != nil {
+			return err
+		}
+		app.OnShutdown(ctx, "close state stores", func(context.Context) error {
+			stores.Close()
+			return nil
+		})
+	}
+	// If --start is set, we replay the topic without joining the consumer group, so that the
+	// group's committed offsets are left untouched.
+	clientOpts := []kgo.Opt{kgo.Balancers(balancer)}
+	if flags.aggregateTopic != "" {
+		// Carts are held in windows long after they are handled, so their offsets are only
+		// committed once their windows are emitted. See aggregate.go.
+		clientOpts = append(clientOpts, kgo.DisableAutoCommit())
+	}
+	client, err := consume.NewKafkaClient(config.Kafka, config.Start, clientOpts...)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
+	app.OnShutdown(ctx, "close client", func(context.Context) error {
+		client.Close()
+		return nil
+	})
 
-	consumer := consume.NewConsumer(
-		client,
-		config.Kafka.Topic,
-		consume.WithMessageHandler(handleCart),
-	)
+	messageHandler := handleCart
+	var stateOptions []consume.ConsumerOption[*demov1.Cart]
+	if stores != nil {
+		messageHandler = state.Handler(stores, countUnits)
+		stateOptions = state.ConsumerOptions[*demov1.Cart](stores)
+	}
+	var agg *aggregator
+	var aggregateOptions []consume.ConsumerOption[*demov1.Cart]
+	if flags.aggregateTopic != "" {
+		agg, err = newAggregator(ctx, client)
+		if err != nil {
+			return err
+		}
+		messageHandler = agg.handleCart
+		aggregateOptions = agg.consumerOptions()
+	}
+	var envelope *encryption.Envelope
+	if flags.encryptionKeyFile != "" {
+		keyProvider, err := encryption.LoadLocalKeyFile(flags.encryptionKeyFile)
+		if err != nil {
+			return err
+		}
+		envelope = encryption.NewEnvelope(keyProvider)
+	}
+	filterOptions, err := newFilterOptions(ctx, client, envelope)
+	if err != nil {
+		return err
+	}
+
+	consumerOptions := []consume.ConsumerOption[*demov1.Cart]{
+		consume.WithMessageHandler(messageHandler),
+		consume.WithStartPosition[*demov1.Cart](config.Start),
+		consume.WithEndPosition[*demov1.Cart](config.End),
+		// Return regularly even if the topic is idle, so that the loop below keeps beating.
+		consume.WithMaxPollWait[*demov1.Cart](maxPollWait),
+	}
+	if envelope != nil {
+		consumerOptions = append(consumerOptions, consume.WithDecryption[*demov1.Cart](envelope))
+	}
+	consumerOptions = append(consumerOptions, stateOptions...)
+	consumerOptions = append(consumerOptions, aggregateOptions...)
+	consumerOptions = append(consumerOptions, filterOptions...)
+	consumer := consume.NewConsumer(client, config.Kafka.Topic, consumerOptions...)
+	// Once the loop below stops, commit what was handled and leave the group, so that the
+	// group's other consumers take over right away without handling any record twice.
+	app.OnShutdown(ctx, "close consumer", consumer.Close)
+
+	health.RegisterClient(app.Health(ctx), client, config.Kafka.Topic)
+	heartbeat := health.NewHeartbeat(config.LivenessTimeout)
+	app.Health(ctx).Register(health.Liveness, "consume-loop", heartbeat)
 
 	slog.InfoContext(ctx, "starting consume")
 	for {
@@ -43,23 +214,61 @@ func run(ctx context.Context, config app.Config) error {
 		//
 		// Only return error if there is an unexpected system error. Of note, an error is not
 		// returned if the data that the consumer receives is malformed.
-		if err := consumer.Consume(ctx); err != nil {
+		err := consumer.Consume(ctx)
+		heartbeat.Beat()
+		if err != nil {
+			if errors.Is(err, consume.ErrEndReached) {
+				slog.InfoContext(ctx, "reached end position")
+				// No more records are coming, so there is no point in waiting for windows to
+				// complete.
+				return emitOpenWindows(ctx, agg)
+			}
+			if ctx.Err() != nil {
+				return errors.Join(err, emitOpenWindows(ctx, agg))
+			}
 			return err
 		}
-		time.Sleep(time.Second)
+		if agg != nil {
+			// Emit the windows completed by the records that were just handled, and commit the
+			// carts they release, even if we are shutting down.
+			released, err := agg.emitComplete(context.WithoutCancel(ctx))
+			if err != nil {
+				return err
+			}
+			if released {
+				if err := consumer.Commit(context.WithoutCancel(ctx)); err != nil {
+					return err
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return emitOpenWindows(ctx, agg)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// emitOpenWindows emits every window of agg, if it is not nil, even if we are shutting down.
+// This must happen before the consumer is closed, as closing commits the offsets of the carts
+// in the windows.
+func emitOpenWindows(ctx context.Context, agg *aggregator) error {
+	if agg == nil {
+		return nil
 	}
+	return agg.emitAll(context.WithoutCancel(ctx))
 }
 
-func handleCart(_ context.Context, invoice *demov1.Cart) error {
+func handleCart(ctx context.Context, invoice *demov1.Cart) error {
 	for _, lineItem := range invoice.GetLineItems() {
 		if lineItem.GetQuantity() == 0 {
-			slog.Error("received a Cart with a zero-quantity LineItem")
+			slog.ErrorContext(ctx, "received a Cart with a zero-quantity LineItem")
 		}
 	}
 
 	cartsHandled++
 	if cartsHandled%250 == 0 {
-		slog.Info(fmt.Sprintf("received %d carts", cartsHandled))
+		slog.InfoContext(ctx, fmt.Sprintf("received %d carts", cartsHandled))
 	}
 
 	return nil
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/state"
+)
+
+// countUnits keeps the number of units sold of each category in the store of the cart's
+// partition, so that the totals survive restarts and rebalances.
+func countUnits(ctx context.Context, store *state.Store, cart *demov1.Cart) error {
+	units := make(map[string]uint64)
+	for _, lineItem := range cart.GetLineItems() {
+		units[lineItem.GetProduct().GetCategory().GetId()] += lineItem.GetQuantity()
+	}
+	totals := make(map[string][]byte, len(units))
+	for categoryID, categoryUnits := range units {
+		var total uint64
+		if value, ok := store.Get(categoryID); ok {
+			var err error
+			total, err = decodeUnits(categoryID, value)
+			if err != nil {
+				return err
+			}
+		}
+		totals[categoryID] = binary.BigEndian.AppendUint64(nil, total+categoryUnits)
+	}
+	// Every category of the cart is updated with one write to the changelog.
+	if err := store.PutAll(ctx, totals); err != nil {
+		return err
+	}
+	if err := handleCart(ctx, cart); err != nil {
+		return err
+	}
+	if cartsHandled%250 == 0 {
+		for categoryID, value := range store.All() {
+			units, err := decodeUnits(categoryID, value)
+			if err != nil {
+				return err
+			}
+			slog.InfoContext(
+				ctx,
+				"units sold",
+				"partition", store.Partition(),
+				"category_id", categoryID,
+				"units", units,
+			)
+		}
+	}
+	return nil
+}
+
+// decodeUnits decodes the units sold of a category, as kept by countUnits.
+//
+// The store is restored from whatever the --state-topic holds, which may not be a changelog
+// written by countUnits, so values are checked before they are decoded.
+func decodeUnits(categoryID string, value []byte) (uint64, error) {
+	if len(value) != 8 {
+		return 0, fmt.Errorf("invalid units sold of category %s: expected 8 bytes, got %d; is --state-topic a changelog of units sold?", categoryID, len(value))
+	}
+	return binary.BigEndian.Uint64(value), nil
+}
@@ -0,0 +1,193 @@
+// Package main implements a tool that exports a range of a topic's records to a file.
+//
+// The exported file preserves each record's key, value, headers, partition, and timestamp,
+// and can be replayed into another topic with bufstream-demo-import. This is useful for
+// backups, and for building test fixtures from real data.
+//
+// If --redact is set, the sensitive fields of each record's value are redacted before it is
+// exported, so that the file can be shared with engineers who must not see them. See
+// redact.go.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/archive"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"github.com/spf13/pflag"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var flags = struct {
+	output  string
+	format  string
+	redact  string
+	message string
+}{}
+
+func main() {
+	// See the app package for the boilerplate we use to set up the producer and
+	// consumer, including bound flags.
+	app.Main(run, app.WithPositionFlags(), app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&flags.output,
+		"output",
+		"-",
+		"The file to export records to. Use - for stdout.",
+	)
+	flagSet.StringVar(
+		&flags.format,
+		"format",
+		string(archive.FormatJSONL),
+		"The format of the exported file: jsonl or delimited.",
+	)
+	flagSet.StringVar(
+		&flags.redact,
+		"redact",
+		"",
+		"How to redact the sensitive fields of record values: mask, hash, or drop. If empty, values are exported as they are.",
+	)
+	flagSet.StringVar(
+		&flags.message,
+		"message",
+		"",
+		"The full name of the message of record values, to redact them as. Defaults to the topic's "+topics.ValueSchemaMessageConfig+" config.",
+	)
+}
+
+func run(ctx context.Context, config app.Config) error {
+	format, err := archive.ParseFormat(flags.format)
+	if err != nil {
+		return err
+	}
+	// By default, export the entire topic.
+	start := config.Start
+	if start.IsZero() {
+		start = kafka.PositionEarliest
+	}
+	end := config.End
+	if end.IsZero() {
+		end = kafka.PositionLatest
+	}
+
+	ranges, err := resolveRanges(ctx, config.Kafka, start, end)
+	if err != nil {
+		return err
+	}
+	redactor, err := newRedactor(ctx, config.Kafka)
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = os.Stdout
+	if flags.output != "-" {
+		file, err := os.Create(flags.output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+	archiveWriter := archive.NewWriter(writer, format)
+
+	exported, err := export(ctx, config.Kafka, ranges, redactor, archiveWriter)
+	if err != nil {
+		return err
+	}
+	if err := archiveWriter.Flush(); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "finished export", "topic", config.Kafka.Topic, "records", exported)
+	return nil
+}
+
+// resolveRanges resolves the start and end positions to the offset range to export from each
+// partition. Partitions without any records to export are omitted.
+func resolveRanges(ctx context.Context, config kafka.Config, start, end kafka.Position) (map[int32]kafka.OffsetRange, error) {
+	client, err := kafka.NewKafkaClient(config, false)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return kafka.ResolveRange(ctx, kadm.NewClient(client), config.Topic, start, end)
+}
+
+// export consumes the given offset ranges of the topic and writes every record to writer,
+// redacted by redactor if it is not nil.
+//
+// It returns once every range has been fully consumed.
+func export(
+	ctx context.Context,
+	config kafka.Config,
+	ranges map[int32]kafka.OffsetRange,
+	redactor *redactor,
+	writer *archive.Writer,
+) (int, error) {
+	if len(ranges) == 0 {
+		return 0, nil
+	}
+	partitions := make(map[int32]kgo.Offset, len(ranges))
+	for partition, offsetRange := range ranges {
+		partitions[partition] = kgo.NewOffset().At(offsetRange.Start)
+	}
+	client, err := kafka.NewKafkaClient(
+		config,
+		false,
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{config.Topic: partitions}),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		// Keep control records so that we can tell when we have reached the end of a range
+		// even if it ends with a transaction marker. They are not exported.
+		kgo.KeepControlRecords(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	var exported int
+	for len(ranges) > 0 {
+		fetches := client.PollFetches(ctx)
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return exported, fmt.Errorf("failed to fetch records: %v", errs)
+		}
+		for _, record := range fetches.Records() {
+			offsetRange, ok := ranges[record.Partition]
+			if !ok {
+				// The range is done, but records fetched before its partition was paused are
+				// still delivered.
+				continue
+			}
+			if record.Offset >= offsetRange.End {
+				// The last offsets of the range were never delivered, such as because they
+				// were compacted away, or belong to an aborted transaction.
+				delete(ranges, record.Partition)
+				client.PauseFetchPartitions(map[string][]int32{config.Topic: {record.Partition}})
+				continue
+			}
+			if !record.Attrs.IsControl() {
+				if redactor != nil {
+					record = redactor.redact(ctx, record)
+				}
+				if err := writer.Write(record); err != nil {
+					return exported, err
+				}
+				exported++
+			}
+			if record.Offset+1 >= offsetRange.End {
+				delete(ranges, record.Partition)
+				client.PauseFetchPartitions(map[string][]int32{config.Topic: {record.Partition}})
+			}
+		}
+	}
+	return exported, nil
+}
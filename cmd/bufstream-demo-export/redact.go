@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/redact"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// redactor redacts the sensitive fields of record values.
+type redactor struct {
+	mode        redact.Mode
+	messageType protoreflect.MessageType
+}
+
+// newRedactor returns a redactor for --redact and --message, or nil if --redact is not set.
+func newRedactor(ctx context.Context, config kafka.Config) (*redactor, error) {
+	if flags.redact == "" {
+		return nil, nil
+	}
+	mode, err := redact.ParseMode(flags.redact)
+	if err != nil {
+		return nil, err
+	}
+	messageName := flags.message
+	if messageName == "" {
+		messageName, err = describeValueMessage(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(messageName))
+	if err != nil {
+		return nil, fmt.Errorf("unknown message %s: %w", messageName, err)
+	}
+	slog.InfoContext(ctx, "redacting record values", "mode", mode, "message", messageName)
+	return &redactor{
+		mode:        mode,
+		messageType: messageType,
+	}, nil
+}
+
+// describeValueMessage returns the name of the message of the topic's record values, from the
+// topic's configs.
+func describeValueMessage(ctx context.Context, config kafka.Config) (string, error) {
+	client, err := kafka.NewKafkaClient(config, false)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	configs, err := topics.DescribeConfigs(ctx, kadm.NewClient(client), config.Topic)
+	if err != nil {
+		return "", err
+	}
+	value := configs[config.Topic][topics.ValueSchemaMessageConfig].Value
+	if value == nil || *value == "" {
+		return "", fmt.Errorf("topic %s has no %s config: set --message", config.Topic, topics.ValueSchemaMessageConfig)
+	}
+	return *value, nil
+}
+
+// redact returns a copy of the record with its value redacted.
+//
+// A value that cannot be parsed as the message might hold sensitive data that cannot be
+// found, so it is exported empty.
+func (r *redactor) redact(ctx context.Context, record *kgo.Record) *kgo.Record {
+	redacted := *record
+	message := r.messageType.New().Interface()
+	if err := proto.Unmarshal(record.Value, message); err != nil {
+		slog.WarnContext(
+			ctx,
+			"exporting a record with an empty value, as it could not be parsed to redact it",
+			"partition", record.Partition,
+			"offset", record.Offset,
+			"error", err,
+		)
+		redacted.Value = []byte{}
+		return &redacted
+	}
+	value, err := proto.Marshal(redact.Redact(message, r.mode))
+	if err != nil {
+		slog.WarnContext(
+			ctx,
+			"exporting a record with an empty value, as it could not be redacted",
+			"partition", record.Partition,
+			"offset", record.Offset,
+			"error", err,
+		)
+		value = []byte{}
+	}
+	redacted.Value = value
+	return &redacted
+}
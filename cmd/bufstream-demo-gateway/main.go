@@ -0,0 +1,107 @@
+// Package main implements an HTTP gateway that produces carts to Bufstream, for clients that
+// cannot speak the Kafka protocol, such as web frontends.
+//
+// The gateway serves CartService with Connect, so the same endpoint accepts the Connect
+// protocol, gRPC, and gRPC-Web. With the Connect protocol, a unary call is a plain HTTP POST
+// of JSON:
+//
+//	curl -H 'Content-Type: application/json' -d '{"cart": {...}}' \
+//	  localhost:8090/bufstream.demo.v1.CartService/SubmitCart
+//
+// Carts are validated with Protovalidate before they are produced, so invalid carts never
+// reach the topic. See service.go.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1/demov1connect"
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/health"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/spf13/pflag"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// produceTimeout is how long a submitted cart may take to be produced.
+const produceTimeout = 30 * time.Second
+
+var flags = struct {
+	listenAddr string
+}{}
+
+func main() {
+	// See the app package for the boilerplate we use to set up the producer and
+	// consumer, including bound flags.
+	app.Main(run, app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&flags.listenAddr,
+		"listen-addr",
+		":8090",
+		"The address to serve CartService on.",
+	)
+}
+
+func run(ctx context.Context, config app.Config) error {
+	if config.Kafka.Topic == "" {
+		return errors.New("--topic is required")
+	}
+	client, err := kafka.NewKafkaClient(
+		config.Kafka,
+		false,
+		// Fail a submission rather than leave its caller waiting for as long as the broker is
+		// unreachable.
+		kgo.RecordDeliveryTimeout(produceTimeout),
+	)
+	if err != nil {
+		return err
+	}
+	app.OnShutdown(ctx, "close client", func(context.Context) error {
+		client.Close()
+		return nil
+	})
+	health.RegisterClient(app.Health(ctx), client, config.Kafka.Topic)
+
+	service := &cartService{
+		producer: produce.NewProducer[*demov1.Cart](client, config.Kafka.Topic),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(demov1connect.NewCartServiceHandler(service))
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Serve gRPC without TLS, as well as HTTP/1.1 for the Connect protocol and gRPC-Web.
+		Protocols: new(http.Protocols),
+	}
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetUnencryptedHTTP2(true)
+	listener, err := net.Listen("tcp", flags.listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on --listen-addr %s: %w", flags.listenAddr, err)
+	}
+	// Stop accepting carts, and wait for those being produced, before the client is closed.
+	app.OnShutdown(ctx, "stop server", server.Shutdown)
+
+	slog.InfoContext(ctx, "serving CartService", "addr", listener.Addr().String(), "topic", config.Kafka.Topic)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- server.Serve(listener)
+	}()
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errC:
+		return err
+	}
+}
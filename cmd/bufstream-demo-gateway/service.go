@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"buf.build/go/protovalidate"
+	"connectrpc.com/connect"
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1/demov1connect"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+)
+
+// cartService implements CartService by producing carts to a topic.
+type cartService struct {
+	demov1connect.UnimplementedCartServiceHandler
+
+	producer *produce.Producer[*demov1.Cart]
+}
+
+// SubmitCart validates a cart, and produces it keyed by the request's key, or its cart_id.
+func (s *cartService) SubmitCart(
+	ctx context.Context,
+	request *connect.Request[demov1.SubmitCartRequest],
+) (*connect.Response[demov1.SubmitCartResponse], error) {
+	if err := validate(request.Msg); err != nil {
+		return nil, err
+	}
+	cart := request.Msg.GetCart()
+	key := request.Msg.GetKey()
+	if key == "" {
+		key = cart.GetCartId()
+	}
+	// The client may give up on the call, but once we start producing the cart, we see it
+	// through so that we never leave it unclear whether it was produced.
+	record, err := s.producer.ProduceProtobufMessageRecord(context.WithoutCancel(ctx), key, cart)
+	if err != nil {
+		slog.ErrorContext(ctx, "error producing submitted cart", "cart_id", cart.GetCartId(), "error", err)
+		return nil, connect.NewError(connect.CodeUnavailable, err)
+	}
+	return connect.NewResponse(&demov1.SubmitCartResponse{
+		Topic:     record.Topic,
+		Partition: record.Partition,
+		Offset:    record.Offset,
+	}), nil
+}
+
+// validate returns an INVALID_ARGUMENT error with the violations of the request as an error
+// detail, if it is invalid.
+//
+// Clients can read the detail as a buf.validate.Violations, whose field paths point at the
+// offending fields, such as cart.line_items[0].quantity.
+func validate(request *demov1.SubmitCartRequest) error {
+	err := protovalidate.Validate(request)
+	if err == nil {
+		return nil
+	}
+	var validationError *protovalidate.ValidationError
+	if !errors.As(err, &validationError) {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to validate cart: %w", err))
+	}
+	connectErr := connect.NewError(connect.CodeInvalidArgument, validationError)
+	if detail, err := connect.NewErrorDetail(validationError.ToProto()); err == nil {
+		connectErr.AddDetail(detail)
+	}
+	return connectErr
+}
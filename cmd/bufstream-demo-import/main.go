@@ -0,0 +1,130 @@
+// Package main implements a tool that imports records from a file written by
+// bufstream-demo-export into a topic.
+//
+// By default, every record is produced to the same partition it was exported from, so the
+// destination topic must have at least as many partitions as the source topic.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/archive"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/spf13/pflag"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var flags = struct {
+	input              string
+	format             string
+	preservePartitions bool
+}{}
+
+func main() {
+	// See the app package for the boilerplate we use to set up the producer and
+	// consumer, including bound flags.
+	app.Main(run, app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&flags.input,
+		"input",
+		"-",
+		"The file to import records from. Use - for stdin.",
+	)
+	flagSet.StringVar(
+		&flags.format,
+		"format",
+		string(archive.FormatJSONL),
+		"The format of the imported file: jsonl or delimited.",
+	)
+	flagSet.BoolVar(
+		&flags.preservePartitions,
+		"preserve-partitions",
+		true,
+		"If true, records are produced to the partition they were exported from. Otherwise, records are partitioned by key.",
+	)
+}
+
+func run(ctx context.Context, config app.Config) error {
+	format, err := archive.ParseFormat(flags.format)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader = os.Stdin
+	if flags.input != "-" {
+		file, err := os.Open(flags.input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+	archiveReader := archive.NewReader(reader, format)
+
+	var opts []kgo.Opt
+	if flags.preservePartitions {
+		opts = append(opts, kgo.RecordPartitioner(kgo.ManualPartitioner()))
+	}
+	client, err := kafka.NewKafkaClient(config.Kafka, false, opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var (
+		lock     sync.Mutex
+		imported int
+		firstErr error
+	)
+	var read int
+	var readErr error
+	for {
+		record, err := archiveReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// The records read so far are still flushed, so that it is clear how many of them
+			// were imported.
+			readErr = fmt.Errorf("failed to read record %d: %w", read+1, err)
+			break
+		}
+		read++
+		recordNumber := read
+		record.Topic = config.Kafka.Topic
+		client.Produce(ctx, record, func(_ *kgo.Record, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to produce record %d: %w", recordNumber, err)
+				}
+				return
+			}
+			imported++
+			if imported%250 == 0 {
+				slog.InfoContext(ctx, fmt.Sprintf("imported %d records", imported))
+			}
+		})
+	}
+	flushErr := client.Flush(ctx)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if err := errors.Join(readErr, firstErr, flushErr); err != nil {
+		slog.ErrorContext(ctx, "stopped import", "topic", config.Kafka.Topic, "records", imported, "read", read)
+		return err
+	}
+	slog.InfoContext(ctx, "finished import", "topic", config.Kafka.Topic, "records", imported)
+	return nil
+}
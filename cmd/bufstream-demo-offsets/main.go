@@ -0,0 +1,304 @@
+// Package main implements a tool that resets, shifts, or sets the committed offsets of a
+// consumer group on a topic.
+//
+// Exactly one of --to-earliest, --to-latest, --to-datetime, --by-delta, or --from-file must
+// be given. By default, the tool only prints the current and new offset of every partition.
+// Pass --execute to commit the new offsets. The tool refuses to commit while the group has
+// active members, since they would immediately overwrite the new offsets.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/spf13/pflag"
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+var flags = struct {
+	toEarliest bool
+	toLatest   bool
+	toDatetime string
+	byDelta    int64
+	fromFile   string
+	execute    bool
+}{}
+
+func main() {
+	// See the app package for the boilerplate we use to set up the producer and
+	// consumer, including bound flags.
+	app.Main(run, app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.BoolVar(
+		&flags.toEarliest,
+		"to-earliest",
+		false,
+		"Reset offsets to the first available offset of every partition.",
+	)
+	flagSet.BoolVar(
+		&flags.toLatest,
+		"to-latest",
+		false,
+		"Reset offsets to the end offset of every partition.",
+	)
+	flagSet.StringVar(
+		&flags.toDatetime,
+		"to-datetime",
+		"",
+		"Reset offsets to the first record at or after the given RFC 3339 timestamp.",
+	)
+	flagSet.Int64Var(
+		&flags.byDelta,
+		"by-delta",
+		0,
+		"Shift the committed offset of every partition by the given delta, which may be negative.",
+	)
+	flagSet.StringVar(
+		&flags.fromFile,
+		"from-file",
+		"",
+		"Set offsets from a file with one partition,offset pair per line.",
+	)
+	flagSet.BoolVar(
+		&flags.execute,
+		"execute",
+		false,
+		"If true, commit the new offsets. Otherwise, only print them.",
+	)
+}
+
+func run(ctx context.Context, config app.Config) error {
+	if config.Kafka.Topic == "" {
+		return errors.New("--topic is required")
+	}
+	if config.Kafka.Group == "" {
+		return errors.New("--group is required")
+	}
+	client, err := kafka.NewKafkaClient(config.Kafka, false)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admClient := kadm.NewClient(client)
+
+	currentOffsets, err := fetchCommittedOffsets(ctx, admClient, config.Kafka.Group, config.Kafka.Topic)
+	if err != nil {
+		return err
+	}
+	newOffsets, err := resolveNewOffsets(ctx, admClient, config.Kafka.Topic, currentOffsets)
+	if err != nil {
+		return err
+	}
+
+	activeMembers, err := countActiveMembers(ctx, admClient, config.Kafka.Group)
+	if err != nil {
+		return err
+	}
+	if err := printOffsets(currentOffsets, newOffsets); err != nil {
+		return err
+	}
+	if !flags.execute {
+		if activeMembers > 0 {
+			slog.WarnContext(ctx, "group has active members, offsets could not be committed", "group", config.Kafka.Group, "members", activeMembers)
+		}
+		slog.InfoContext(ctx, "dry run, pass --execute to commit the new offsets")
+		return nil
+	}
+	if activeMembers > 0 {
+		return fmt.Errorf("group %s has %d active members: stop them before changing offsets", config.Kafka.Group, activeMembers)
+	}
+
+	offsets := make(kadm.Offsets)
+	for partition, offset := range newOffsets {
+		offsets.Add(kadm.Offset{
+			Topic:       config.Kafka.Topic,
+			Partition:   partition,
+			At:          offset,
+			LeaderEpoch: -1,
+		})
+	}
+	responses, err := admClient.CommitOffsets(ctx, config.Kafka.Group, offsets)
+	if err == nil {
+		err = responses.Error()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	slog.InfoContext(ctx, "committed offsets", "group", config.Kafka.Group, "topic", config.Kafka.Topic, "partitions", len(newOffsets))
+	return nil
+}
+
+// fetchCommittedOffsets returns the group's committed offset for each partition of the topic,
+// or -1 for partitions without a committed offset.
+func fetchCommittedOffsets(ctx context.Context, admClient *kadm.Client, group string, topic string) (map[int32]int64, error) {
+	responses, err := admClient.FetchOffsetsForTopics(ctx, group, topic)
+	if err == nil {
+		err = responses.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %w", err)
+	}
+	offsets := make(map[int32]int64)
+	responses.Each(func(response kadm.OffsetResponse) {
+		if response.Topic == topic {
+			offsets[response.Partition] = response.At
+		}
+	})
+	return offsets, nil
+}
+
+// resolveNewOffsets returns the new offset for each partition to change, according to
+// the flags.
+func resolveNewOffsets(
+	ctx context.Context,
+	admClient *kadm.Client,
+	topic string,
+	currentOffsets map[int32]int64,
+) (map[int32]int64, error) {
+	var position kafka.Position
+	var modes int
+	if flags.toEarliest {
+		modes++
+		position = kafka.PositionEarliest
+	}
+	if flags.toLatest {
+		modes++
+		position = kafka.PositionLatest
+	}
+	if flags.toDatetime != "" {
+		modes++
+		t, err := time.Parse(time.RFC3339, flags.toDatetime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --to-datetime: %w", err)
+		}
+		position = kafka.PositionTime(t)
+	}
+	if flags.fromFile != "" {
+		modes++
+		offsets, err := readOffsetsFile(flags.fromFile)
+		if err != nil {
+			return nil, err
+		}
+		position = kafka.PositionPartitionOffsets(offsets)
+	}
+	if flags.byDelta != 0 {
+		modes++
+	}
+	if modes != 1 {
+		return nil, errors.New("exactly one of --to-earliest, --to-latest, --to-datetime, --by-delta, or --from-file is required")
+	}
+	if !position.IsZero() {
+		return kafka.ResolvePosition(ctx, admClient, topic, position)
+	}
+
+	// Shift each committed offset by the delta, staying within the partition's
+	// available offsets. Partitions without a committed offset are left alone.
+	startOffsets, err := kafka.ResolvePosition(ctx, admClient, topic, kafka.PositionEarliest)
+	if err != nil {
+		return nil, err
+	}
+	endOffsets, err := kafka.ResolvePosition(ctx, admClient, topic, kafka.PositionLatest)
+	if err != nil {
+		return nil, err
+	}
+	newOffsets := make(map[int32]int64, len(currentOffsets))
+	for partition, offset := range currentOffsets {
+		if offset < 0 {
+			continue
+		}
+		newOffsets[partition] = min(max(offset+flags.byDelta, startOffsets[partition]), endOffsets[partition])
+	}
+	return newOffsets, nil
+}
+
+// readOffsetsFile reads a file with one partition,offset pair per line. Empty lines and
+// lines starting with # are ignored.
+func readOffsetsFile(path string) (map[int32]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	offsets := make(map[int32]int64)
+	scanner := bufio.NewScanner(file)
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		partitionString, offsetString, ok := strings.Cut(line, ",")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected partition,offset", path, lineNumber)
+		}
+		partition, err := strconv.ParseInt(strings.TrimSpace(partitionString), 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("%s:%d: bad partition %q", path, lineNumber, partitionString)
+		}
+		offset, err := strconv.ParseInt(strings.TrimSpace(offsetString), 10, 64)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("%s:%d: bad offset %q", path, lineNumber, offsetString)
+		}
+		offsets[int32(partition)] = offset
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// countActiveMembers returns the number of members currently in the group.
+func countActiveMembers(ctx context.Context, admClient *kadm.Client, group string) (int, error) {
+	describedGroups, err := admClient.DescribeGroups(ctx, group)
+	if err == nil {
+		err = describedGroups.Error()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to describe group %s: %w", group, err)
+	}
+	return len(describedGroups[group].Members), nil
+}
+
+// printOffsets prints the current and new offset of every partition to stdout.
+func printOffsets(currentOffsets map[int32]int64, newOffsets map[int32]int64) error {
+	partitions := make([]int32, 0, len(currentOffsets))
+	for partition := range currentOffsets {
+		partitions = append(partitions, partition)
+	}
+	for partition := range newOffsets {
+		if _, ok := currentOffsets[partition]; !ok {
+			partitions = append(partitions, partition)
+		}
+	}
+	slices.Sort(partitions)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PARTITION\tCURRENT\tNEW")
+	for _, partition := range partitions {
+		current := "-"
+		if offset, ok := currentOffsets[partition]; ok && offset >= 0 {
+			current = strconv.FormatInt(offset, 10)
+		}
+		next := current
+		if offset, ok := newOffsets[partition]; ok {
+			next = strconv.FormatInt(offset, 10)
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\n", partition, current, next)
+	}
+	return writer.Flush()
+}
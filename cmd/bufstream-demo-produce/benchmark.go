@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// benchmarkCodec is a codec to benchmark, at a level that is 0 for the codec's default.
+type benchmarkCodec struct {
+	name  kafka.CompressionName
+	level int
+}
+
+// parseBenchmarkCodecs parses --benchmark-codecs, such as none,gzip:9,zstd.
+func parseBenchmarkCodecs(values []string) ([]benchmarkCodec, error) {
+	codecs := make([]benchmarkCodec, 0, len(values))
+	for _, value := range values {
+		name, levelString, hasLevel := strings.Cut(value, ":")
+		codec := benchmarkCodec{name: kafka.CompressionName(name)}
+		if hasLevel {
+			level, err := strconv.Atoi(levelString)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --benchmark-codecs %q: %w", value, err)
+			}
+			codec.level = level
+		}
+		// Catch invalid codecs before producing anything.
+		if _, err := kafka.CompressionOpts(codec.name, codec.level); err != nil {
+			return nil, fmt.Errorf("invalid --benchmark-codecs %q: %w", value, err)
+		}
+		codecs = append(codecs, codec)
+	}
+	return codecs, nil
+}
+
+func (c benchmarkCodec) String() string {
+	if c.level == 0 {
+		return string(c.name)
+	}
+	return fmt.Sprintf("%s:%d", c.name, c.level)
+}
+
+// benchmarkResult is what was measured while producing the workload with one codec.
+type benchmarkResult struct {
+	codec   benchmarkCodec
+	records int
+	elapsed time.Duration
+	// cpu is the CPU time that the process spent running Go code, including garbage
+	// collection. Producing the workload costs the same for every codec, so differences
+	// between codecs are down to compression.
+	cpu time.Duration
+	// uncompressedBytes and compressedBytes are the sizes of the records of every produced
+	// batch, before and after compression. compressedBytes is what the broker stores.
+	uncompressedBytes int64
+	compressedBytes   int64
+	// wireBytes is the size of every produce request written to the brokers, including
+	// request and batch overhead.
+	wireBytes int64
+}
+
+// benchmarkHooks counts the bytes produced by a client.
+type benchmarkHooks struct {
+	uncompressedBytes atomic.Int64
+	compressedBytes   atomic.Int64
+	wireBytes         atomic.Int64
+}
+
+func (h *benchmarkHooks) OnProduceBatchWritten(_ kgo.BrokerMetadata, _ string, _ int32, metrics kgo.ProduceBatchMetrics) {
+	h.uncompressedBytes.Add(int64(metrics.UncompressedBytes))
+	h.compressedBytes.Add(int64(metrics.CompressedBytes))
+}
+
+func (h *benchmarkHooks) OnBrokerWrite(_ kgo.BrokerMetadata, key int16, bytesWritten int, _, _ time.Duration, _ error) {
+	if key == (&kmsg.ProduceRequest{}).Key() {
+		h.wireBytes.Add(int64(bytesWritten))
+	}
+}
+
+// benchmark produces the same random carts once with every codec, and prints the throughput,
+// bytes, and CPU time of each to stdout.
+//
+// Records are produced asynchronously, so that batches are as full as the client can make
+// them, as they would be for a busy producer. Only valid carts are produced, so that the
+// topic's validation mode cannot reject any of them.
+func benchmark(
+	ctx context.Context,
+	config app.Config,
+	producer *produce.Producer[*demov1.Cart],
+	codecs []benchmarkCodec,
+	extraOpts ...kgo.Opt,
+) error {
+	if flags.benchmarkRecords < 1 {
+		return fmt.Errorf("invalid --benchmark-records %d: must be at least 1", flags.benchmarkRecords)
+	}
+	// The strategy was validated by run.
+	keyFunc, _ := newKeyFunc(keyStrategy(flags.keyStrategy))
+	records := make([]*kgo.Record, flags.benchmarkRecords)
+	for i := range records {
+		cart := newValidCart()
+		record, err := producer.NewProtobufMessageRecord(ctx, keyFunc(cart), cart)
+		if err != nil {
+			return err
+		}
+		records[i] = record
+	}
+	slog.InfoContext(ctx, "starting benchmark", "codecs", len(codecs), "records", len(records))
+	results := make([]benchmarkResult, 0, len(codecs))
+	for _, codec := range codecs {
+		result, err := benchmarkCodecRun(ctx, config, codec, records, extraOpts)
+		if err != nil {
+			return fmt.Errorf("failed to benchmark %s: %w", codec, err)
+		}
+		slog.InfoContext(
+			ctx,
+			"benchmarked codec",
+			"codec", codec.String(),
+			"elapsed", result.elapsed,
+			"compressed_bytes", result.compressedBytes,
+		)
+		results = append(results, result)
+	}
+	return printBenchmarkResults(results)
+}
+
+// benchmarkCodecRun produces records with a new client that compresses with codec.
+func benchmarkCodecRun(
+	ctx context.Context,
+	config app.Config,
+	codec benchmarkCodec,
+	records []*kgo.Record,
+	extraOpts []kgo.Opt,
+) (benchmarkResult, error) {
+	hooks := &benchmarkHooks{}
+	config.Kafka.Compression = codec.name
+	config.Kafka.CompressionLevel = codec.level
+	client, err := kafka.NewKafkaClient(config.Kafka, false, append(extraOpts, kgo.WithHooks(hooks))...)
+	if err != nil {
+		return benchmarkResult{}, err
+	}
+	defer client.Close()
+	// Connect before starting the clock, so that it only measures producing.
+	if err := client.Ping(ctx); err != nil {
+		return benchmarkResult{}, err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errsMu   sync.Mutex
+		firstErr error
+	)
+	startCPU := cpuTime()
+	start := time.Now()
+	for _, template := range records {
+		// The client sets the partition, offset, and timestamp of the records it produces,
+		// so every run produces its own copies.
+		record := *template
+		wg.Add(1)
+		client.Produce(ctx, &record, func(_ *kgo.Record, err error) {
+			defer wg.Done()
+			if err != nil {
+				errsMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errsMu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	cpu := cpuTime() - startCPU
+	if firstErr != nil {
+		return benchmarkResult{}, firstErr
+	}
+	return benchmarkResult{
+		codec:             codec,
+		records:           len(records),
+		elapsed:           elapsed,
+		cpu:               cpu,
+		uncompressedBytes: hooks.uncompressedBytes.Load(),
+		compressedBytes:   hooks.compressedBytes.Load(),
+		wireBytes:         hooks.wireBytes.Load(),
+	}, nil
+}
+
+// cpuTime returns the CPU time that the process has spent running Go code, including garbage
+// collection.
+func cpuTime() time.Duration {
+	// The runtime only adds up CPU time when it collects garbage.
+	runtime.GC()
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/user:cpu-seconds"},
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+	}
+	metrics.Read(samples)
+	seconds := samples[0].Value.Float64() + samples[1].Value.Float64()
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func printBenchmarkResults(results []benchmarkResult) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "CODEC\tRECORDS/S\tUNCOMPRESSED MB/S\tUNCOMPRESSED BYTES\tCOMPRESSED BYTES\tRATIO\tWIRE BYTES\tCPU\tCPU/MB\t")
+	for _, result := range results {
+		seconds := result.elapsed.Seconds()
+		uncompressedMB := float64(result.uncompressedBytes) / 1e6
+		ratio := 0.0
+		if result.compressedBytes > 0 {
+			ratio = float64(result.uncompressedBytes) / float64(result.compressedBytes)
+		}
+		cpuPerMB := time.Duration(0)
+		if uncompressedMB > 0 {
+			cpuPerMB = time.Duration(float64(result.cpu) / uncompressedMB)
+		}
+		fmt.Fprintf(
+			writer,
+			"%s\t%.0f\t%.1f\t%d\t%d\t%.2f\t%d\t%s\t%s\t\n",
+			result.codec,
+			float64(result.records)/seconds,
+			uncompressedMB/seconds,
+			result.uncompressedBytes,
+			result.compressedBytes,
+			ratio,
+			result.wireBytes,
+			result.cpu.Round(time.Millisecond),
+			cpuPerMB.Round(time.Microsecond),
+		)
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/bufbuild/bufstream-demo/pkg/product"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// minUnitPriceCents and maxUnitPriceCents are the bounds of Product.unit_price_cents.
+	minUnitPriceCents = 1
+	maxUnitPriceCents = 10_000_000
+)
+
+// productSource is the set of products that random carts are filled from.
+type productSource interface {
+	// Len returns the number of products.
+	Len() int
+	// Random returns a randomly selected product, or nil if no product can be picked.
+	Random() *demov1.Product
+}
+
+// followCatalogTopic provisions the catalog topic, starts following it in the background,
+// and returns the LiveCatalog once it has loaded the topic's current products.
+//
+// If the topic is empty, or if publish is true, products are first published to the topic.
+func followCatalogTopic(
+	ctx context.Context,
+	config kafka.Config,
+	producer *produce.Producer[*demov1.Product],
+	products []*demov1.Product,
+	publish bool,
+) (*product.LiveCatalog, error) {
+	if err := provisionTopic(ctx, config, product.CatalogTopicSpec(config.Topic)); err != nil {
+		return nil, err
+	}
+	liveCatalog := product.NewLiveCatalog()
+	errC := make(chan error, 1)
+	go func() {
+		errC <- liveCatalog.Follow(ctx, config)
+	}()
+	select {
+	case <-liveCatalog.Ready():
+	case err := <-errC:
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to load catalog topic %s: %w", config.Topic, err)
+	}
+	go func() {
+		if err := <-errC; err != nil {
+			slog.ErrorContext(ctx, "stopped following catalog topic", "topic", config.Topic, "err", err)
+		}
+	}()
+
+	if publish || liveCatalog.Len() == 0 {
+		if err := product.Publish(ctx, producer, products); err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "published catalog", "topic", config.Topic, "products", len(products))
+		// Wait until the published products are visible, so carts can be filled from them.
+		for liveCatalog.Len() == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+	liveCatalog.Subscribe(func(change product.CatalogChange) {
+		switch {
+		case change.New == nil:
+			slog.InfoContext(ctx, "product removed from catalog", "product_id", change.ProductID)
+		case change.Old == nil:
+			slog.InfoContext(ctx, "product added to catalog", "product_id", change.ProductID)
+		case change.Old.GetUnitPriceCents() != change.New.GetUnitPriceCents():
+			slog.InfoContext(
+				ctx,
+				"product price changed",
+				"product_id", change.ProductID,
+				"old_unit_price_cents", change.Old.GetUnitPriceCents(),
+				"new_unit_price_cents", change.New.GetUnitPriceCents(),
+			)
+		default:
+			slog.InfoContext(ctx, "product changed", "product_id", change.ProductID)
+		}
+	})
+	slog.InfoContext(ctx, "following catalog topic", "topic", config.Topic, "products", liveCatalog.Len())
+	return liveCatalog, nil
+}
+
+// provisionTopic creates a topic that the producer writes to along with --topic, or updates
+// its configs to match the given Spec.
+func provisionTopic(ctx context.Context, config kafka.Config, spec topics.Spec) error {
+	client, err := kafka.NewKafkaClient(config, false)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admClient := kadm.NewClient(client)
+
+	plan, err := topics.NewPlan(ctx, admClient, []topics.Spec{spec})
+	if err != nil {
+		return err
+	}
+	return plan.Apply(ctx, admClient, false)
+}
+
+// changePrices changes the price of a random product by up to 10% every interval, until
+// ctx is done.
+//
+// Changes are published to the catalog topic, and reach liveCatalog, and therefore new
+// carts, once they are consumed back from it.
+func changePrices(
+	ctx context.Context,
+	producer *produce.Producer[*demov1.Product],
+	liveCatalog *product.LiveCatalog,
+	interval time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := liveCatalog.Random()
+		if current == nil {
+			continue
+		}
+		// Products in the LiveCatalog are shared, so change a copy.
+		changed := proto.Clone(current).(*demov1.Product)
+		factor := 0.9 + rand.Float64()*0.2
+		unitPriceCents := uint64(float64(current.GetUnitPriceCents()) * factor)
+		changed.UnitPriceCents = min(max(unitPriceCents, minUnitPriceCents), maxUnitPriceCents)
+		if err := producer.ProduceProtobufMessage(ctx, changed.GetProductId(), changed); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.ErrorContext(ctx, "error publishing price change", "product_id", changed.GetProductId(), "err", err)
+		}
+	}
+}
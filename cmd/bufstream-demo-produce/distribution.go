@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bufbuild/bufstream-demo/pkg/distribution"
+)
+
+// parsePopularity returns the Popularity given by the --zipf-exponent, --category-weight,
+// and --category-seasonality flags.
+func parsePopularity() (distribution.Popularity, error) {
+	popularity := distribution.Popularity{
+		ZipfExponent:        flags.zipfExponent,
+		CategoryWeights:     make(map[string]float64, len(flags.categoryWeights)),
+		CategorySeasonality: make(map[string]distribution.Curve, len(flags.categorySeasonality)),
+	}
+	for _, categoryWeight := range flags.categoryWeights {
+		categoryID, weightString, ok := strings.Cut(categoryWeight, "=")
+		if !ok || categoryID == "" {
+			return distribution.Popularity{}, fmt.Errorf("invalid --category-weight %q: expected category_id=weight", categoryWeight)
+		}
+		weight, err := strconv.ParseFloat(weightString, 64)
+		if err != nil {
+			return distribution.Popularity{}, fmt.Errorf("invalid --category-weight %q: %w", categoryWeight, err)
+		}
+		popularity.CategoryWeights[categoryID] = weight
+	}
+	for _, categorySeasonality := range flags.categorySeasonality {
+		categoryID, curveName, ok := strings.Cut(categorySeasonality, "=")
+		if !ok || categoryID == "" {
+			return distribution.Popularity{}, fmt.Errorf("invalid --category-seasonality %q: expected category_id=curve", categorySeasonality)
+		}
+		curve, err := distribution.ParseCurve(curveName)
+		if err != nil {
+			return distribution.Popularity{}, fmt.Errorf("invalid --category-seasonality %q: %w", categorySeasonality, err)
+		}
+		popularity.CategorySeasonality[categoryID] = curve
+	}
+	if err := popularity.Validate(); err != nil {
+		return distribution.Popularity{}, err
+	}
+	return popularity, nil
+}
+
+// parseQuantity returns the Quantity given by the --quantity-distribution, --quantity-mean,
+// and --quantity-max flags.
+func parseQuantity() (distribution.Quantity, error) {
+	quantity := distribution.Quantity{
+		Kind: distribution.QuantityKind(flags.quantityDistribution),
+		Mean: flags.quantityMean,
+		Max:  flags.quantityMax,
+	}
+	if err := quantity.Validate(); err != nil {
+		return distribution.Quantity{}, err
+	}
+	return quantity, nil
+}
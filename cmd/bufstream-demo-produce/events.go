@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// freeShippingCents is the subtotal at which shipping becomes free.
+	freeShippingCents = 5000
+	// shippingCents is the cost of shipping below freeShippingCents.
+	shippingCents = 599
+	// taxPercent is the tax rate applied to discounted subtotals.
+	taxPercent = 8
+)
+
+var (
+	carriers       = []string{"UPS", "FedEx", "DHL", "USPS"}
+	paymentMethods = []demov1.PaymentMethod{
+		demov1.PaymentMethod_PAYMENT_METHOD_CARD,
+		demov1.PaymentMethod_PAYMENT_METHOD_WALLET,
+		demov1.PaymentMethod_PAYMENT_METHOD_BANK_TRANSFER,
+	}
+	failureReasons = []string{"card declined", "insufficient funds", "payment timed out"}
+	refundReasons  = []string{"damaged in transit", "item not as described", "changed mind"}
+)
+
+// customers holds the *customerState of each simulated customer that has placed an order, by
+// index.
+var customers sync.Map
+
+// customerState is the state of a simulated customer.
+type customerState struct {
+	// mu is held while the customer's order events are produced, so that the events of their
+	// orders are never interleaved, and never produced before their registration.
+	mu sync.Mutex
+	// registered is true once the customer's customer_registered event has been produced.
+	registered bool
+}
+
+// orderEventsTopicSpec returns the Spec of the order events topic.
+func orderEventsTopicSpec(name string) topics.Spec {
+	schema := string((&demov1.OrderEvent{}).ProtoReflect().Descriptor().FullName())
+	return topics.Spec{
+		Name: name,
+		Configs: map[string]*string{
+			topics.ValueSchemaMessageConfig: &schema,
+		},
+	}
+}
+
+// produceOrderEvents produces the lifecycle events of the order for a valid cart, keyed by
+// the customer_id of the cart's customer, so that they stay in order, along with the
+// customer's registration and other orders.
+func produceOrderEvents(ctx context.Context, producer *produce.Producer[*demov1.OrderEvent], cart *demov1.Cart) error {
+	value, _ := customers.LoadOrStore(customerIndexForCart(cart), &customerState{})
+	customer := value.(*customerState)
+	customer.mu.Lock()
+	defer customer.mu.Unlock()
+	for _, event := range newOrderEvents(cart, time.Now(), !customer.registered) {
+		if err := producer.ProduceProtobufMessage(ctx, event.GetCustomerId(), event); err != nil {
+			// If the registration failed, the customer's next order registers them instead.
+			return err
+		}
+		if event.GetCustomerRegistered() != nil {
+			customer.registered = true
+		}
+	}
+	return nil
+}
+
+// newOrderEvents returns the lifecycle of the order for a valid cart, starting at the
+// given time:
+//
+//   - The customer registers, if register is true.
+//   - The customer checks out the cart.
+//   - A payment is captured, or fails about 3% of the time, which ends the order.
+//   - The order is shipped.
+//   - About 5% of orders are partially or fully refunded.
+func newOrderEvents(cart *demov1.Cart, now time.Time, register bool) []*demov1.OrderEvent {
+	customerIndex := customerIndexForCart(cart)
+	customerID := customerID(customerIndex)
+	var events []*demov1.OrderEvent
+	newEvent := func() *demov1.OrderEvent {
+		event := &demov1.OrderEvent{
+			EventId:    newID(),
+			OrderId:    cart.GetCartId(),
+			CustomerId: customerID,
+			OccurredAt: timestamppb.New(now.Add(time.Duration(len(events)) * time.Millisecond)),
+		}
+		events = append(events, event)
+		return event
+	}
+
+	if register {
+		newEvent().Event = &demov1.OrderEvent_CustomerRegistered{
+			CustomerRegistered: &demov1.Customer{
+				CustomerId: customerID,
+				Email:      fmt.Sprintf("customer%d@example.com", customerIndex),
+				Name:       fmt.Sprintf("Customer %d", customerIndex),
+			},
+		}
+	}
+
+	checkout := newCheckout(cart, customerID)
+	newEvent().Event = &demov1.OrderEvent_Checkout{Checkout: checkout}
+
+	payment := &demov1.Payment{
+		PaymentId:   newID(),
+		CheckoutId:  checkout.GetCheckoutId(),
+		AmountCents: checkout.GetTotalCents(),
+		Method:      paymentMethods[rand.IntN(len(paymentMethods))],
+		Status:      demov1.PaymentStatus_PAYMENT_STATUS_CAPTURED,
+	}
+	if rand.IntN(100) < 3 {
+		payment.Status = demov1.PaymentStatus_PAYMENT_STATUS_FAILED
+		payment.FailureReason = failureReasons[rand.IntN(len(failureReasons))]
+		newEvent().Event = &demov1.OrderEvent_Payment{Payment: payment}
+		return events
+	}
+	newEvent().Event = &demov1.OrderEvent_Payment{Payment: payment}
+
+	shipment := &demov1.Shipment{
+		ShipmentId:     newID(),
+		CheckoutId:     checkout.GetCheckoutId(),
+		Carrier:        carriers[rand.IntN(len(carriers))],
+		TrackingNumber: newTrackingNumber(),
+	}
+	shipmentEvent := newEvent()
+	shipment.ShippedAt = shipmentEvent.GetOccurredAt()
+	shipmentEvent.Event = &demov1.OrderEvent_Shipment{Shipment: shipment}
+
+	if rand.IntN(100) < 5 {
+		newEvent().Event = &demov1.OrderEvent_Refund{
+			Refund: &demov1.Refund{
+				RefundId:           newID(),
+				PaymentId:          payment.GetPaymentId(),
+				AmountCents:        rand.Uint64N(payment.GetAmountCents()) + 1,
+				PaymentAmountCents: payment.GetAmountCents(),
+				Reason:             refundReasons[rand.IntN(len(refundReasons))],
+			},
+		}
+	}
+	return events
+}
+
+// newCheckout returns the checkout of a cart, with about 10% of checkouts getting a 10%
+// discount.
+func newCheckout(cart *demov1.Cart, customerID string) *demov1.Checkout {
+	var subtotalCents uint64
+	for _, lineItem := range cart.GetLineItems() {
+		subtotalCents += lineItem.GetQuantity() * lineItem.GetUnitPriceCents()
+	}
+	var discountCents uint64
+	if rand.IntN(10) == 0 {
+		discountCents = subtotalCents / 10
+	}
+	var shipping uint64
+	if subtotalCents < freeShippingCents {
+		shipping = shippingCents
+	}
+	taxCents := (subtotalCents - discountCents) * taxPercent / 100
+	return &demov1.Checkout{
+		CheckoutId:    newID(),
+		CartId:        cart.GetCartId(),
+		CustomerId:    customerID,
+		SubtotalCents: subtotalCents,
+		DiscountCents: discountCents,
+		ShippingCents: shipping,
+		TaxCents:      taxCents,
+		TotalCents:    subtotalCents - discountCents + shipping + taxCents,
+	}
+}
+
+// customerIndexForCart returns the index of the simulated customer who owns a cart.
+//
+// The customer is derived from the cart_id, so that the order events of a cart and its
+// record key with --key-strategy customer-id agree on the customer.
+func customerIndexForCart(cart *demov1.Cart) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(cart.GetCartId()))
+	return int(hash.Sum32() % uint32(flags.customers))
+}
+
+// newTrackingNumber returns a random carrier tracking number.
+func newTrackingNumber() string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	var builder strings.Builder
+	for range 18 {
+		builder.WriteByte(alphabet[rand.IntN(len(alphabet))])
+	}
+	return builder.String()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/google/uuid"
+)
+
+// keyStrategy is how the record key of a random cart is chosen.
+type keyStrategy string
+
+const (
+	// keyStrategyRandom keys every record with a new UUID, so records spread evenly
+	// across partitions and no two records share a key.
+	keyStrategyRandom keyStrategy = "random"
+	// keyStrategyCartID keys every record with its cart_id.
+	keyStrategyCartID keyStrategy = "cart-id"
+	// keyStrategyCustomerID keys every record with the ID of the simulated customer who
+	// owns the cart, out of --customers customers, so each customer's carts stay in order on
+	// one partition.
+	keyStrategyCustomerID keyStrategy = "customer-id"
+	// keyStrategyCategory keys every record with the category_id of its first line item,
+	// so popular categories make hot partitions.
+	keyStrategyCategory keyStrategy = "category"
+	// keyStrategyNone produces records without a key, leaving the choice of partition
+	// entirely to the partitioner.
+	keyStrategyNone keyStrategy = "none"
+	// keyStrategySticky keys --sticky-key-records records in a row with the same random
+	// key, so bursts of records share a partition.
+	keyStrategySticky keyStrategy = "sticky"
+)
+
+// customerNamespace is the namespace of the simulated customers' IDs, so that the same
+// customer has the same ID across runs.
+var customerNamespace = uuid.MustParse("7c9b6c2e-2f4b-4d4e-9a3f-0d6e5b8a1c42")
+
+// newKeyFunc returns a function that returns the record key of each cart, according to
+// the given strategy.
+//
+// Sticky keys are tracked by the returned function, so each worker needs its own.
+func newKeyFunc(strategy keyStrategy) (func(*demov1.Cart) string, error) {
+	switch strategy {
+	case keyStrategyRandom:
+		return func(*demov1.Cart) string {
+			return newID()
+		}, nil
+	case keyStrategyCartID:
+		return func(cart *demov1.Cart) string {
+			return cart.GetCartId()
+		}, nil
+	case keyStrategyCustomerID:
+		return func(cart *demov1.Cart) string {
+			return customerID(customerIndexForCart(cart))
+		}, nil
+	case keyStrategyCategory:
+		return func(cart *demov1.Cart) string {
+			if len(cart.GetLineItems()) == 0 {
+				return ""
+			}
+			return cart.GetLineItems()[0].GetProduct().GetCategory().GetId()
+		}, nil
+	case keyStrategyNone:
+		return func(*demov1.Cart) string {
+			return ""
+		}, nil
+	case keyStrategySticky:
+		if flags.stickyKeyRecords < 1 {
+			return nil, fmt.Errorf("invalid --sticky-key-records %d: must be at least 1", flags.stickyKeyRecords)
+		}
+		var key string
+		var remaining int
+		return func(*demov1.Cart) string {
+			if remaining == 0 {
+				key = newID()
+				remaining = flags.stickyKeyRecords
+			}
+			remaining--
+			return key
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown key strategy %q", strategy)
+	}
+}
+
+// customerID returns the ID of the simulated customer with the given index.
+func customerID(index int) string {
+	return uuid.NewSHA1(customerNamespace, []byte(strconv.Itoa(index))).String()
+}
@@ -3,6 +3,33 @@
 // Produces example Cart messages. About 1% of messages produced are
 // intentionally semantically-invalid: they contain a line with a zero
 // quantity.
+//
+// If --catalog-topic is set, products are picked from a live view of a compacted catalog
+// topic instead of a fixed catalog, and --price-change-interval changes prices while
+// producing. See catalog.go.
+//
+// Products are picked uniformly and quantities range uniformly from 1 to 5 by default. Use
+// --zipf-exponent, --category-weight, and --category-seasonality to make some products
+// more popular than others, and the --quantity-* flags to change quantities. See
+// pkg/distribution.
+//
+// Each record is keyed with a new UUID by default. Use --key-strategy to key records by
+// cart, customer, or category instead, or not at all, and --partitioner to choose how keys
+// map to partitions. See keys.go.
+//
+// If --order-events-topic is set, the producer also sends the lifecycle of an order for
+// every valid cart to that topic: checkout, payment, shipment, and sometimes a refund. See
+// events.go.
+//
+// If --encryption-key-file is set, cart values are encrypted with data keys wrapped by the
+// keys of that file. See the encryption package.
+//
+// If --benchmark-codecs is set, the producer instead produces the same random carts once with
+// each of the given compression codecs, and reports the throughput, bytes, and CPU time of
+// each. See benchmark.go.
+//
+// If --replay-file is set, the producer instead replays Cart messages read
+// from a JSONL file. See replay.go for the file format.
 package main
 
 import (
@@ -10,44 +37,324 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"math/rand/v2"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
 	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/distribution"
+	"github.com/bufbuild/bufstream-demo/pkg/encryption"
+	"github.com/bufbuild/bufstream-demo/pkg/health"
 	"github.com/bufbuild/bufstream-demo/pkg/kafka"
 	"github.com/bufbuild/bufstream-demo/pkg/produce"
 	"github.com/bufbuild/bufstream-demo/pkg/product"
 	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var flags = struct {
+	replayFile           string
+	replayRate           float64
+	catalogFile          string
+	catalogTopic         string
+	priceChangeInterval  time.Duration
+	zipfExponent         float64
+	categoryWeights      []string
+	categorySeasonality  []string
+	quantityDistribution string
+	quantityMean         float64
+	quantityMax          uint64
+	keyStrategy          string
+	customers            int
+	stickyKeyRecords     int
+	partitioner          string
+	partition            int32
+	orderEventsTopic     string
+	encryptionKeyFile    string
+	benchmarkCodecs      []string
+	benchmarkRecords     int
+}{}
+
+var (
+	// catalog is the catalog that random carts are filled from. It defaults to the built-in
+	// catalog, and is replaced if --catalog-file or --catalog-topic is set.
+	catalog productSource = distribution.NewProductPicker(
+		func() []*demov1.Product { return product.Catalog },
+		distribution.Popularity{},
+	)
+	// quantity is the distribution of line item quantities.
+	quantity = distribution.Quantity{
+		Kind: distribution.QuantityUniform,
+		Max:  5,
+	}
 )
 
 func main() {
 	// See the app package for the boilerplate we use to set up the producer and
 	// consumer, including bound flags.
-	app.MainAutoCreateTopic(run)
+	app.MainAutoCreateTopic(run, app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&flags.replayFile,
+		"replay-file",
+		"",
+		"A JSONL file of Cart messages to replay instead of producing random carts. Use - for stdin.",
+	)
+	flagSet.Float64Var(
+		&flags.replayRate,
+		"replay-rate",
+		0,
+		"The number of records per second to replay. If 0, records are replayed as fast as possible.",
+	)
+	flagSet.StringVar(
+		&flags.catalogFile,
+		"catalog-file",
+		"",
+		"A .json, .jsonl, .yaml, or .csv file of products to use instead of the built-in catalog.",
+	)
+	flagSet.StringVar(
+		&flags.catalogTopic,
+		"catalog-topic",
+		"",
+		"A compacted topic to follow the catalog from. It is created and filled from the catalog if it is empty, or if --catalog-file is set.",
+	)
+	flagSet.DurationVar(
+		&flags.priceChangeInterval,
+		"price-change-interval",
+		0,
+		"How often to change the price of a random product in --catalog-topic. If 0, prices are not changed.",
+	)
+	flagSet.Float64Var(
+		&flags.zipfExponent,
+		"zipf-exponent",
+		0,
+		"Skews popularity towards the first products of the catalog, following Zipf's law. If 0, products are equally popular. Try 1.1 for a realistic long tail.",
+	)
+	flagSet.StringArrayVar(
+		&flags.categoryWeights,
+		"category-weight",
+		nil,
+		"A category_id=weight relative popularity, such as electronics_accessories=3. Unlisted categories have a weight of 1. May be repeated.",
+	)
+	flagSet.StringArrayVar(
+		&flags.categorySeasonality,
+		"category-seasonality",
+		nil,
+		"A category_id=curve time-of-day popularity curve, where curve is one of flat, morning, midday, evening, or night. May be repeated.",
+	)
+	flagSet.StringVar(
+		&flags.quantityDistribution,
+		"quantity-distribution",
+		string(distribution.QuantityUniform),
+		"The distribution of line item quantities: uniform, geometric, poisson, or fixed.",
+	)
+	flagSet.Float64Var(
+		&flags.quantityMean,
+		"quantity-mean",
+		2,
+		"The mean line item quantity, for the geometric, poisson, and fixed distributions.",
+	)
+	flagSet.Uint64Var(
+		&flags.quantityMax,
+		"quantity-max",
+		5,
+		"The largest line item quantity.",
+	)
+	flagSet.StringVar(
+		&flags.keyStrategy,
+		"key-strategy",
+		string(keyStrategyRandom),
+		"How to key records: random, cart-id, customer-id, category, none, or sticky.",
+	)
+	flagSet.IntVar(
+		&flags.customers,
+		"customers",
+		1000,
+		"The number of simulated customers who own carts, for --key-strategy customer-id and --order-events-topic.",
+	)
+	flagSet.IntVar(
+		&flags.stickyKeyRecords,
+		"sticky-key-records",
+		100,
+		"The number of records in a row that share a key, with --key-strategy sticky.",
+	)
+	flagSet.StringVar(
+		&flags.partitioner,
+		"partitioner",
+		string(produce.PartitionerMurmur2),
+		"How to choose the partition of each cart: murmur2, round-robin, least-backup, or manual.",
+	)
+	flagSet.Int32Var(
+		&flags.partition,
+		"partition",
+		0,
+		"The partition to send every cart to, with --partitioner manual.",
+	)
+	flagSet.StringVar(
+		&flags.orderEventsTopic,
+		"order-events-topic",
+		"",
+		"A topic to send the OrderEvents of every valid cart to. If empty, no OrderEvents are sent.",
+	)
+	flagSet.StringVar(
+		&flags.encryptionKeyFile,
+		"encryption-key-file",
+		"",
+		"A YAML file of keys to encrypt cart values with. The topic must not have a value schema. If empty, carts are not encrypted.",
+	)
+	flagSet.StringSliceVar(
+		&flags.benchmarkCodecs,
+		"benchmark-codecs",
+		nil,
+		"Compression codecs to benchmark instead of producing continuously, each optionally with a level, such as none,gzip:6,snappy,lz4,zstd:4.",
+	)
+	flagSet.IntVar(
+		&flags.benchmarkRecords,
+		"benchmark-records",
+		100000,
+		"The number of carts to produce with each codec of --benchmark-codecs.",
+	)
 }
 
 func run(ctx context.Context, config app.Config) error {
-	client, err := kafka.NewKafkaClient(config.Kafka, false)
+	products := product.Catalog
+	if flags.catalogFile != "" {
+		loaded, err := product.LoadCatalog(flags.catalogFile)
+		if err != nil {
+			return err
+		}
+		products = loaded
+		slog.InfoContext(ctx, "loaded catalog", "file", flags.catalogFile, "products", len(products))
+	}
+	popularity, err := parsePopularity()
+	if err != nil {
+		return err
+	}
+	if err := popularity.ValidateFor(products); err != nil {
+		return fmt.Errorf("invalid --category-weight or --category-seasonality for the catalog: %w", err)
+	}
+	quantity, err = parseQuantity()
 	if err != nil {
 		return err
 	}
-	defer client.Close()
+	catalog = distribution.NewProductPicker(func() []*demov1.Product { return products }, popularity)
+	if flags.priceChangeInterval != 0 && flags.catalogTopic == "" {
+		return errors.New("--price-change-interval requires --catalog-topic")
+	}
+
+	if flags.customers < 1 {
+		return fmt.Errorf("invalid --customers %d: must be at least 1", flags.customers)
+	}
+	if _, err := newKeyFunc(keyStrategy(flags.keyStrategy)); err != nil {
+		return err
+	}
+	partitioner, err := produce.NewPartitioner(produce.PartitionerName(flags.partitioner))
+	if err != nil {
+		return err
+	}
+	if flags.partition != 0 && flags.partitioner != string(produce.PartitionerManual) {
+		return errors.New("--partition requires --partitioner manual")
+	}
+	benchmarkCodecs, err := parseBenchmarkCodecs(flags.benchmarkCodecs)
+	if err != nil {
+		return err
+	}
+	if len(benchmarkCodecs) > 0 && flags.replayFile != "" {
+		return errors.New("--benchmark-codecs cannot be used with --replay-file")
+	}
+	if flags.replayRate < 0 || math.IsNaN(flags.replayRate) || math.IsInf(flags.replayRate, 0) {
+		return fmt.Errorf("invalid --replay-rate %v: must be a finite number of at least 0", flags.replayRate)
+	}
 
-	producer := produce.NewProducer[*demov1.Cart](
-		client,
-		config.Kafka.Topic,
+	// The catalog is compacted by product, and order events are ordered per customer, so
+	// both keep the default partitioner. Only carts are partitioned with --partitioner.
+	client, err := kafka.NewKafkaClient(
+		config.Kafka,
+		false,
+		kgo.RecordPartitioner(produce.NewTopicPartitioner(config.Kafka.Topic, partitioner)),
 	)
+	if err != nil {
+		return err
+	}
+	// Records are produced synchronously, but the catalog and price changes may still be
+	// buffered when we stop.
+	app.OnShutdown(ctx, "flush producer", func(ctx context.Context) error {
+		defer client.Close()
+		return client.Flush(ctx)
+	})
+	health.RegisterClient(app.Health(ctx), client, config.Kafka.Topic)
+
+	producerOptions := []produce.ProducerOption[*demov1.Cart]{
+		produce.WithPartition[*demov1.Cart](flags.partition),
+	}
+	if flags.encryptionKeyFile != "" {
+		keyProvider, err := encryption.LoadLocalKeyFile(flags.encryptionKeyFile)
+		if err != nil {
+			return err
+		}
+		producerOptions = append(producerOptions, produce.WithEncryption[*demov1.Cart](encryption.NewEnvelope(keyProvider)))
+		slog.InfoContext(ctx, "encrypting carts", "encryption_key_file", flags.encryptionKeyFile)
+	}
+	producer := produce.NewProducer(client, config.Kafka.Topic, producerOptions...)
+
+	if flags.catalogTopic != "" {
+		catalogConfig := config.Kafka
+		catalogConfig.Topic = flags.catalogTopic
+		catalogProducer := produce.NewProducer[*demov1.Product](
+			client,
+			flags.catalogTopic,
+		)
+		liveCatalog, err := followCatalogTopic(ctx, catalogConfig, catalogProducer, products, flags.catalogFile != "")
+		if err != nil {
+			return err
+		}
+		picker := distribution.NewProductPicker(liveCatalog.Products, popularity)
+		liveCatalog.Subscribe(func(product.CatalogChange) { picker.Invalidate() })
+		catalog = picker
+		if flags.priceChangeInterval > 0 {
+			go changePrices(ctx, catalogProducer, liveCatalog, flags.priceChangeInterval)
+		}
+	}
+
+	var orderEventProducer *produce.Producer[*demov1.OrderEvent]
+	if flags.orderEventsTopic != "" {
+		orderEventsConfig := config.Kafka
+		orderEventsConfig.Topic = flags.orderEventsTopic
+		if err := provisionTopic(ctx, orderEventsConfig, orderEventsTopicSpec(flags.orderEventsTopic)); err != nil {
+			return err
+		}
+		orderEventProducer = produce.NewProducer[*demov1.OrderEvent](
+			client,
+			flags.orderEventsTopic,
+		)
+	}
+
+	if len(benchmarkCodecs) > 0 {
+		return benchmark(ctx, config, producer, benchmarkCodecs, kgo.RecordPartitioner(partitioner))
+	}
+	if flags.replayFile != "" {
+		return replay(ctx, producer, flags.replayFile, flags.replayRate)
+	}
 
-	slog.InfoContext(ctx, "starting produce")
+	slog.InfoContext(ctx, "starting produce", "key_strategy", flags.keyStrategy, "partitioner", flags.partitioner)
 
 	var wg sync.WaitGroup
 	numWorkers := 50
 	attemptCount := atomic.Int64{}
+	// Every worker beats after every cart, so the heartbeat only stops if they are all stuck.
+	heartbeat := health.NewHeartbeat(config.LivenessTimeout)
+	app.Health(ctx).Register(health.Liveness, "produce-loop", heartbeat)
 
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
+		// The strategy was validated above.
+		keyFunc, _ := newKeyFunc(keyStrategy(flags.keyStrategy))
 		go func() {
 			defer wg.Done()
 			for {
@@ -57,21 +364,30 @@ func run(ctx context.Context, config app.Config) error {
 				case <-ctx.Done():
 					return
 				default:
+					// Once a cart is started, it and its order events are produced in full,
+					// even if we are shutting down meanwhile.
+					produceCtx := context.WithoutCancel(ctx)
 					var inv *demov1.Cart
 					n := rand.IntN(100)
-					if n < 1 {
-						inv = newInvalidCart()
-					} else {
+					valid := n >= 1
+					if valid {
 						inv = newValidCart()
+					} else {
+						inv = newInvalidCart()
 					}
-					if err := producer.ProduceProtobufMessage(ctx, newID(), inv); err != nil {
-						if errors.Is(err, context.Canceled) {
-							return
-						}
+					if err := producer.ProduceProtobufMessage(produceCtx, keyFunc(inv), inv); err != nil {
 						slog.ErrorContext(ctx, "error producing message", "err", err)
+						continue
+					}
+					// Invalid carts never become orders.
+					if valid && orderEventProducer != nil {
+						if err := produceOrderEvents(produceCtx, orderEventProducer, inv); err != nil {
+							slog.ErrorContext(ctx, "error producing order events", "err", err)
+						}
 					}
 				}
 
+				heartbeat.Beat()
 				if nextAttempt > 0 && nextAttempt%250 == 0 {
 					slog.InfoContext(ctx, fmt.Sprintf("produced %d records", nextAttempt))
 				}
@@ -85,7 +401,7 @@ func run(ctx context.Context, config app.Config) error {
 
 // newID returns a new UUID.
 //
-// This is also used as the record key.
+// This is also used as the record key by default.
 func newID() string {
 	return uuid.New().String()
 }
@@ -119,15 +435,23 @@ func newCart(lineItems []*demov1.LineItem) *demov1.Cart {
 }
 
 func newRandomLineItems() []*demov1.LineItem {
-	maxItems := min(5, len(product.Catalog))
+	maxItems := min(5, catalog.Len())
+	if maxItems == 0 {
+		return nil
+	}
 	numItems := rand.IntN(maxItems) + 1
 	lineItems := make([]*demov1.LineItem, 0, numItems)
 
 	// Track product_ids to ensure uniqueness.
 	usedProductIDs := make(map[string]bool)
 
-	for len(lineItems) < numItems {
+	// Popular products are picked again and again, so give up after enough attempts rather
+	// than looping forever on a skewed catalog.
+	for attempts := 0; len(lineItems) < numItems && attempts < 100; attempts++ {
 		randomProduct := randomProduct()
+		if randomProduct == nil {
+			break
+		}
 
 		// Skip if we've already used this randomProduct.
 		if usedProductIDs[randomProduct.GetProductId()] {
@@ -139,7 +463,7 @@ func newRandomLineItems() []*demov1.LineItem {
 		lineItems = append(lineItems, &demov1.LineItem{
 			LineItemId:     uuid.New().String(),
 			Product:        randomProduct,
-			Quantity:       uint64(rand.IntN(5) + 1),
+			Quantity:       quantity.Sample(),
 			UnitPriceCents: randomProduct.GetUnitPriceCents(),
 		})
 	}
@@ -149,5 +473,5 @@ func newRandomLineItems() []*demov1.LineItem {
 
 // RandomProduct returns a randomly selected product from the catalog.
 func randomProduct() *demov1.Product {
-	return product.Catalog[rand.IntN(len(product.Catalog))]
+	return catalog.Random()
 }
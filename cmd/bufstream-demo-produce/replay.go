@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// maxReplayLineSize is the largest line we accept in a replay file.
+const maxReplayLineSize = 16 * 1024 * 1024
+
+// replayLine is a single line of a replay file that wraps a Cart with a record key
+// and headers.
+//
+// A line in a replay file is either a protojson-encoded Cart, such as:
+//
+//	{"cartId": "...", "lineItems": [...]}
+//
+// Or a replayLine, where value is a protojson-encoded Cart:
+//
+//	{"key": "...", "headers": {"name": "value"}, "value": {"cartId": "...", "lineItems": [...]}}
+//
+// If no key is given, a new random key is used, just as when producing random carts.
+type replayLine struct {
+	Key     *string           `json:"key"`
+	Headers map[string]string `json:"headers"`
+	Value   json.RawMessage   `json:"value"`
+}
+
+// replay produces every Cart in the replay file at path, or stdin if path is "-".
+//
+// If rate is positive, at most rate records are produced per second. Otherwise, records are
+// produced as fast as possible. Lines that cannot be parsed or produced are logged with their
+// line number and skipped; an error is returned at the end if any line failed.
+func replay(ctx context.Context, producer *produce.Producer[*demov1.Cart], path string, rate float64) error {
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		// Rates above a record per nanosecond are as fast as a ticker can go.
+		ticker := time.NewTicker(max(time.Duration(float64(time.Second)/rate), time.Nanosecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	slog.InfoContext(ctx, "starting replay", "file", path, "rate", rate)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, maxReplayLineSize)
+	var lineNumber, produced, failed int
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		key, headers, cart, err := parseReplayLine(line)
+		if err != nil {
+			failed++
+			slog.ErrorContext(ctx, "error parsing replay line", "line", lineNumber, "error", err)
+			continue
+		}
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-tick:
+			}
+		}
+		if err := producer.ProduceProtobufMessage(ctx, key, cart, headers...); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			failed++
+			slog.ErrorContext(ctx, "error producing replay line", "line", lineNumber, "error", err)
+			continue
+		}
+		produced++
+		if produced%250 == 0 {
+			slog.InfoContext(ctx, fmt.Sprintf("replayed %d records", produced))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read replay file after line %d: %w", lineNumber, err)
+	}
+
+	slog.InfoContext(ctx, "finished replay", "produced", produced, "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("failed to replay %d of %d records", failed, produced+failed)
+	}
+	return nil
+}
+
+func parseReplayLine(line []byte) (string, []kgo.RecordHeader, *demov1.Cart, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return "", nil, nil, err
+	}
+	key := newID()
+	var headers []kgo.RecordHeader
+	value := line
+	if _, ok := fields["value"]; ok {
+		var wrapped replayLine
+		if err := json.Unmarshal(line, &wrapped); err != nil {
+			return "", nil, nil, err
+		}
+		if wrapped.Key != nil {
+			key = *wrapped.Key
+		}
+		for _, name := range slices.Sorted(maps.Keys(wrapped.Headers)) {
+			headers = append(headers, kgo.RecordHeader{Key: name, Value: []byte(wrapped.Headers[name])})
+		}
+		value = wrapped.Value
+	}
+	cart := &demov1.Cart{}
+	if err := protojson.Unmarshal(value, cart); err != nil {
+		return "", nil, nil, fmt.Errorf("failed to unmarshal Cart: %w", err)
+	}
+	return key, headers, cart, nil
+}
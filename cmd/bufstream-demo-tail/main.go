@@ -0,0 +1,109 @@
+// Package main implements a tool that streams the carts of a topic to a browser as they are
+// produced, so that they can be watched without any Kafka tooling.
+//
+// The tool serves a page at / that shows the stream, and the stream itself at /tail as
+// server-sent events. Each record is sent as a "record" event holding its topic, partition,
+// offset, timestamp, key, and cart as JSON. The stream can also be read with curl:
+//
+//	curl -N 'localhost:8091/tail?start=earliest&partitions=0,1&filter=this.line_items.size()>2'
+//
+// Each stream reads the topic with its own client, without joining a consumer group, so
+// watching never moves a group's committed offsets. At most --max-streams are served at once.
+// See stream.go.
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/health"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/spf13/pflag"
+)
+
+var flags = struct {
+	listenAddr string
+	maxStreams int
+}{}
+
+//go:embed index.html
+var indexHTML []byte
+
+func main() {
+	// See the app package for the boilerplate we use to set up the producer and
+	// consumer, including bound flags.
+	app.Main(run, app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&flags.listenAddr,
+		"listen-addr",
+		":8091",
+		"The address to serve the tail page and stream on.",
+	)
+	flagSet.IntVar(
+		&flags.maxStreams,
+		"max-streams",
+		16,
+		"The maximum number of streams to serve at once. Each stream has its own Kafka client.",
+	)
+}
+
+func run(ctx context.Context, config app.Config) error {
+	if config.Kafka.Topic == "" {
+		return errors.New("--topic is required")
+	}
+	if flags.maxStreams < 1 {
+		return fmt.Errorf("invalid --max-streams %d: must be at least 1", flags.maxStreams)
+	}
+	// Streams have clients of their own. This one only checks that the topic can be read.
+	client, err := kafka.NewKafkaClient(config.Kafka, false)
+	if err != nil {
+		return err
+	}
+	app.OnShutdown(ctx, "close client", func(context.Context) error {
+		client.Close()
+		return nil
+	})
+	health.RegisterClient(app.Health(ctx), client, config.Kafka.Topic)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(indexHTML)
+	})
+	mux.Handle("GET /tail", newTailHandler(config.Kafka, flags.maxStreams))
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Streams never end on their own, so end them once the program starts shutting down.
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	listener, err := net.Listen("tcp", flags.listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on --listen-addr %s: %w", flags.listenAddr, err)
+	}
+	app.OnShutdown(ctx, "stop server", server.Shutdown)
+
+	slog.InfoContext(ctx, "serving tail", "addr", listener.Addr().String(), "topic", config.Kafka.Topic)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- server.Serve(listener)
+	}()
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errC:
+		return err
+	}
+}
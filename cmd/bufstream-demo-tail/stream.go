@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/consume"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/redact"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// keepAliveInterval is how often a comment is sent on an idle stream, so that proxies keep it
+// open and a stream whose reader went away is noticed.
+const keepAliveInterval = 15 * time.Second
+
+// tailHandler streams the carts of a topic as server-sent events.
+//
+// It accepts the following query parameters:
+//
+//   - start: where to start streaming from, in any form accepted by --start. Defaults to
+//     latest, so that only new carts are streamed.
+//   - end: where to stop streaming, in any form accepted by --end. The stream sends an "end"
+//     event and closes once it is reached. Defaults to streaming forever.
+//   - partitions: comma-separated partitions to stream, such as 0,2. Defaults to all.
+//   - filter: a CEL expression over the cart, bound as this, such as
+//     this.line_items.exists(li, li.quantity > 10). Only carts it is true for are streamed.
+//
+// Every stream has a Kafka client of its own, so at most --max-streams are served at once, and
+// further requests get 503 Service Unavailable until one ends.
+type tailHandler struct {
+	config kafka.Config
+	// streams holds a token for every stream being served.
+	streams chan struct{}
+}
+
+func newTailHandler(config kafka.Config, maxStreams int) *tailHandler {
+	return &tailHandler{
+		config:  config,
+		streams: make(chan struct{}, maxStreams),
+	}
+}
+
+// recordEvent is the data of a "record" event.
+type recordEvent struct {
+	Topic     string          `json:"topic"`
+	Partition int32           `json:"partition"`
+	Offset    int64           `json:"offset"`
+	Timestamp time.Time       `json:"timestamp"`
+	Key       string          `json:"key,omitempty"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// malformedEvent is the data of a "malformed" event, sent for records that are not carts.
+type malformedEvent struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Error     string `json:"error"`
+}
+
+func (h *tailHandler) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+	options, err := h.parseQuery(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	select {
+	case h.streams <- struct{}{}:
+		defer func() { <-h.streams }()
+	default:
+		slog.WarnContext(ctx, "rejected tail: too many streams", "remote_addr", request.RemoteAddr, "max_streams", cap(h.streams))
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "too many streams, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	client, err := consume.NewKafkaClient(h.config, options.start)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer client.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	stream := &eventStream{writer: w, controller: http.NewResponseController(w)}
+	consumerOptions := []consume.ConsumerOption[*demov1.Cart]{
+		consume.WithMessageHandler(func(ctx context.Context, cart *demov1.Cart) error {
+			record, _ := consume.RecordFromContext(ctx)
+			// Support staff watching the stream need not see sensitive fields.
+			value, err := protojson.Marshal(redact.Redact(cart, redact.ModeMask))
+			if err != nil {
+				return err
+			}
+			return stream.send("record", recordEvent{
+				Topic:     record.Topic,
+				Partition: record.Partition,
+				Offset:    record.Offset,
+				Timestamp: record.Timestamp,
+				Key:       string(record.Key),
+				Value:     value,
+			})
+		}),
+		consume.WithMalformedDataHandler[*demov1.Cart](func(ctx context.Context, _ []byte, err error) error {
+			record, _ := consume.RecordFromContext(ctx)
+			return stream.send("malformed", malformedEvent{
+				Topic:     record.Topic,
+				Partition: record.Partition,
+				Offset:    record.Offset,
+				Error:     err.Error(),
+			})
+		}),
+		consume.WithStartPosition[*demov1.Cart](options.start),
+		consume.WithEndPosition[*demov1.Cart](options.end),
+		consume.WithPartitions[*demov1.Cart](options.partitions...),
+		consume.WithMaxPollWait[*demov1.Cart](keepAliveInterval),
+	}
+	if options.filter != nil {
+		consumerOptions = append(consumerOptions, consume.WithFilter(options.filter))
+	}
+	consumer := consume.NewConsumer(client, h.config.Topic, consumerOptions...)
+
+	slog.InfoContext(ctx, "starting tail", "remote_addr", request.RemoteAddr, "query", request.URL.RawQuery)
+	defer slog.InfoContext(ctx, "stopped tail", "remote_addr", request.RemoteAddr)
+	for {
+		err := consumer.Consume(ctx)
+		switch {
+		case errors.Is(err, consume.ErrEndReached):
+			_ = stream.send("end", struct{}{})
+			return
+		case ctx.Err() != nil:
+			// The reader went away, or we are shutting down.
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "failed to tail", "error", err)
+			_ = stream.send("error", map[string]string{"error": err.Error()})
+			return
+		}
+		if err := stream.keepAlive(); err != nil {
+			return
+		}
+	}
+}
+
+type tailOptions struct {
+	start      kafka.Position
+	end        kafka.Position
+	partitions []int32
+	filter     *consume.Filter[*demov1.Cart]
+}
+
+func (h *tailHandler) parseQuery(request *http.Request) (tailOptions, error) {
+	query := request.URL.Query()
+	options := tailOptions{start: kafka.PositionLatest}
+	if value := query.Get("start"); value != "" {
+		start, err := kafka.ParsePosition(value)
+		if err != nil {
+			return tailOptions{}, fmt.Errorf("invalid start: %w", err)
+		}
+		options.start = start
+	}
+	if value := query.Get("end"); value != "" {
+		end, err := kafka.ParsePosition(value)
+		if err != nil {
+			return tailOptions{}, fmt.Errorf("invalid end: %w", err)
+		}
+		options.end = end
+	}
+	if value := query.Get("partitions"); value != "" {
+		for partitionString := range strings.SplitSeq(value, ",") {
+			partition, err := strconv.ParseInt(strings.TrimSpace(partitionString), 10, 32)
+			if err != nil || partition < 0 {
+				return tailOptions{}, fmt.Errorf("invalid partitions %q: bad partition %q", value, partitionString)
+			}
+			options.partitions = append(options.partitions, int32(partition))
+		}
+	}
+	if value := query.Get("filter"); value != "" {
+		filter, err := consume.NewFilter[*demov1.Cart](value)
+		if err != nil {
+			return tailOptions{}, err
+		}
+		options.filter = filter
+	}
+	return options, nil
+}
+
+// eventStream writes server-sent events to a response.
+type eventStream struct {
+	writer     http.ResponseWriter
+	controller *http.ResponseController
+	// sent is true if an event was sent since the last keep-alive.
+	sent bool
+}
+
+// send sends an event of the given type, with data encoded as JSON.
+func (s *eventStream) send(event string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	// JSON encoded by encoding/json never spans lines, so it fits in one data field.
+	if _, err := fmt.Fprintf(s.writer, "event: %s\ndata: %s\n\n", event, encoded); err != nil {
+		return err
+	}
+	s.sent = true
+	return s.controller.Flush()
+}
+
+// keepAlive sends a comment if no event was sent since the last call.
+func (s *eventStream) keepAlive() error {
+	if s.sent {
+		s.sent = false
+		return nil
+	}
+	if _, err := fmt.Fprint(s.writer, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
@@ -0,0 +1,87 @@
+// Package main implements a tool that provisions topics declared in a YAML file.
+//
+// By default, the tool prints the plan of changes needed to make the cluster match the file.
+// Pass --apply to make them. Plans that delete topics additionally require --confirm-delete.
+// See the topics package for the file format.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"github.com/spf13/pflag"
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+var flags = struct {
+	file          string
+	apply         bool
+	confirmDelete bool
+}{}
+
+func main() {
+	// See the app package for the boilerplate we use to set up the producer and
+	// consumer, including bound flags.
+	app.Main(run, app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&flags.file,
+		"file",
+		"",
+		"The YAML file declaring the topics to provision.",
+	)
+	flagSet.BoolVar(
+		&flags.apply,
+		"apply",
+		false,
+		"If true, apply the plan. Otherwise, only print it.",
+	)
+	flagSet.BoolVar(
+		&flags.confirmDelete,
+		"confirm-delete",
+		false,
+		"Confirms that the plan may delete topics, along with all of their data.",
+	)
+}
+
+func run(ctx context.Context, config app.Config) error {
+	if flags.file == "" {
+		return errors.New("--file is required")
+	}
+	specs, err := topics.LoadFile(flags.file)
+	if err != nil {
+		return err
+	}
+
+	client, err := kafka.NewKafkaClient(config.Kafka, false)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admClient := kadm.NewClient(client)
+
+	plan, err := topics.NewPlan(ctx, admClient, specs)
+	if err != nil {
+		return err
+	}
+	fmt.Println(plan.String())
+	if !flags.apply || len(plan.Changes) == 0 {
+		return nil
+	}
+	if deletes := plan.Deletes(); len(deletes) > 0 && !flags.confirmDelete {
+		return fmt.Errorf("refusing to delete topics %s without --confirm-delete", strings.Join(deletes, ", "))
+	}
+	if err := plan.Apply(ctx, admClient, flags.confirmDelete); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "applied plan", "changes", len(plan.Changes))
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package main implements a consumer that transforms carts as configured by a file, and
+// produces the results to another topic.
+//
+// The transformation is configured rather than programmed, so that a new projection, such as
+// the PublicCarts of config/transforms/public-cart.yaml, needs no new Go program. See the
+// transform package for the rules that can be used.
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"buf.build/go/protovalidate"
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/app"
+	"github.com/bufbuild/bufstream-demo/pkg/consume"
+	"github.com/bufbuild/bufstream-demo/pkg/health"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/bufbuild/bufstream-demo/pkg/transform"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+)
+
+var flags = struct {
+	transformFile string
+	outputTopic   string
+}{}
+
+// maxPollWait is how long Consume waits for records before returning anyway.
+const maxPollWait = 5 * time.Second
+
+func main() {
+	// See the app package for the boilerplate we use to set up the producer and
+	// consumer, including bound flags.
+	app.Main(run, app.WithPositionFlags(), app.WithFlags(bindFlags))
+}
+
+func bindFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&flags.transformFile,
+		"transform-file",
+		"",
+		"The YAML file that configures the transformation, such as config/transforms/public-cart.yaml.",
+	)
+	flagSet.StringVar(
+		&flags.outputTopic,
+		"output-topic",
+		"",
+		"The topic to produce the transformed messages to.",
+	)
+}
+
+func run(ctx context.Context, config app.Config) error {
+	if flags.transformFile == "" {
+		return errors.New("--transform-file is required")
+	}
+	if flags.outputTopic == "" {
+		return errors.New("--output-topic is required")
+	}
+	transformConfig, err := transform.LoadFile(flags.transformFile)
+	if err != nil {
+		return err
+	}
+	transformer, err := transform.New[*demov1.Cart](transformConfig)
+	if err != nil {
+		return err
+	}
+	// If --start is set, we replay the topic without joining the consumer group, so that the
+	// group's committed offsets are left untouched.
+	client, err := consume.NewKafkaClient(config.Kafka, config.Start)
+	if err != nil {
+		return err
+	}
+	app.OnShutdown(ctx, "close client", func(context.Context) error {
+		client.Close()
+		return nil
+	})
+	producer := produce.NewProducer[proto.Message](client, flags.outputTopic)
+
+	consumer := consume.NewConsumer(
+		client,
+		config.Kafka.Topic,
+		consume.WithMessageHandler(func(ctx context.Context, cart *demov1.Cart) error {
+			output, err := transformer.Transform(cart)
+			if err != nil {
+				// The same cart would fail again, so skip it rather than stop.
+				slog.ErrorContext(ctx, "failed to transform cart", "error", err)
+				return nil
+			}
+			// Invalid carts, such as those with a zero-quantity line item, make invalid output
+			// that the output topic would reject.
+			if err := protovalidate.Validate(output); err != nil {
+				slog.WarnContext(ctx, "skipped invalid transformed cart", "error", err)
+				return nil
+			}
+			// The input key, such as a cart or customer ID, may be what the transformation
+			// hides, so the output is produced without a key.
+			return producer.ProduceProtobufMessage(ctx, "", output)
+		}),
+		consume.WithStartPosition[*demov1.Cart](config.Start),
+		consume.WithEndPosition[*demov1.Cart](config.End),
+		// Return regularly even if the topic is idle, so that the loop below keeps beating.
+		consume.WithMaxPollWait[*demov1.Cart](maxPollWait),
+	)
+	app.OnShutdown(ctx, "close consumer", consumer.Close)
+
+	health.RegisterClient(app.Health(ctx), client, config.Kafka.Topic)
+	heartbeat := health.NewHeartbeat(config.LivenessTimeout)
+	app.Health(ctx).Register(health.Liveness, "consume-loop", heartbeat)
+
+	slog.InfoContext(
+		ctx,
+		"starting transform",
+		"transform_file", flags.transformFile,
+		"output", transformer.OutputType().Descriptor().FullName(),
+		"output_topic", flags.outputTopic,
+	)
+	for {
+		err := consumer.Consume(ctx)
+		heartbeat.Beat()
+		if err != nil {
+			if errors.Is(err, consume.ErrEndReached) {
+				slog.InfoContext(ctx, "reached end position")
+				return nil
+			}
+			return err
+		}
+	}
+}
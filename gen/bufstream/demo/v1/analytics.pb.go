@@ -0,0 +1,451 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: bufstream/demo/v1/analytics.proto
+
+// Implements types for the Bufstream demo.
+
+package demov1
+
+import (
+	_ "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// CategoryRevenue is the revenue of one category of products over a window of time,
+// aggregated from carts.
+type CategoryRevenue struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// window_start is the start of the window, inclusive.
+	WindowStart *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=window_start,json=windowStart,proto3" json:"window_start,omitempty"`
+	// window_end is the end of the window, exclusive.
+	WindowEnd *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=window_end,json=windowEnd,proto3" json:"window_end,omitempty"`
+	// category_id identifies the category.
+	CategoryId string `protobuf:"bytes,3,opt,name=category_id,json=categoryId,proto3" json:"category_id,omitempty"`
+	// revenue_cents is the total of quantity * unit_price_cents of the category's line items.
+	RevenueCents uint64 `protobuf:"varint,4,opt,name=revenue_cents,json=revenueCents,proto3" json:"revenue_cents,omitempty"`
+	// units is the total quantity of the category's line items.
+	Units uint64 `protobuf:"varint,5,opt,name=units,proto3" json:"units,omitempty"`
+	// cart_count is the number of carts with at least one line item in the category.
+	CartCount uint64 `protobuf:"varint,6,opt,name=cart_count,json=cartCount,proto3" json:"cart_count,omitempty"`
+	// products break the category's revenue down by product.
+	Products      []*ProductRevenue `protobuf:"bytes,7,rep,name=products,proto3" json:"products,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CategoryRevenue) Reset() {
+	*x = CategoryRevenue{}
+	mi := &file_bufstream_demo_v1_analytics_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CategoryRevenue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CategoryRevenue) ProtoMessage() {}
+
+func (x *CategoryRevenue) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_analytics_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CategoryRevenue.ProtoReflect.Descriptor instead.
+func (*CategoryRevenue) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_analytics_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CategoryRevenue) GetWindowStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowStart
+	}
+	return nil
+}
+
+func (x *CategoryRevenue) GetWindowEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowEnd
+	}
+	return nil
+}
+
+func (x *CategoryRevenue) GetCategoryId() string {
+	if x != nil {
+		return x.CategoryId
+	}
+	return ""
+}
+
+func (x *CategoryRevenue) GetRevenueCents() uint64 {
+	if x != nil {
+		return x.RevenueCents
+	}
+	return 0
+}
+
+func (x *CategoryRevenue) GetUnits() uint64 {
+	if x != nil {
+		return x.Units
+	}
+	return 0
+}
+
+func (x *CategoryRevenue) GetCartCount() uint64 {
+	if x != nil {
+		return x.CartCount
+	}
+	return 0
+}
+
+func (x *CategoryRevenue) GetProducts() []*ProductRevenue {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+// ProductRevenue is the revenue of one product over a window of time.
+type ProductRevenue struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// product_id identifies the product.
+	ProductId string `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	// revenue_cents is the total of quantity * unit_price_cents of the product's line items.
+	RevenueCents uint64 `protobuf:"varint,2,opt,name=revenue_cents,json=revenueCents,proto3" json:"revenue_cents,omitempty"`
+	// units is the total quantity of the product's line items.
+	Units uint64 `protobuf:"varint,3,opt,name=units,proto3" json:"units,omitempty"`
+	// cart_count is the number of carts with the product.
+	CartCount     uint64 `protobuf:"varint,4,opt,name=cart_count,json=cartCount,proto3" json:"cart_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductRevenue) Reset() {
+	*x = ProductRevenue{}
+	mi := &file_bufstream_demo_v1_analytics_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductRevenue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductRevenue) ProtoMessage() {}
+
+func (x *ProductRevenue) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_analytics_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductRevenue.ProtoReflect.Descriptor instead.
+func (*ProductRevenue) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_analytics_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ProductRevenue) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *ProductRevenue) GetRevenueCents() uint64 {
+	if x != nil {
+		return x.RevenueCents
+	}
+	return 0
+}
+
+func (x *ProductRevenue) GetUnits() uint64 {
+	if x != nil {
+		return x.Units
+	}
+	return 0
+}
+
+func (x *ProductRevenue) GetCartCount() uint64 {
+	if x != nil {
+		return x.CartCount
+	}
+	return 0
+}
+
+// PublicCart is a projection of a Cart for the public analytics topic, without the product
+// details that only the catalog needs.
+//
+// PublicCarts are projected from carts by bufstream-demo-transform, as configured in
+// config/transforms/public-cart.yaml.
+type PublicCart struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// cart_id identifies the cart, masked to its last characters so that public carts cannot
+	// be joined with orders.
+	CartId string `protobuf:"bytes,1,opt,name=cart_id,json=cartId,proto3" json:"cart_id,omitempty"`
+	// line_item_count is the number of line items in the cart.
+	LineItemCount uint64 `protobuf:"varint,2,opt,name=line_item_count,json=lineItemCount,proto3" json:"line_item_count,omitempty"`
+	// category_ids are the categories of the cart's line items, in the order of the line
+	// items. A category appears once for each of its line items.
+	CategoryIds []string `protobuf:"bytes,3,rep,name=category_ids,json=categoryIds,proto3" json:"category_ids,omitempty"`
+	// line_items are the cart's line items.
+	LineItems     []*PublicLineItem `protobuf:"bytes,4,rep,name=line_items,json=lineItems,proto3" json:"line_items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublicCart) Reset() {
+	*x = PublicCart{}
+	mi := &file_bufstream_demo_v1_analytics_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublicCart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublicCart) ProtoMessage() {}
+
+func (x *PublicCart) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_analytics_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublicCart.ProtoReflect.Descriptor instead.
+func (*PublicCart) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_analytics_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PublicCart) GetCartId() string {
+	if x != nil {
+		return x.CartId
+	}
+	return ""
+}
+
+func (x *PublicCart) GetLineItemCount() uint64 {
+	if x != nil {
+		return x.LineItemCount
+	}
+	return 0
+}
+
+func (x *PublicCart) GetCategoryIds() []string {
+	if x != nil {
+		return x.CategoryIds
+	}
+	return nil
+}
+
+func (x *PublicCart) GetLineItems() []*PublicLineItem {
+	if x != nil {
+		return x.LineItems
+	}
+	return nil
+}
+
+// PublicLineItem is a projection of a LineItem for the public analytics topic.
+type PublicLineItem struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// product_id identifies the product.
+	ProductId string `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	// category_id identifies the category of the product.
+	CategoryId string `protobuf:"bytes,2,opt,name=category_id,json=categoryId,proto3" json:"category_id,omitempty"`
+	// quantity is the number of units of the product.
+	Quantity uint64 `protobuf:"varint,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	// unit_price_cents is the price of one unit of the product.
+	UnitPriceCents uint64 `protobuf:"varint,4,opt,name=unit_price_cents,json=unitPriceCents,proto3" json:"unit_price_cents,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PublicLineItem) Reset() {
+	*x = PublicLineItem{}
+	mi := &file_bufstream_demo_v1_analytics_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublicLineItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublicLineItem) ProtoMessage() {}
+
+func (x *PublicLineItem) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_analytics_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublicLineItem.ProtoReflect.Descriptor instead.
+func (*PublicLineItem) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_analytics_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PublicLineItem) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *PublicLineItem) GetCategoryId() string {
+	if x != nil {
+		return x.CategoryId
+	}
+	return ""
+}
+
+func (x *PublicLineItem) GetQuantity() uint64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *PublicLineItem) GetUnitPriceCents() uint64 {
+	if x != nil {
+		return x.UnitPriceCents
+	}
+	return 0
+}
+
+var File_bufstream_demo_v1_analytics_proto protoreflect.FileDescriptor
+
+const file_bufstream_demo_v1_analytics_proto_rawDesc = "" +
+	"\n" +
+	"!bufstream/demo/v1/analytics.proto\x12\x11bufstream.demo.v1\x1a\x1bbuf/validate/validate.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc3\x05\n" +
+	"\x0fCategoryRevenue\x12E\n" +
+	"\fwindow_start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampB\x06\xbaH\x03\xc8\x01\x01R\vwindowStart\x12A\n" +
+	"\n" +
+	"window_end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampB\x06\xbaH\x03\xc8\x01\x01R\twindowEnd\x125\n" +
+	"\vcategory_id\x18\x03 \x01(\tB\x14\xbaH\x11r\x0f\x10\x01\x1822\t^[a-z_]+$R\n" +
+	"categoryId\x12#\n" +
+	"\rrevenue_cents\x18\x04 \x01(\x04R\frevenueCents\x12\x14\n" +
+	"\x05units\x18\x05 \x01(\x04R\x05units\x12&\n" +
+	"\n" +
+	"cart_count\x18\x06 \x01(\x04B\a\xbaH\x042\x02 \x00R\tcartCount\x12\xb2\x01\n" +
+	"\bproducts\x18\a \x03(\v2!.bufstream.demo.v1.ProductRevenueBs\xbaHp\xba\x01h\n" +
+	"\x1aproducts.unique_product_id\x12$all product_id values must be unique\x1a$this.map(it, it.product_id).unique()\x92\x01\x02\b\x01R\bproducts:\xd6\x01\xbaH\xd2\x01\x1ae\n" +
+	"\x17category_revenue.window\x12%window_end must be after window_start\x1a#this.window_end > this.window_start\x1ai\n" +
+	"%category_revenue.units_gte_cart_count\x12!units must be at least cart_count\x1a\x1dthis.units >= this.cart_count\"\x8b\x02\n" +
+	"\x0eProductRevenue\x12'\n" +
+	"\n" +
+	"product_id\x18\x01 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\tproductId\x12#\n" +
+	"\rrevenue_cents\x18\x02 \x01(\x04R\frevenueCents\x12\x14\n" +
+	"\x05units\x18\x03 \x01(\x04R\x05units\x12&\n" +
+	"\n" +
+	"cart_count\x18\x04 \x01(\x04B\a\xbaH\x042\x02 \x00R\tcartCount:m\xbaHj\x1ah\n" +
+	"$product_revenue.units_gte_cart_count\x12!units must be at least cart_count\x1a\x1dthis.units >= this.cart_count\"\xb2\x01\n" +
+	"\n" +
+	"PublicCart\x12\x17\n" +
+	"\acart_id\x18\x01 \x01(\tR\x06cartId\x12&\n" +
+	"\x0fline_item_count\x18\x02 \x01(\x04R\rlineItemCount\x12!\n" +
+	"\fcategory_ids\x18\x03 \x03(\tR\vcategoryIds\x12@\n" +
+	"\n" +
+	"line_items\x18\x04 \x03(\v2!.bufstream.demo.v1.PublicLineItemR\tlineItems\"\xa9\x01\n" +
+	"\x0ePublicLineItem\x12'\n" +
+	"\n" +
+	"product_id\x18\x01 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\tproductId\x12\x1f\n" +
+	"\vcategory_id\x18\x02 \x01(\tR\n" +
+	"categoryId\x12#\n" +
+	"\bquantity\x18\x03 \x01(\x04B\a\xbaH\x042\x02 \x00R\bquantity\x12(\n" +
+	"\x10unit_price_cents\x18\x04 \x01(\x04R\x0eunitPriceCentsB\xce\x01\n" +
+	"\x15com.bufstream.demo.v1B\x0eAnalyticsProtoP\x01Z?github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1;demov1\xa2\x02\x03BDX\xaa\x02\x11Bufstream.Demo.V1\xca\x02\x11Bufstream\\Demo\\V1\xe2\x02\x1dBufstream\\Demo\\V1\\GPBMetadata\xea\x02\x13Bufstream::Demo::V1b\x06proto3"
+
+var (
+	file_bufstream_demo_v1_analytics_proto_rawDescOnce sync.Once
+	file_bufstream_demo_v1_analytics_proto_rawDescData []byte
+)
+
+func file_bufstream_demo_v1_analytics_proto_rawDescGZIP() []byte {
+	file_bufstream_demo_v1_analytics_proto_rawDescOnce.Do(func() {
+		file_bufstream_demo_v1_analytics_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_bufstream_demo_v1_analytics_proto_rawDesc), len(file_bufstream_demo_v1_analytics_proto_rawDesc)))
+	})
+	return file_bufstream_demo_v1_analytics_proto_rawDescData
+}
+
+var file_bufstream_demo_v1_analytics_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_bufstream_demo_v1_analytics_proto_goTypes = []any{
+	(*CategoryRevenue)(nil),       // 0: bufstream.demo.v1.CategoryRevenue
+	(*ProductRevenue)(nil),        // 1: bufstream.demo.v1.ProductRevenue
+	(*PublicCart)(nil),            // 2: bufstream.demo.v1.PublicCart
+	(*PublicLineItem)(nil),        // 3: bufstream.demo.v1.PublicLineItem
+	(*timestamppb.Timestamp)(nil), // 4: google.protobuf.Timestamp
+}
+var file_bufstream_demo_v1_analytics_proto_depIdxs = []int32{
+	4, // 0: bufstream.demo.v1.CategoryRevenue.window_start:type_name -> google.protobuf.Timestamp
+	4, // 1: bufstream.demo.v1.CategoryRevenue.window_end:type_name -> google.protobuf.Timestamp
+	1, // 2: bufstream.demo.v1.CategoryRevenue.products:type_name -> bufstream.demo.v1.ProductRevenue
+	3, // 3: bufstream.demo.v1.PublicCart.line_items:type_name -> bufstream.demo.v1.PublicLineItem
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_bufstream_demo_v1_analytics_proto_init() }
+func file_bufstream_demo_v1_analytics_proto_init() {
+	if File_bufstream_demo_v1_analytics_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bufstream_demo_v1_analytics_proto_rawDesc), len(file_bufstream_demo_v1_analytics_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_bufstream_demo_v1_analytics_proto_goTypes,
+		DependencyIndexes: file_bufstream_demo_v1_analytics_proto_depIdxs,
+		MessageInfos:      file_bufstream_demo_v1_analytics_proto_msgTypes,
+	}.Build()
+	File_bufstream_demo_v1_analytics_proto = out.File
+	file_bufstream_demo_v1_analytics_proto_goTypes = nil
+	file_bufstream_demo_v1_analytics_proto_depIdxs = nil
+}
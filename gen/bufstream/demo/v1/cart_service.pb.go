@@ -0,0 +1,215 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: bufstream/demo/v1/cart_service.proto
+
+// Implements types for the Bufstream demo.
+
+package demov1
+
+import (
+	_ "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SubmitCartRequest is a request to submit a cart.
+type SubmitCartRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// cart is the cart to produce. It is validated before it is produced.
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+	// key is the key of the record. If empty, the cart_id is used.
+	Key           string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitCartRequest) Reset() {
+	*x = SubmitCartRequest{}
+	mi := &file_bufstream_demo_v1_cart_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitCartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitCartRequest) ProtoMessage() {}
+
+func (x *SubmitCartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_cart_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitCartRequest.ProtoReflect.Descriptor instead.
+func (*SubmitCartRequest) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_cart_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitCartRequest) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+func (x *SubmitCartRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+// SubmitCartResponse is where a submitted cart was produced.
+type SubmitCartResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// topic is the topic the cart was produced to.
+	Topic string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	// partition is the partition the cart was produced to.
+	Partition int32 `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	// offset is the offset of the cart's record within the partition.
+	Offset        int64 `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitCartResponse) Reset() {
+	*x = SubmitCartResponse{}
+	mi := &file_bufstream_demo_v1_cart_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitCartResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitCartResponse) ProtoMessage() {}
+
+func (x *SubmitCartResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_cart_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitCartResponse.ProtoReflect.Descriptor instead.
+func (*SubmitCartResponse) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_cart_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubmitCartResponse) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *SubmitCartResponse) GetPartition() int32 {
+	if x != nil {
+		return x.Partition
+	}
+	return 0
+}
+
+func (x *SubmitCartResponse) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+var File_bufstream_demo_v1_cart_service_proto protoreflect.FileDescriptor
+
+const file_bufstream_demo_v1_cart_service_proto_rawDesc = "" +
+	"\n" +
+	"$bufstream/demo/v1/cart_service.proto\x12\x11bufstream.demo.v1\x1a\x1bbuf/validate/validate.proto\x1a\x1cbufstream/demo/v1/demo.proto\"d\n" +
+	"\x11SubmitCartRequest\x123\n" +
+	"\x04cart\x18\x01 \x01(\v2\x17.bufstream.demo.v1.CartB\x06\xbaH\x03\xc8\x01\x01R\x04cart\x12\x1a\n" +
+	"\x03key\x18\x02 \x01(\tB\b\xbaH\x05r\x03\x18\x80\x02R\x03key\"`\n" +
+	"\x12SubmitCartResponse\x12\x14\n" +
+	"\x05topic\x18\x01 \x01(\tR\x05topic\x12\x1c\n" +
+	"\tpartition\x18\x02 \x01(\x05R\tpartition\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset2h\n" +
+	"\vCartService\x12Y\n" +
+	"\n" +
+	"SubmitCart\x12$.bufstream.demo.v1.SubmitCartRequest\x1a%.bufstream.demo.v1.SubmitCartResponseB\xd0\x01\n" +
+	"\x15com.bufstream.demo.v1B\x10CartServiceProtoP\x01Z?github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1;demov1\xa2\x02\x03BDX\xaa\x02\x11Bufstream.Demo.V1\xca\x02\x11Bufstream\\Demo\\V1\xe2\x02\x1dBufstream\\Demo\\V1\\GPBMetadata\xea\x02\x13Bufstream::Demo::V1b\x06proto3"
+
+var (
+	file_bufstream_demo_v1_cart_service_proto_rawDescOnce sync.Once
+	file_bufstream_demo_v1_cart_service_proto_rawDescData []byte
+)
+
+func file_bufstream_demo_v1_cart_service_proto_rawDescGZIP() []byte {
+	file_bufstream_demo_v1_cart_service_proto_rawDescOnce.Do(func() {
+		file_bufstream_demo_v1_cart_service_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_bufstream_demo_v1_cart_service_proto_rawDesc), len(file_bufstream_demo_v1_cart_service_proto_rawDesc)))
+	})
+	return file_bufstream_demo_v1_cart_service_proto_rawDescData
+}
+
+var file_bufstream_demo_v1_cart_service_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_bufstream_demo_v1_cart_service_proto_goTypes = []any{
+	(*SubmitCartRequest)(nil),  // 0: bufstream.demo.v1.SubmitCartRequest
+	(*SubmitCartResponse)(nil), // 1: bufstream.demo.v1.SubmitCartResponse
+	(*Cart)(nil),               // 2: bufstream.demo.v1.Cart
+}
+var file_bufstream_demo_v1_cart_service_proto_depIdxs = []int32{
+	2, // 0: bufstream.demo.v1.SubmitCartRequest.cart:type_name -> bufstream.demo.v1.Cart
+	0, // 1: bufstream.demo.v1.CartService.SubmitCart:input_type -> bufstream.demo.v1.SubmitCartRequest
+	1, // 2: bufstream.demo.v1.CartService.SubmitCart:output_type -> bufstream.demo.v1.SubmitCartResponse
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_bufstream_demo_v1_cart_service_proto_init() }
+func file_bufstream_demo_v1_cart_service_proto_init() {
+	if File_bufstream_demo_v1_cart_service_proto != nil {
+		return
+	}
+	file_bufstream_demo_v1_demo_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bufstream_demo_v1_cart_service_proto_rawDesc), len(file_bufstream_demo_v1_cart_service_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_bufstream_demo_v1_cart_service_proto_goTypes,
+		DependencyIndexes: file_bufstream_demo_v1_cart_service_proto_depIdxs,
+		MessageInfos:      file_bufstream_demo_v1_cart_service_proto_msgTypes,
+	}.Build()
+	File_bufstream_demo_v1_cart_service_proto = out.File
+	file_bufstream_demo_v1_cart_service_proto_goTypes = nil
+	file_bufstream_demo_v1_cart_service_proto_depIdxs = nil
+}
@@ -0,0 +1,117 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: bufstream/demo/v1/cart_service.proto
+
+// Implements types for the Bufstream demo.
+package demov1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	v1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// CartServiceName is the fully-qualified name of the CartService service.
+	CartServiceName = "bufstream.demo.v1.CartService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// CartServiceSubmitCartProcedure is the fully-qualified name of the CartService's SubmitCart RPC.
+	CartServiceSubmitCartProcedure = "/bufstream.demo.v1.CartService/SubmitCart"
+)
+
+// CartServiceClient is a client for the bufstream.demo.v1.CartService service.
+type CartServiceClient interface {
+	// SubmitCart validates a cart and produces it.
+	//
+	// A cart that fails validation is rejected with INVALID_ARGUMENT, and a
+	// buf.validate.Violations error detail listing every violation by field path.
+	SubmitCart(context.Context, *connect.Request[v1.SubmitCartRequest]) (*connect.Response[v1.SubmitCartResponse], error)
+}
+
+// NewCartServiceClient constructs a client for the bufstream.demo.v1.CartService service. By
+// default, it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses,
+// and sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the
+// connect.WithGRPC() or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewCartServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) CartServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	cartServiceMethods := v1.File_bufstream_demo_v1_cart_service_proto.Services().ByName("CartService").Methods()
+	return &cartServiceClient{
+		submitCart: connect.NewClient[v1.SubmitCartRequest, v1.SubmitCartResponse](
+			httpClient,
+			baseURL+CartServiceSubmitCartProcedure,
+			connect.WithSchema(cartServiceMethods.ByName("SubmitCart")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// cartServiceClient implements CartServiceClient.
+type cartServiceClient struct {
+	submitCart *connect.Client[v1.SubmitCartRequest, v1.SubmitCartResponse]
+}
+
+// SubmitCart calls bufstream.demo.v1.CartService.SubmitCart.
+func (c *cartServiceClient) SubmitCart(ctx context.Context, req *connect.Request[v1.SubmitCartRequest]) (*connect.Response[v1.SubmitCartResponse], error) {
+	return c.submitCart.CallUnary(ctx, req)
+}
+
+// CartServiceHandler is an implementation of the bufstream.demo.v1.CartService service.
+type CartServiceHandler interface {
+	// SubmitCart validates a cart and produces it.
+	//
+	// A cart that fails validation is rejected with INVALID_ARGUMENT, and a
+	// buf.validate.Violations error detail listing every violation by field path.
+	SubmitCart(context.Context, *connect.Request[v1.SubmitCartRequest]) (*connect.Response[v1.SubmitCartResponse], error)
+}
+
+// NewCartServiceHandler builds an HTTP handler from the service implementation. It returns the path
+// on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewCartServiceHandler(svc CartServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	cartServiceMethods := v1.File_bufstream_demo_v1_cart_service_proto.Services().ByName("CartService").Methods()
+	cartServiceSubmitCartHandler := connect.NewUnaryHandler(
+		CartServiceSubmitCartProcedure,
+		svc.SubmitCart,
+		connect.WithSchema(cartServiceMethods.ByName("SubmitCart")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/bufstream.demo.v1.CartService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case CartServiceSubmitCartProcedure:
+			cartServiceSubmitCartHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedCartServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedCartServiceHandler struct{}
+
+func (UnimplementedCartServiceHandler) SubmitCart(context.Context, *connect.Request[v1.SubmitCartRequest]) (*connect.Response[v1.SubmitCartResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("bufstream.demo.v1.CartService.SubmitCart is not implemented"))
+}
@@ -0,0 +1,916 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: bufstream/demo/v1/lifecycle.proto
+
+// Implements types for the Bufstream demo.
+
+package demov1
+
+import (
+	_ "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// PaymentMethod is how a payment was made.
+type PaymentMethod int32
+
+const (
+	PaymentMethod_PAYMENT_METHOD_UNSPECIFIED   PaymentMethod = 0
+	PaymentMethod_PAYMENT_METHOD_CARD          PaymentMethod = 1
+	PaymentMethod_PAYMENT_METHOD_WALLET        PaymentMethod = 2
+	PaymentMethod_PAYMENT_METHOD_BANK_TRANSFER PaymentMethod = 3
+)
+
+// Enum value maps for PaymentMethod.
+var (
+	PaymentMethod_name = map[int32]string{
+		0: "PAYMENT_METHOD_UNSPECIFIED",
+		1: "PAYMENT_METHOD_CARD",
+		2: "PAYMENT_METHOD_WALLET",
+		3: "PAYMENT_METHOD_BANK_TRANSFER",
+	}
+	PaymentMethod_value = map[string]int32{
+		"PAYMENT_METHOD_UNSPECIFIED":   0,
+		"PAYMENT_METHOD_CARD":          1,
+		"PAYMENT_METHOD_WALLET":        2,
+		"PAYMENT_METHOD_BANK_TRANSFER": 3,
+	}
+)
+
+func (x PaymentMethod) Enum() *PaymentMethod {
+	p := new(PaymentMethod)
+	*p = x
+	return p
+}
+
+func (x PaymentMethod) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PaymentMethod) Descriptor() protoreflect.EnumDescriptor {
+	return file_bufstream_demo_v1_lifecycle_proto_enumTypes[0].Descriptor()
+}
+
+func (PaymentMethod) Type() protoreflect.EnumType {
+	return &file_bufstream_demo_v1_lifecycle_proto_enumTypes[0]
+}
+
+func (x PaymentMethod) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PaymentMethod.Descriptor instead.
+func (PaymentMethod) EnumDescriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP(), []int{0}
+}
+
+// PaymentStatus is the outcome of a payment.
+type PaymentStatus int32
+
+const (
+	PaymentStatus_PAYMENT_STATUS_UNSPECIFIED PaymentStatus = 0
+	PaymentStatus_PAYMENT_STATUS_CAPTURED    PaymentStatus = 1
+	PaymentStatus_PAYMENT_STATUS_FAILED      PaymentStatus = 2
+)
+
+// Enum value maps for PaymentStatus.
+var (
+	PaymentStatus_name = map[int32]string{
+		0: "PAYMENT_STATUS_UNSPECIFIED",
+		1: "PAYMENT_STATUS_CAPTURED",
+		2: "PAYMENT_STATUS_FAILED",
+	}
+	PaymentStatus_value = map[string]int32{
+		"PAYMENT_STATUS_UNSPECIFIED": 0,
+		"PAYMENT_STATUS_CAPTURED":    1,
+		"PAYMENT_STATUS_FAILED":      2,
+	}
+)
+
+func (x PaymentStatus) Enum() *PaymentStatus {
+	p := new(PaymentStatus)
+	*p = x
+	return p
+}
+
+func (x PaymentStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PaymentStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_bufstream_demo_v1_lifecycle_proto_enumTypes[1].Descriptor()
+}
+
+func (PaymentStatus) Type() protoreflect.EnumType {
+	return &file_bufstream_demo_v1_lifecycle_proto_enumTypes[1]
+}
+
+func (x PaymentStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PaymentStatus.Descriptor instead.
+func (PaymentStatus) EnumDescriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP(), []int{1}
+}
+
+// OrderEvent is one step in the lifecycle of an order, from the customer checking out a
+// cart to the order being shipped and refunded.
+//
+// All events of an order share its order_id, which is the cart_id of the cart that was
+// checked out. Keying records by order_id keeps each order's events in order.
+type OrderEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// event_id is a unique identifier for this event.
+	EventId string `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// order_id identifies the order that this event belongs to. It is the cart_id of the
+	// checked out cart.
+	OrderId string `protobuf:"bytes,2,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	// customer_id identifies the customer who placed the order.
+	CustomerId string `protobuf:"bytes,3,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	// occurred_at is when the event happened.
+	OccurredAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	// event is what happened.
+	//
+	// Types that are valid to be assigned to Event:
+	//
+	//	*OrderEvent_CustomerRegistered
+	//	*OrderEvent_Checkout
+	//	*OrderEvent_Payment
+	//	*OrderEvent_Shipment
+	//	*OrderEvent_Refund
+	Event         isOrderEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderEvent) Reset() {
+	*x = OrderEvent{}
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderEvent) ProtoMessage() {}
+
+func (x *OrderEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderEvent.ProtoReflect.Descriptor instead.
+func (*OrderEvent) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *OrderEvent) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *OrderEvent) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *OrderEvent) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *OrderEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+func (x *OrderEvent) GetEvent() isOrderEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *OrderEvent) GetCustomerRegistered() *Customer {
+	if x != nil {
+		if x, ok := x.Event.(*OrderEvent_CustomerRegistered); ok {
+			return x.CustomerRegistered
+		}
+	}
+	return nil
+}
+
+func (x *OrderEvent) GetCheckout() *Checkout {
+	if x != nil {
+		if x, ok := x.Event.(*OrderEvent_Checkout); ok {
+			return x.Checkout
+		}
+	}
+	return nil
+}
+
+func (x *OrderEvent) GetPayment() *Payment {
+	if x != nil {
+		if x, ok := x.Event.(*OrderEvent_Payment); ok {
+			return x.Payment
+		}
+	}
+	return nil
+}
+
+func (x *OrderEvent) GetShipment() *Shipment {
+	if x != nil {
+		if x, ok := x.Event.(*OrderEvent_Shipment); ok {
+			return x.Shipment
+		}
+	}
+	return nil
+}
+
+func (x *OrderEvent) GetRefund() *Refund {
+	if x != nil {
+		if x, ok := x.Event.(*OrderEvent_Refund); ok {
+			return x.Refund
+		}
+	}
+	return nil
+}
+
+type isOrderEvent_Event interface {
+	isOrderEvent_Event()
+}
+
+type OrderEvent_CustomerRegistered struct {
+	// customer_registered is sent before the first order of a new customer.
+	CustomerRegistered *Customer `protobuf:"bytes,10,opt,name=customer_registered,json=customerRegistered,proto3,oneof"`
+}
+
+type OrderEvent_Checkout struct {
+	// checkout is sent when the customer checks out a cart.
+	Checkout *Checkout `protobuf:"bytes,11,opt,name=checkout,proto3,oneof"`
+}
+
+type OrderEvent_Payment struct {
+	// payment is sent when a payment for the order is captured or fails.
+	Payment *Payment `protobuf:"bytes,12,opt,name=payment,proto3,oneof"`
+}
+
+type OrderEvent_Shipment struct {
+	// shipment is sent when the order is shipped.
+	Shipment *Shipment `protobuf:"bytes,13,opt,name=shipment,proto3,oneof"`
+}
+
+type OrderEvent_Refund struct {
+	// refund is sent when some or all of a payment is refunded.
+	Refund *Refund `protobuf:"bytes,14,opt,name=refund,proto3,oneof"`
+}
+
+func (*OrderEvent_CustomerRegistered) isOrderEvent_Event() {}
+
+func (*OrderEvent_Checkout) isOrderEvent_Event() {}
+
+func (*OrderEvent_Payment) isOrderEvent_Event() {}
+
+func (*OrderEvent_Shipment) isOrderEvent_Event() {}
+
+func (*OrderEvent_Refund) isOrderEvent_Event() {}
+
+// Customer is a person who places orders.
+type Customer struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// customer_id is the unique identifier for this customer.
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	// email is the customer's email address.
+	Email string `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	// name is the customer's display name.
+	Name          string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Customer) Reset() {
+	*x = Customer{}
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Customer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Customer) ProtoMessage() {}
+
+func (x *Customer) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Customer.ProtoReflect.Descriptor instead.
+func (*Customer) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Customer) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *Customer) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *Customer) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// Checkout is a cart that a customer has committed to buy.
+type Checkout struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// checkout_id is the unique identifier for this checkout.
+	CheckoutId string `protobuf:"bytes,1,opt,name=checkout_id,json=checkoutId,proto3" json:"checkout_id,omitempty"`
+	// cart_id identifies the cart that was checked out.
+	CartId string `protobuf:"bytes,2,opt,name=cart_id,json=cartId,proto3" json:"cart_id,omitempty"`
+	// customer_id identifies the customer who checked out.
+	CustomerId string `protobuf:"bytes,3,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	// subtotal_cents is the sum of the cart's line items.
+	SubtotalCents uint64 `protobuf:"varint,4,opt,name=subtotal_cents,json=subtotalCents,proto3" json:"subtotal_cents,omitempty"`
+	// discount_cents is the amount taken off the subtotal.
+	DiscountCents uint64 `protobuf:"varint,5,opt,name=discount_cents,json=discountCents,proto3" json:"discount_cents,omitempty"`
+	// shipping_cents is the cost of shipping.
+	ShippingCents uint64 `protobuf:"varint,6,opt,name=shipping_cents,json=shippingCents,proto3" json:"shipping_cents,omitempty"`
+	// tax_cents is the tax due on the order.
+	TaxCents uint64 `protobuf:"varint,7,opt,name=tax_cents,json=taxCents,proto3" json:"tax_cents,omitempty"`
+	// total_cents is the amount that the customer must pay.
+	TotalCents    uint64 `protobuf:"varint,8,opt,name=total_cents,json=totalCents,proto3" json:"total_cents,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Checkout) Reset() {
+	*x = Checkout{}
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Checkout) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Checkout) ProtoMessage() {}
+
+func (x *Checkout) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Checkout.ProtoReflect.Descriptor instead.
+func (*Checkout) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Checkout) GetCheckoutId() string {
+	if x != nil {
+		return x.CheckoutId
+	}
+	return ""
+}
+
+func (x *Checkout) GetCartId() string {
+	if x != nil {
+		return x.CartId
+	}
+	return ""
+}
+
+func (x *Checkout) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *Checkout) GetSubtotalCents() uint64 {
+	if x != nil {
+		return x.SubtotalCents
+	}
+	return 0
+}
+
+func (x *Checkout) GetDiscountCents() uint64 {
+	if x != nil {
+		return x.DiscountCents
+	}
+	return 0
+}
+
+func (x *Checkout) GetShippingCents() uint64 {
+	if x != nil {
+		return x.ShippingCents
+	}
+	return 0
+}
+
+func (x *Checkout) GetTaxCents() uint64 {
+	if x != nil {
+		return x.TaxCents
+	}
+	return 0
+}
+
+func (x *Checkout) GetTotalCents() uint64 {
+	if x != nil {
+		return x.TotalCents
+	}
+	return 0
+}
+
+// Payment is an attempt to collect the total of a checkout.
+type Payment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// payment_id is the unique identifier for this payment.
+	PaymentId string `protobuf:"bytes,1,opt,name=payment_id,json=paymentId,proto3" json:"payment_id,omitempty"`
+	// checkout_id identifies the checkout being paid for.
+	CheckoutId string `protobuf:"bytes,2,opt,name=checkout_id,json=checkoutId,proto3" json:"checkout_id,omitempty"`
+	// amount_cents is the amount collected.
+	AmountCents uint64 `protobuf:"varint,3,opt,name=amount_cents,json=amountCents,proto3" json:"amount_cents,omitempty"`
+	// method is how the customer paid.
+	Method PaymentMethod `protobuf:"varint,4,opt,name=method,proto3,enum=bufstream.demo.v1.PaymentMethod" json:"method,omitempty"`
+	// status is the outcome of the payment.
+	Status PaymentStatus `protobuf:"varint,5,opt,name=status,proto3,enum=bufstream.demo.v1.PaymentStatus" json:"status,omitempty"`
+	// failure_reason explains why a failed payment failed.
+	FailureReason string `protobuf:"bytes,6,opt,name=failure_reason,json=failureReason,proto3" json:"failure_reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Payment) Reset() {
+	*x = Payment{}
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Payment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payment) ProtoMessage() {}
+
+func (x *Payment) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payment.ProtoReflect.Descriptor instead.
+func (*Payment) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Payment) GetPaymentId() string {
+	if x != nil {
+		return x.PaymentId
+	}
+	return ""
+}
+
+func (x *Payment) GetCheckoutId() string {
+	if x != nil {
+		return x.CheckoutId
+	}
+	return ""
+}
+
+func (x *Payment) GetAmountCents() uint64 {
+	if x != nil {
+		return x.AmountCents
+	}
+	return 0
+}
+
+func (x *Payment) GetMethod() PaymentMethod {
+	if x != nil {
+		return x.Method
+	}
+	return PaymentMethod_PAYMENT_METHOD_UNSPECIFIED
+}
+
+func (x *Payment) GetStatus() PaymentStatus {
+	if x != nil {
+		return x.Status
+	}
+	return PaymentStatus_PAYMENT_STATUS_UNSPECIFIED
+}
+
+func (x *Payment) GetFailureReason() string {
+	if x != nil {
+		return x.FailureReason
+	}
+	return ""
+}
+
+// Shipment is the delivery of an order to its customer.
+type Shipment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// shipment_id is the unique identifier for this shipment.
+	ShipmentId string `protobuf:"bytes,1,opt,name=shipment_id,json=shipmentId,proto3" json:"shipment_id,omitempty"`
+	// checkout_id identifies the checkout being shipped.
+	CheckoutId string `protobuf:"bytes,2,opt,name=checkout_id,json=checkoutId,proto3" json:"checkout_id,omitempty"`
+	// carrier is the name of the company delivering the shipment.
+	Carrier string `protobuf:"bytes,3,opt,name=carrier,proto3" json:"carrier,omitempty"`
+	// tracking_number is the carrier's identifier for the shipment.
+	TrackingNumber string `protobuf:"bytes,4,opt,name=tracking_number,json=trackingNumber,proto3" json:"tracking_number,omitempty"`
+	// shipped_at is when the shipment left the warehouse.
+	ShippedAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=shipped_at,json=shippedAt,proto3" json:"shipped_at,omitempty"`
+	// delivered_at is when the shipment arrived, if it has.
+	DeliveredAt   *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=delivered_at,json=deliveredAt,proto3" json:"delivered_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Shipment) Reset() {
+	*x = Shipment{}
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Shipment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Shipment) ProtoMessage() {}
+
+func (x *Shipment) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Shipment.ProtoReflect.Descriptor instead.
+func (*Shipment) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Shipment) GetShipmentId() string {
+	if x != nil {
+		return x.ShipmentId
+	}
+	return ""
+}
+
+func (x *Shipment) GetCheckoutId() string {
+	if x != nil {
+		return x.CheckoutId
+	}
+	return ""
+}
+
+func (x *Shipment) GetCarrier() string {
+	if x != nil {
+		return x.Carrier
+	}
+	return ""
+}
+
+func (x *Shipment) GetTrackingNumber() string {
+	if x != nil {
+		return x.TrackingNumber
+	}
+	return ""
+}
+
+func (x *Shipment) GetShippedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ShippedAt
+	}
+	return nil
+}
+
+func (x *Shipment) GetDeliveredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeliveredAt
+	}
+	return nil
+}
+
+// Refund returns some or all of a captured payment to the customer.
+type Refund struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// refund_id is the unique identifier for this refund.
+	RefundId string `protobuf:"bytes,1,opt,name=refund_id,json=refundId,proto3" json:"refund_id,omitempty"`
+	// payment_id identifies the payment being refunded.
+	PaymentId string `protobuf:"bytes,2,opt,name=payment_id,json=paymentId,proto3" json:"payment_id,omitempty"`
+	// amount_cents is the amount returned by this refund.
+	AmountCents uint64 `protobuf:"varint,3,opt,name=amount_cents,json=amountCents,proto3" json:"amount_cents,omitempty"`
+	// payment_amount_cents is the amount_cents of the payment being refunded.
+	PaymentAmountCents uint64 `protobuf:"varint,4,opt,name=payment_amount_cents,json=paymentAmountCents,proto3" json:"payment_amount_cents,omitempty"`
+	// previously_refunded_cents is the total of earlier refunds of the same payment.
+	PreviouslyRefundedCents uint64 `protobuf:"varint,5,opt,name=previously_refunded_cents,json=previouslyRefundedCents,proto3" json:"previously_refunded_cents,omitempty"`
+	// reason explains why the refund was issued.
+	Reason        string `protobuf:"bytes,6,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Refund) Reset() {
+	*x = Refund{}
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Refund) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Refund) ProtoMessage() {}
+
+func (x *Refund) ProtoReflect() protoreflect.Message {
+	mi := &file_bufstream_demo_v1_lifecycle_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Refund.ProtoReflect.Descriptor instead.
+func (*Refund) Descriptor() ([]byte, []int) {
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Refund) GetRefundId() string {
+	if x != nil {
+		return x.RefundId
+	}
+	return ""
+}
+
+func (x *Refund) GetPaymentId() string {
+	if x != nil {
+		return x.PaymentId
+	}
+	return ""
+}
+
+func (x *Refund) GetAmountCents() uint64 {
+	if x != nil {
+		return x.AmountCents
+	}
+	return 0
+}
+
+func (x *Refund) GetPaymentAmountCents() uint64 {
+	if x != nil {
+		return x.PaymentAmountCents
+	}
+	return 0
+}
+
+func (x *Refund) GetPreviouslyRefundedCents() uint64 {
+	if x != nil {
+		return x.PreviouslyRefundedCents
+	}
+	return 0
+}
+
+func (x *Refund) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+var File_bufstream_demo_v1_lifecycle_proto protoreflect.FileDescriptor
+
+const file_bufstream_demo_v1_lifecycle_proto_rawDesc = "" +
+	"\n" +
+	"!bufstream/demo/v1/lifecycle.proto\x12\x11bufstream.demo.v1\x1a\x1bbuf/validate/validate.proto\x1a\x1fbufstream/demo/v1/options.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xfc\a\n" +
+	"\n" +
+	"OrderEvent\x12#\n" +
+	"\bevent_id\x18\x01 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\aeventId\x12#\n" +
+	"\border_id\x18\x02 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\aorderId\x12)\n" +
+	"\vcustomer_id\x18\x03 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\n" +
+	"customerId\x12C\n" +
+	"\voccurred_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampB\x06\xbaH\x03\xc8\x01\x01R\n" +
+	"occurredAt\x12N\n" +
+	"\x13customer_registered\x18\n" +
+	" \x01(\v2\x1b.bufstream.demo.v1.CustomerH\x00R\x12customerRegistered\x129\n" +
+	"\bcheckout\x18\v \x01(\v2\x1b.bufstream.demo.v1.CheckoutH\x00R\bcheckout\x126\n" +
+	"\apayment\x18\f \x01(\v2\x1a.bufstream.demo.v1.PaymentH\x00R\apayment\x129\n" +
+	"\bshipment\x18\r \x01(\v2\x1b.bufstream.demo.v1.ShipmentH\x00R\bshipment\x123\n" +
+	"\x06refund\x18\x0e \x01(\v2\x19.bufstream.demo.v1.RefundH\x00R\x06refund:\xf0\x03\xbaH\xec\x03\x1a\x9e\x01\n" +
+	"\x1dorder_event.checkout_customer\x127checkout customer_id must match the order's customer_id\x1aD!has(this.checkout) || this.checkout.customer_id == this.customer_id\x1a\x8d\x01\n" +
+	"\x1aorder_event.checkout_order\x120checkout cart_id must match the order's order_id\x1a=!has(this.checkout) || this.checkout.cart_id == this.order_id\x1a\xb8\x01\n" +
+	"\x1forder_event.customer_registered\x129registered customer_id must match the order's customer_id\x1aZ!has(this.customer_registered) || this.customer_registered.customer_id == this.customer_idB\x0e\n" +
+	"\x05event\x12\x05\xbaH\x02\b\x01\"|\n" +
+	"\bCustomer\x12)\n" +
+	"\vcustomer_id\x18\x01 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\n" +
+	"customerId\x12!\n" +
+	"\x05email\x18\x02 \x01(\tB\v\xbaH\x04r\x02`\x01\x80\xb5\x18\x01R\x05email\x12\"\n" +
+	"\x04name\x18\x03 \x01(\tB\x0e\xbaH\ar\x05\x10\x01\x18\xc8\x01\x80\xb5\x18\x01R\x04name\"\x9a\x05\n" +
+	"\bCheckout\x12)\n" +
+	"\vcheckout_id\x18\x01 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\n" +
+	"checkoutId\x12!\n" +
+	"\acart_id\x18\x02 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\x06cartId\x12)\n" +
+	"\vcustomer_id\x18\x03 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\n" +
+	"customerId\x12.\n" +
+	"\x0esubtotal_cents\x18\x04 \x01(\x04B\a\xbaH\x042\x02 \x00R\rsubtotalCents\x12%\n" +
+	"\x0ediscount_cents\x18\x05 \x01(\x04R\rdiscountCents\x12%\n" +
+	"\x0eshipping_cents\x18\x06 \x01(\x04R\rshippingCents\x12\x1b\n" +
+	"\ttax_cents\x18\a \x01(\x04R\btaxCents\x12(\n" +
+	"\vtotal_cents\x18\b \x01(\x04B\a\xbaH\x042\x02 \x00R\n" +
+	"totalCents:\xcf\x02\xbaH\xcb\x02\x1a{\n" +
+	"\x1echeckout.discount_lte_subtotal\x12-discount_cents must not exceed subtotal_cents\x1a*this.discount_cents <= this.subtotal_cents\x1a\xcb\x01\n" +
+	"\x0echeckout.total\x12Stotal_cents must equal subtotal_cents - discount_cents + shipping_cents + tax_cents\x1adthis.total_cents + this.discount_cents == this.subtotal_cents + this.shipping_cents + this.tax_cents\"\xd9\x03\n" +
+	"\aPayment\x12'\n" +
+	"\n" +
+	"payment_id\x18\x01 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\tpaymentId\x12)\n" +
+	"\vcheckout_id\x18\x02 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\n" +
+	"checkoutId\x12*\n" +
+	"\famount_cents\x18\x03 \x01(\x04B\a\xbaH\x042\x02 \x00R\vamountCents\x12D\n" +
+	"\x06method\x18\x04 \x01(\x0e2 .bufstream.demo.v1.PaymentMethodB\n" +
+	"\xbaH\a\x82\x01\x04\x10\x01 \x00R\x06method\x12D\n" +
+	"\x06status\x18\x05 \x01(\x0e2 .bufstream.demo.v1.PaymentStatusB\n" +
+	"\xbaH\a\x82\x01\x04\x10\x01 \x00R\x06status\x12/\n" +
+	"\x0efailure_reason\x18\x06 \x01(\tB\b\xbaH\x05r\x03\x18\xc8\x01R\rfailureReason:\x90\x01\xbaH\x8c\x01\x1a\x89\x01\n" +
+	"\x16payment.failure_reason\x12<failure_reason must be set if and only if the payment failed\x1a1(this.status == 2) == (this.failure_reason != '')\"\xe2\x03\n" +
+	"\bShipment\x12)\n" +
+	"\vshipment_id\x18\x01 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\n" +
+	"shipmentId\x12)\n" +
+	"\vcheckout_id\x18\x02 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\n" +
+	"checkoutId\x12#\n" +
+	"\acarrier\x18\x03 \x01(\tB\t\xbaH\x06r\x04\x10\x01\x182R\acarrier\x12@\n" +
+	"\x0ftracking_number\x18\x04 \x01(\tB\x17\xbaH\x14r\x122\x10^[A-Z0-9]{8,40}$R\x0etrackingNumber\x12A\n" +
+	"\n" +
+	"shipped_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampB\x06\xbaH\x03\xc8\x01\x01R\tshippedAt\x12=\n" +
+	"\fdelivered_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\vdeliveredAt:\x96\x01\xbaH\x92\x01\x1a\x8f\x01\n" +
+	" shipment.delivered_after_shipped\x12*delivered_at must not be before shipped_at\x1a?!has(this.delivered_at) || this.delivered_at >= this.shipped_at\"\xe3\x03\n" +
+	"\x06Refund\x12%\n" +
+	"\trefund_id\x18\x01 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\brefundId\x12'\n" +
+	"\n" +
+	"payment_id\x18\x02 \x01(\tB\b\xbaH\x05r\x03\xb0\x01\x01R\tpaymentId\x12*\n" +
+	"\famount_cents\x18\x03 \x01(\x04B\a\xbaH\x042\x02 \x00R\vamountCents\x129\n" +
+	"\x14payment_amount_cents\x18\x04 \x01(\x04B\a\xbaH\x042\x02 \x00R\x12paymentAmountCents\x12:\n" +
+	"\x19previously_refunded_cents\x18\x05 \x01(\x04R\x17previouslyRefundedCents\x12\"\n" +
+	"\x06reason\x18\x06 \x01(\tB\n" +
+	"\xbaH\ar\x05\x10\x01\x18\xc8\x01R\x06reason:\xc1\x01\xbaH\xbd\x01\x1a\xba\x01\n" +
+	"\x18refund.total_lte_payment\x12Mpreviously_refunded_cents + amount_cents must not exceed payment_amount_cents\x1aOthis.previously_refunded_cents + this.amount_cents <= this.payment_amount_cents*\x85\x01\n" +
+	"\rPaymentMethod\x12\x1e\n" +
+	"\x1aPAYMENT_METHOD_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13PAYMENT_METHOD_CARD\x10\x01\x12\x19\n" +
+	"\x15PAYMENT_METHOD_WALLET\x10\x02\x12 \n" +
+	"\x1cPAYMENT_METHOD_BANK_TRANSFER\x10\x03*g\n" +
+	"\rPaymentStatus\x12\x1e\n" +
+	"\x1aPAYMENT_STATUS_UNSPECIFIED\x10\x00\x12\x1b\n" +
+	"\x17PAYMENT_STATUS_CAPTURED\x10\x01\x12\x19\n" +
+	"\x15PAYMENT_STATUS_FAILED\x10\x02B\xce\x01\n" +
+	"\x15com.bufstream.demo.v1B\x0eLifecycleProtoP\x01Z?github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1;demov1\xa2\x02\x03BDX\xaa\x02\x11Bufstream.Demo.V1\xca\x02\x11Bufstream\\Demo\\V1\xe2\x02\x1dBufstream\\Demo\\V1\\GPBMetadata\xea\x02\x13Bufstream::Demo::V1b\x06proto3"
+
+var (
+	file_bufstream_demo_v1_lifecycle_proto_rawDescOnce sync.Once
+	file_bufstream_demo_v1_lifecycle_proto_rawDescData []byte
+)
+
+func file_bufstream_demo_v1_lifecycle_proto_rawDescGZIP() []byte {
+	file_bufstream_demo_v1_lifecycle_proto_rawDescOnce.Do(func() {
+		file_bufstream_demo_v1_lifecycle_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_bufstream_demo_v1_lifecycle_proto_rawDesc), len(file_bufstream_demo_v1_lifecycle_proto_rawDesc)))
+	})
+	return file_bufstream_demo_v1_lifecycle_proto_rawDescData
+}
+
+var file_bufstream_demo_v1_lifecycle_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_bufstream_demo_v1_lifecycle_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_bufstream_demo_v1_lifecycle_proto_goTypes = []any{
+	(PaymentMethod)(0),            // 0: bufstream.demo.v1.PaymentMethod
+	(PaymentStatus)(0),            // 1: bufstream.demo.v1.PaymentStatus
+	(*OrderEvent)(nil),            // 2: bufstream.demo.v1.OrderEvent
+	(*Customer)(nil),              // 3: bufstream.demo.v1.Customer
+	(*Checkout)(nil),              // 4: bufstream.demo.v1.Checkout
+	(*Payment)(nil),               // 5: bufstream.demo.v1.Payment
+	(*Shipment)(nil),              // 6: bufstream.demo.v1.Shipment
+	(*Refund)(nil),                // 7: bufstream.demo.v1.Refund
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+}
+var file_bufstream_demo_v1_lifecycle_proto_depIdxs = []int32{
+	8,  // 0: bufstream.demo.v1.OrderEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	3,  // 1: bufstream.demo.v1.OrderEvent.customer_registered:type_name -> bufstream.demo.v1.Customer
+	4,  // 2: bufstream.demo.v1.OrderEvent.checkout:type_name -> bufstream.demo.v1.Checkout
+	5,  // 3: bufstream.demo.v1.OrderEvent.payment:type_name -> bufstream.demo.v1.Payment
+	6,  // 4: bufstream.demo.v1.OrderEvent.shipment:type_name -> bufstream.demo.v1.Shipment
+	7,  // 5: bufstream.demo.v1.OrderEvent.refund:type_name -> bufstream.demo.v1.Refund
+	0,  // 6: bufstream.demo.v1.Payment.method:type_name -> bufstream.demo.v1.PaymentMethod
+	1,  // 7: bufstream.demo.v1.Payment.status:type_name -> bufstream.demo.v1.PaymentStatus
+	8,  // 8: bufstream.demo.v1.Shipment.shipped_at:type_name -> google.protobuf.Timestamp
+	8,  // 9: bufstream.demo.v1.Shipment.delivered_at:type_name -> google.protobuf.Timestamp
+	10, // [10:10] is the sub-list for method output_type
+	10, // [10:10] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_bufstream_demo_v1_lifecycle_proto_init() }
+func file_bufstream_demo_v1_lifecycle_proto_init() {
+	if File_bufstream_demo_v1_lifecycle_proto != nil {
+		return
+	}
+	file_bufstream_demo_v1_options_proto_init()
+	file_bufstream_demo_v1_lifecycle_proto_msgTypes[0].OneofWrappers = []any{
+		(*OrderEvent_CustomerRegistered)(nil),
+		(*OrderEvent_Checkout)(nil),
+		(*OrderEvent_Payment)(nil),
+		(*OrderEvent_Shipment)(nil),
+		(*OrderEvent_Refund)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bufstream_demo_v1_lifecycle_proto_rawDesc), len(file_bufstream_demo_v1_lifecycle_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_bufstream_demo_v1_lifecycle_proto_goTypes,
+		DependencyIndexes: file_bufstream_demo_v1_lifecycle_proto_depIdxs,
+		EnumInfos:         file_bufstream_demo_v1_lifecycle_proto_enumTypes,
+		MessageInfos:      file_bufstream_demo_v1_lifecycle_proto_msgTypes,
+	}.Build()
+	File_bufstream_demo_v1_lifecycle_proto = out.File
+	file_bufstream_demo_v1_lifecycle_proto_goTypes = nil
+	file_bufstream_demo_v1_lifecycle_proto_depIdxs = nil
+}
@@ -0,0 +1,91 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: bufstream/demo/v1/options.proto
+
+// Implements types for the Bufstream demo.
+
+package demov1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+var file_bufstream_demo_v1_options_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         50000,
+		Name:          "bufstream.demo.v1.sensitive",
+		Tag:           "varint,50000,opt,name=sensitive",
+		Filename:      "bufstream/demo/v1/options.proto",
+	},
+}
+
+// Extension fields to descriptorpb.FieldOptions.
+var (
+	// sensitive marks a field that holds sensitive data, such as personal information, that
+	// must not be shown to anyone who does not need it.
+	//
+	// Sensitive fields are redacted when messages are logged or tailed, and when topics are
+	// exported with --redact. See the redact package.
+	//
+	// optional bool sensitive = 50000;
+	E_Sensitive = &file_bufstream_demo_v1_options_proto_extTypes[0]
+)
+
+var File_bufstream_demo_v1_options_proto protoreflect.FileDescriptor
+
+const file_bufstream_demo_v1_options_proto_rawDesc = "" +
+	"\n" +
+	"\x1fbufstream/demo/v1/options.proto\x12\x11bufstream.demo.v1\x1a google/protobuf/descriptor.proto:=\n" +
+	"\tsensitive\x12\x1d.google.protobuf.FieldOptions\x18І\x03 \x01(\bR\tsensitiveB\xcc\x01\n" +
+	"\x15com.bufstream.demo.v1B\fOptionsProtoP\x01Z?github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1;demov1\xa2\x02\x03BDX\xaa\x02\x11Bufstream.Demo.V1\xca\x02\x11Bufstream\\Demo\\V1\xe2\x02\x1dBufstream\\Demo\\V1\\GPBMetadata\xea\x02\x13Bufstream::Demo::V1b\x06proto3"
+
+var file_bufstream_demo_v1_options_proto_goTypes = []any{
+	(*descriptorpb.FieldOptions)(nil), // 0: google.protobuf.FieldOptions
+}
+var file_bufstream_demo_v1_options_proto_depIdxs = []int32{
+	0, // 0: bufstream.demo.v1.sensitive:extendee -> google.protobuf.FieldOptions
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	0, // [0:1] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_bufstream_demo_v1_options_proto_init() }
+func file_bufstream_demo_v1_options_proto_init() {
+	if File_bufstream_demo_v1_options_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bufstream_demo_v1_options_proto_rawDesc), len(file_bufstream_demo_v1_options_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   0,
+			NumExtensions: 1,
+			NumServices:   0,
+		},
+		GoTypes:           file_bufstream_demo_v1_options_proto_goTypes,
+		DependencyIndexes: file_bufstream_demo_v1_options_proto_depIdxs,
+		ExtensionInfos:    file_bufstream_demo_v1_options_proto_extTypes,
+	}.Build()
+	File_bufstream_demo_v1_options_proto = out.File
+	file_bufstream_demo_v1_options_proto_goTypes = nil
+	file_bufstream_demo_v1_options_proto_depIdxs = nil
+}
@@ -0,0 +1,259 @@
+// Package aggregate implements windowed aggregation of carts into CategoryRevenue messages.
+//
+// Windows are based on record timestamps, not on when records are consumed, so replaying a
+// topic produces the same windows as consuming it live.
+package aggregate
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Windows describes the windows that records are aggregated into.
+//
+// If Slide equals Size, windows are tumbling: every record belongs to exactly one window.
+// If Slide is smaller than Size, windows are hopping: they overlap, and every record
+// belongs to Size/Slide windows.
+type Windows struct {
+	// Size is the length of each window.
+	Size time.Duration
+	// Slide is the time between the starts of consecutive windows.
+	Slide time.Duration
+	// AllowedLateness is how long to wait for late records after a window ends, before it is
+	// emitted. Records that arrive after their window was emitted are dropped.
+	AllowedLateness time.Duration
+}
+
+// Validate returns an error if the windows cannot be computed.
+func (w Windows) Validate() error {
+	if w.Size <= 0 {
+		return fmt.Errorf("invalid window size %v: must be positive", w.Size)
+	}
+	if w.Slide <= 0 || w.Slide > w.Size {
+		return fmt.Errorf("invalid window slide %v: must be positive and at most the window size %v", w.Slide, w.Size)
+	}
+	if w.Size%w.Slide != 0 {
+		return fmt.Errorf("invalid window slide %v: must divide the window size %v", w.Slide, w.Size)
+	}
+	if w.AllowedLateness < 0 {
+		return fmt.Errorf("invalid allowed lateness %v: must not be negative", w.AllowedLateness)
+	}
+	return nil
+}
+
+// LastStart returns the start of the last window that contains t. Windows complete in order
+// of their starts, so it is the last of t's windows to complete.
+func (w Windows) LastStart(t time.Time) time.Time {
+	return t.UTC().Truncate(w.Slide)
+}
+
+// starts returns the start of every window that contains t, earliest first.
+func (w Windows) starts(t time.Time) []time.Time {
+	latest := w.LastStart(t)
+	starts := make([]time.Time, 0, w.Size/w.Slide)
+	for start := latest.Add(-w.Size + w.Slide); !start.After(latest); start = start.Add(w.Slide) {
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// Aggregator aggregates carts into the revenue, units, and cart count of each category and
+// product, per window.
+//
+// The Aggregator tracks a watermark: the latest record timestamp it has seen. A window is
+// complete once the watermark passes its end plus the allowed lateness. An Aggregator is not
+// safe for concurrent use.
+type Aggregator struct {
+	windows   Windows
+	open      map[time.Time]map[string]*categoryTotals
+	watermark time.Time
+	late      int
+}
+
+// NewAggregator returns a new Aggregator.
+func NewAggregator(windows Windows) (*Aggregator, error) {
+	if err := windows.Validate(); err != nil {
+		return nil, err
+	}
+	return &Aggregator{
+		windows: windows,
+		open:    make(map[time.Time]map[string]*categoryTotals),
+	}, nil
+}
+
+// ErrLate is returned by Add for records whose windows have all been emitted.
+var ErrLate = errors.New("record is later than the allowed lateness")
+
+// Add adds a cart with the given record timestamp to every window that contains it, and
+// advances the watermark.
+//
+// If every window of the cart has already been emitted, the cart is dropped and ErrLate is
+// returned. If only some have, it is added to the others.
+func (a *Aggregator) Add(timestamp time.Time, cart *demov1.Cart) error {
+	added := false
+	for _, start := range a.windows.starts(timestamp) {
+		if a.isClosed(start) {
+			continue
+		}
+		categories, ok := a.open[start]
+		if !ok {
+			categories = make(map[string]*categoryTotals)
+			a.open[start] = categories
+		}
+		addCart(categories, cart)
+		added = true
+	}
+	if timestamp.After(a.watermark) {
+		a.watermark = timestamp
+	}
+	if !added {
+		a.late++
+		return ErrLate
+	}
+	return nil
+}
+
+// Watermark returns the latest record timestamp seen by Add.
+func (a *Aggregator) Watermark() time.Time {
+	return a.watermark
+}
+
+// Late returns the number of carts dropped by Add for being late.
+func (a *Aggregator) Late() int {
+	return a.late
+}
+
+// Complete removes and returns the CategoryRevenues of every window that the watermark has
+// passed, by more than the allowed lateness. Windows are ordered by start time, and
+// categories by category_id.
+func (a *Aggregator) Complete() []*demov1.CategoryRevenue {
+	return a.take(func(start time.Time) bool {
+		return a.isClosed(start)
+	})
+}
+
+// Flush removes and returns the CategoryRevenues of every window, complete or not, such as
+// when a bounded replay reaches its end.
+func (a *Aggregator) Flush() []*demov1.CategoryRevenue {
+	return a.take(func(time.Time) bool {
+		return true
+	})
+}
+
+// isClosed returns true if the window with the given start has been, or is ready to be,
+// emitted.
+func (a *Aggregator) isClosed(start time.Time) bool {
+	if a.watermark.IsZero() {
+		return false
+	}
+	end := start.Add(a.windows.Size)
+	return !a.watermark.Before(end.Add(a.windows.AllowedLateness))
+}
+
+func (a *Aggregator) take(shouldTake func(time.Time) bool) []*demov1.CategoryRevenue {
+	var categoryRevenues []*demov1.CategoryRevenue
+	for _, start := range slices.SortedFunc(maps.Keys(a.open), time.Time.Compare) {
+		if !shouldTake(start) {
+			continue
+		}
+		categories := a.open[start]
+		delete(a.open, start)
+		for _, categoryID := range slices.Sorted(maps.Keys(categories)) {
+			categoryRevenues = append(categoryRevenues, categories[categoryID].toProto(categoryID, start, start.Add(a.windows.Size)))
+		}
+	}
+	return categoryRevenues
+}
+
+// totals are the totals of a category or product in a window.
+type totals struct {
+	revenueCents uint64
+	units        uint64
+	cartCount    uint64
+}
+
+type categoryTotals struct {
+	totals
+	products map[string]*totals
+}
+
+func addCart(categories map[string]*categoryTotals, cart *demov1.Cart) {
+	// A cart counts once per category and product, however many of its lines they are on.
+	seenCategories := make(map[string]bool)
+	seenProducts := make(map[string]bool)
+	for _, lineItem := range cart.GetLineItems() {
+		categoryID := lineItem.GetProduct().GetCategory().GetId()
+		productID := lineItem.GetProduct().GetProductId()
+		revenueCents := lineItem.GetQuantity() * lineItem.GetUnitPriceCents()
+
+		category, ok := categories[categoryID]
+		if !ok {
+			category = &categoryTotals{products: make(map[string]*totals)}
+			categories[categoryID] = category
+		}
+		category.revenueCents += revenueCents
+		category.units += lineItem.GetQuantity()
+		if !seenCategories[categoryID] {
+			seenCategories[categoryID] = true
+			category.cartCount++
+		}
+
+		product, ok := category.products[productID]
+		if !ok {
+			product = &totals{}
+			category.products[productID] = product
+		}
+		product.revenueCents += revenueCents
+		product.units += lineItem.GetQuantity()
+		if !seenProducts[productID] {
+			seenProducts[productID] = true
+			product.cartCount++
+		}
+	}
+}
+
+func (c *categoryTotals) toProto(categoryID string, start time.Time, end time.Time) *demov1.CategoryRevenue {
+	categoryRevenue := &demov1.CategoryRevenue{
+		WindowStart:  timestamppb.New(start),
+		WindowEnd:    timestamppb.New(end),
+		CategoryId:   categoryID,
+		RevenueCents: c.revenueCents,
+		Units:        c.units,
+		CartCount:    c.cartCount,
+	}
+	for productID, product := range c.products {
+		categoryRevenue.Products = append(categoryRevenue.Products, &demov1.ProductRevenue{
+			ProductId:    productID,
+			RevenueCents: product.revenueCents,
+			Units:        product.units,
+			CartCount:    product.cartCount,
+		})
+	}
+	// Highest revenue first, so that the top sellers are easy to spot.
+	slices.SortFunc(categoryRevenue.Products, func(a, b *demov1.ProductRevenue) int {
+		if c := cmp.Compare(b.GetRevenueCents(), a.GetRevenueCents()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.GetProductId(), b.GetProductId())
+	})
+	return categoryRevenue
+}
+
+// TopicSpec returns the Spec of a topic of CategoryRevenue messages with the given name.
+func TopicSpec(name string) topics.Spec {
+	schema := string((&demov1.CategoryRevenue{}).ProtoReflect().Descriptor().FullName())
+	return topics.Spec{
+		Name: name,
+		Configs: map[string]*string{
+			topics.ValueSchemaMessageConfig: &schema,
+		},
+	}
+}
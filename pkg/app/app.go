@@ -1,7 +1,8 @@
 // Package app implements boilerplate code shared by the producer and consumer.
 //
 // It implements Main, which both the producer and consumer use within their main functions.
-// It also binds all relevant flags.
+// It also binds all relevant flags, and shuts down gracefully on SIGINT or SIGTERM: see
+// OnShutdown.
 package app
 
 import (
@@ -12,11 +13,13 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
 	"github.com/spf13/pflag"
 	"github.com/twmb/franz-go/pkg/kadm"
-	"github.com/twmb/franz-go/pkg/kerr"
 )
 
 const (
@@ -30,19 +33,40 @@ var (
 // Config contains all application configuration needed by the producer and consumer.
 type Config struct {
 	Kafka kafka.Config
+	// Start is the position to start consuming from. It is only bound as a flag by
+	// commands using [WithPositionFlags], and is zero if not set.
+	Start kafka.Position
+	// End is the position to stop consuming at. It is only bound as a flag by commands
+	// using [WithPositionFlags], and is zero if not set.
+	End kafka.Position
+	// ShutdownGracePeriod is how long the program has to shut down once it receives SIGINT or
+	// SIGTERM, before it exits regardless.
+	ShutdownGracePeriod time.Duration
+	// HealthAddr is the address to serve health checks and the log level on. If empty, they
+	// are not served. See [Health].
+	HealthAddr string
+	// LivenessTimeout is how long a loop may go without making progress before the program is
+	// considered stuck, for commands that register liveness checks.
+	LivenessTimeout time.Duration
 }
 
 // Main is used by the producer and consumer within their main functions.
 //
 // It sets up logging, interrupt handling, and binds and parses all flags. Afterwards, it calls
 // action to invoke the application logic.
-func Main(action func(context.Context, Config) error) {
-	doMain(false, action)
+//
+// On SIGINT or SIGTERM, the context passed to action is canceled. The action should stop
+// taking on new work and return, after which every function registered with [OnShutdown] is
+// called. If this takes longer than the shutdown grace period, the program exits anyway. A
+// second signal exits right away.
+func Main(action func(context.Context, Config) error, options ...MainOption) {
+	doMain(false, action, options...)
 }
 
 // MainAutoCreateTopic is used by the producer's main function. It is just like [Main] except
-// that it will also create the topic if necessary. The producer defines the topic and provides
-// the data, so the consumer should not be the one auto-creating it.
+// that it will also create the topic if necessary, and bring its partitions and configs in line
+// with the topic flags. The producer defines the topic and provides the data, so the consumer
+// should not be the one auto-creating it. See the topics package for how topics are provisioned.
 //
 // Note that in a real production workload, neither producer nor consumer applications should
 // ever create topics. This should be considered an infrastructure concern, and the topic
@@ -52,36 +76,106 @@ func Main(action func(context.Context, Config) error) {
 //
 // This demo workload creates the topic, despite it not being a typical good practice, just
 // for simplicity, so there are fewer steps to get the demo running.
-func MainAutoCreateTopic(action func(context.Context, Config) error) {
-	doMain(true, action)
+func MainAutoCreateTopic(action func(context.Context, Config) error, options ...MainOption) {
+	doMain(true, action, options...)
+}
+
+// MainOption is an option for [Main] and [MainAutoCreateTopic].
+type MainOption func(*mainOptions)
+
+// WithFlags returns a new MainOption that lets a command bind its own flags in addition
+// to the flags shared by all commands.
+//
+// bindFlags is called before flags are parsed, so any variables it binds will be populated
+// by the time action is invoked.
+func WithFlags(bindFlags func(*pflag.FlagSet)) MainOption {
+	return func(mainOptions *mainOptions) {
+		mainOptions.bindFlags = append(mainOptions.bindFlags, bindFlags)
+	}
 }
 
-func doMain(autoCreateTopic bool, action func(context.Context, Config) error) { // Set up slog. We use the global logger throughout this demo.
-	// Set up slog. We use the global logger throughout this demo.
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
-	// Cancel the context on interrupt, i.e. ctrl+c for our purposes.
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+// WithPositionFlags returns a new MainOption that binds the --start and --end flags to
+// Config.Start and Config.End, for commands that consume a range of a topic.
+func WithPositionFlags() MainOption {
+	return func(mainOptions *mainOptions) {
+		mainOptions.positionFlags = true
+	}
+}
+
+type mainOptions struct {
+	bindFlags     []func(*pflag.FlagSet)
+	positionFlags bool
+	// healthProbe is the path to probe instead of running the action, if set.
+	healthProbe string
+	logOptions  logOptions
+}
+
+func doMain(autoCreateTopic bool, action func(context.Context, Config) error, options ...MainOption) {
+	// Set up slog. We use the global logger throughout this demo. It is replaced according to
+	// the log flags once they are parsed.
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	// Cancel the context on interrupt, i.e. ctrl+c for our purposes, or on termination, such as
+	// by docker compose down or a Kubernetes rollout.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
-	if err := run(ctx, autoCreateTopic, action); err != nil {
+	go func() {
+		<-ctx.Done()
+		// Restore the default behavior, so that a second signal exits right away.
+		cancel()
+	}()
+	mainOptions := &mainOptions{}
+	for _, option := range options {
+		option(mainOptions)
+	}
+	if err := run(ctx, autoCreateTopic, action, mainOptions); err != nil {
 		slog.ErrorContext(ctx, "program error", "error", err)
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context, autoCreateTopic bool, action func(context.Context, Config) error) error {
-	config, err := parseConfig(autoCreateTopic)
+func run(ctx context.Context, autoCreateTopic bool, action func(context.Context, Config) error, mainOptions *mainOptions) error {
+	config, err := parseConfig(autoCreateTopic, mainOptions)
 	if err != nil {
 		return err
 	}
+	if mainOptions.healthProbe != "" {
+		return probeHealth(ctx, config.HealthAddr, mainOptions.healthProbe)
+	}
+	levelVar, err := setUpLogging(mainOptions.logOptions, config)
+	if err != nil {
+		return err
+	}
+	stopWatching := watchShutdown(ctx, config.ShutdownGracePeriod)
+	defer stopWatching()
+	lifecycle := &lifecycle{}
+	actionCtx := context.WithValue(ctx, lifecycleContextKey{}, lifecycle)
+	registry := newHealthRegistry(ctx)
+	actionCtx = context.WithValue(actionCtx, healthContextKey{}, registry)
+	if config.HealthAddr != "" {
+		err = serveHealth(actionCtx, config.HealthAddr, registry, logLevelHandler(levelVar))
+	}
+	if err == nil {
+		err = runAction(actionCtx, autoCreateTopic, action, config)
+	}
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		// Stopping on a signal is not an error.
+		err = nil
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.ShutdownGracePeriod)
+	defer cancel()
+	return errors.Join(err, lifecycle.shutdown(shutdownCtx))
+}
+
+func runAction(ctx context.Context, autoCreateTopic bool, action func(context.Context, Config) error, config Config) error {
 	if autoCreateTopic {
-		if err := maybeCreateTopic(ctx, config.Kafka); err != nil {
+		if err := provisionTopics(ctx, config.Kafka); err != nil {
 			return err
 		}
 	}
 	return action(ctx, config)
 }
 
-func parseConfig(canCreateTopic bool) (Config, error) {
+func parseConfig(canCreateTopic bool, mainOptions *mainOptions) (Config, error) {
 	flagSet := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
 	config := Config{}
 	flagSet.StringArrayVar(
@@ -107,19 +201,31 @@ func parseConfig(canCreateTopic bool) (Config, error) {
 			&config.Kafka.RecreateTopic,
 			"recreate-topic",
 			false,
-			"If true, the topic will be recreated even if it already exists.",
+			"If true, the topic will be recreated even if it already exists. Requires --confirm-delete.",
+		)
+		flagSet.BoolVar(
+			&config.Kafka.ConfirmDelete,
+			"confirm-delete",
+			false,
+			"Confirms that topics may be deleted, along with all of their data.",
 		)
 		flagSet.IntVar(
 			&config.Kafka.TopicPartitions,
 			"topic-partitions",
-			1,
-			"The number of partitions to use when creating the topic.",
+			0,
+			"The number of partitions the topic should have. Defaults to 1 when creating the topic.",
 		)
 		flagSet.StringSliceVar(
 			&config.Kafka.TopicConfig,
 			"topic-config",
 			nil,
-			"Topic config parameters to use when creating the topic.",
+			"Topic config parameters the topic should have.",
+		)
+		flagSet.StringVar(
+			&config.Kafka.TopicsFile,
+			"topics-file",
+			"",
+			"A YAML file declaring the topics to provision instead of --topic, --topic-partitions, and --topic-config.",
 		)
 	}
 	flagSet.StringVar(
@@ -128,67 +234,114 @@ func parseConfig(canCreateTopic bool) (Config, error) {
 		"",
 		"The Kafka consumer group ID.",
 	)
+	flagSet.StringVar(
+		(*string)(&config.Kafka.Compression),
+		"compression",
+		string(kafka.CompressionSnappy),
+		"The codec to compress produced record batches with: none, gzip, snappy, lz4, or zstd.",
+	)
+	flagSet.IntVar(
+		&config.Kafka.CompressionLevel,
+		"compression-level",
+		0,
+		"The level of --compression: 1-9 for gzip and lz4, and 1-4 for zstd. If 0, the codec's default level is used.",
+	)
 	flagSet.StringVar(
 		&config.Kafka.RootCAPath,
 		"tls-root-ca-path",
 		"",
 		"A path to root CA certificate for kafka TLS.",
 	)
+	flagSet.DurationVar(
+		&config.ShutdownGracePeriod,
+		"shutdown-grace-period",
+		defaultShutdownGracePeriod,
+		"How long to wait for in-flight work to finish on SIGINT or SIGTERM before exiting regardless.",
+	)
+	flagSet.StringVar(
+		&config.HealthAddr,
+		"health-addr",
+		"",
+		"The address to serve /healthz, /readyz, /livez, and /log-level on, such as :8081. If empty, they are not served.",
+	)
+	flagSet.DurationVar(
+		&config.LivenessTimeout,
+		"liveness-timeout",
+		defaultLivenessTimeout,
+		"How long the main loop may go without making progress before /livez fails.",
+	)
+	flagSet.StringVar(
+		&mainOptions.healthProbe,
+		"health-probe",
+		"",
+		"Instead of running, request this path, such as /readyz, from the instance serving --health-addr, and exit non-zero unless it succeeds.",
+	)
+	bindLogFlags(flagSet, &mainOptions.logOptions)
+	if mainOptions.positionFlags {
+		flagSet.Var(
+			&config.Start,
+			"start",
+			"The position to start consuming from: earliest, latest, an offset, partition:offset pairs, or an RFC 3339 timestamp.",
+		)
+		flagSet.Var(
+			&config.End,
+			"end",
+			"The position to stop consuming at, in the same forms as --start.",
+		)
+	}
+	for _, bind := range mainOptions.bindFlags {
+		bind(flagSet)
+	}
 	if err := flagSet.Parse(os.Args[1:]); err != nil {
 		return Config{}, err
 	}
 	return config, nil
 }
 
-func maybeCreateTopic(ctx context.Context, config kafka.Config) error {
+// provisionTopics provisions the producer's topics: either every topic declared in the
+// topics file, or just the configured topic.
+func provisionTopics(ctx context.Context, config kafka.Config) error {
 	client, err := kafka.NewKafkaClient(config, false)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	admClient := kadm.NewClient(client)
-	if config.RecreateTopic {
-		resp, err := admClient.DeleteTopic(ctx, config.Topic)
-		if err == nil {
-			err = resp.Err
-		}
-		if !isUnknownTopic(err) {
-			return err // something went wrong
+	var specs []topics.Spec
+	if config.TopicsFile != "" {
+		specs, err = topics.LoadFile(config.TopicsFile)
+		if err != nil {
+			return err
 		}
 	} else {
-		resp, err := admClient.DescribeTopicConfigs(ctx, config.Topic)
-		if err == nil {
-			if len(resp) != 1 {
-				return fmt.Errorf("expected 1 topic config, got %d", len(resp))
+		configs := make(map[string]*string, len(config.TopicConfig))
+		for _, conf := range config.TopicConfig {
+			k, v, _ := strings.Cut(conf, "=")
+			if v == "" {
+				configs[k] = nil
+			} else {
+				configs[k] = &v
 			}
-			err = resp[0].Err
-		}
-		if err == nil {
-			return nil // topic exists; nothing to create
-		}
-		if !isUnknownTopic(err) {
-			return err // something went wrong
 		}
-		// Else, topic does not exist, so we fall through to create it.
-	}
-	configs := make(map[string]*string, len(config.TopicConfig))
-	for _, conf := range config.TopicConfig {
-		k, v, _ := strings.Cut(conf, "=")
-		if v == "" {
-			configs[k] = nil
-		} else {
-			configs[k] = &v
-		}
-	}
-	resp, err := admClient.CreateTopic(ctx, int32(config.TopicPartitions), 1, configs, config.Topic)
-	if err == nil {
-		err = resp.Err
+		specs = []topics.Spec{{
+			Name:       config.Topic,
+			Partitions: int32(config.TopicPartitions),
+			Configs:    configs,
+			Recreate:   config.RecreateTopic,
+		}}
 	}
-	return err
-}
 
-func isUnknownTopic(err error) bool {
-	var kError *kerr.Error
-	return errors.As(err, &kError) && kError.Code == kerr.UnknownTopicOrPartition.Code
+	admClient := kadm.NewClient(client)
+	plan, err := topics.NewPlan(ctx, admClient, specs)
+	if err != nil {
+		return err
+	}
+	if len(plan.Changes) == 0 {
+		return nil
+	}
+	slog.InfoContext(ctx, "provisioning topics:\n"+plan.String())
+	if deletes := plan.Deletes(); len(deletes) > 0 && !config.ConfirmDelete {
+		return fmt.Errorf("refusing to delete topics %s without --confirm-delete", strings.Join(deletes, ", "))
+	}
+	return plan.Apply(ctx, admClient, config.ConfirmDelete)
 }
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/bufstream-demo/pkg/health"
+)
+
+const defaultLivenessTimeout = time.Minute
+
+// Health returns the health check Registry of the program, for commands to register their
+// readiness and liveness checks in. The checks are served on --health-addr, if it is set.
+//
+// ctx must be the context passed to the action, or derived from it.
+func Health(ctx context.Context) *health.Registry {
+	registry, ok := ctx.Value(healthContextKey{}).(*health.Registry)
+	if !ok {
+		panic("app.Health called with a context that was not passed to an action by app.Main")
+	}
+	return registry
+}
+
+type healthContextKey struct{}
+
+// newHealthRegistry returns a new Registry with a readiness check that fails once the program
+// starts shutting down, so that it is sent no more traffic.
+func newHealthRegistry(ctx context.Context) *health.Registry {
+	registry := health.NewRegistry()
+	registry.Register(health.Readiness, "shutdown", health.CheckerFunc(func(context.Context) error {
+		if ctx.Err() != nil {
+			return errors.New("shutting down")
+		}
+		return nil
+	}))
+	return registry
+}
+
+// serveHealth serves the checks of the registry on addr until the program has shut down,
+// along with the log level.
+func serveHealth(ctx context.Context, addr string, registry *health.Registry, logLevelHandler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on --health-addr %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", registry.Handler())
+	mux.Handle("/log-level", logLevelHandler)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.ErrorContext(ctx, "health server failed", "error", err)
+		}
+	}()
+	slog.InfoContext(ctx, "serving health checks", "addr", listener.Addr().String())
+	// Registered before the action runs, so that health checks are served until every other
+	// shutdown step is done.
+	OnShutdown(ctx, "stop health server", server.Shutdown)
+	return nil
+}
+
+// probeHealth requests the given path, such as /readyz, from the health server of another
+// instance of the program listening on addr, and returns an error unless it succeeds. This
+// lets container healthchecks probe the program without an HTTP client in the image.
+func probeHealth(ctx context.Context, addr string, path string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid --health-addr %s: %w", addr, err)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	url := "http://" + net.JoinHostPort(host, port) + "/" + strings.TrimPrefix(path, "/")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s:\n%s", url, response.Status, body)
+	}
+	return nil
+}
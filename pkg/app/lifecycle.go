@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultShutdownGracePeriod = 30 * time.Second
+
+// OnShutdown registers fn to be called once the action passed to [Main] returns, such as to
+// flush buffered records, commit offsets, leave a consumer group, or close a client.
+//
+// Shutdown functions are called in the reverse order of registration, like deferred calls, so
+// a client registered right after it is created is closed after everything that uses it. They
+// are called even if the action returns an error, with a context that is canceled once the
+// shutdown grace period is over. An error does not stop the remaining functions from being
+// called, and makes the program exit with a non-zero status.
+//
+// ctx must be the context passed to the action, or derived from it.
+func OnShutdown(ctx context.Context, name string, fn func(context.Context) error) {
+	lifecycle, ok := ctx.Value(lifecycleContextKey{}).(*lifecycle)
+	if !ok {
+		panic("app.OnShutdown called with a context that was not passed to an action by app.Main")
+	}
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+	lifecycle.hooks = append(lifecycle.hooks, shutdownHook{name: name, fn: fn})
+}
+
+type lifecycleContextKey struct{}
+
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+type lifecycle struct {
+	mu    sync.Mutex
+	hooks []shutdownHook
+}
+
+// shutdown calls every shutdown hook, most recently registered first.
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := slices.Clone(l.hooks)
+	l.hooks = nil
+	l.mu.Unlock()
+	var errs []error
+	for _, hook := range slices.Backward(hooks) {
+		slog.DebugContext(ctx, "running shutdown step", "step", hook.name)
+		if err := hook.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to %s: %w", hook.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// watchShutdown exits the program if it has not shut down within the grace period of ctx
+// being canceled by a signal. It returns a function to stop watching once the program has
+// shut down.
+func watchShutdown(ctx context.Context, gracePeriod time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		slog.InfoContext(ctx, "shutting down", "grace_period", gracePeriod)
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+			slog.ErrorContext(ctx, "did not shut down within the grace period, exiting", "grace_period", gracePeriod)
+			os.Exit(1)
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
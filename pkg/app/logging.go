@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bufbuild/bufstream-demo/pkg/logging"
+	"github.com/spf13/pflag"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+type logOptions struct {
+	format           string
+	level            string
+	sampleFirst      int
+	sampleThereafter int
+}
+
+func bindLogFlags(flagSet *pflag.FlagSet, logOptions *logOptions) {
+	flagSet.StringVar(
+		&logOptions.format,
+		"log-format",
+		logFormatText,
+		"The format of log lines: text or json.",
+	)
+	flagSet.StringVar(
+		&logOptions.level,
+		"log-level",
+		"info",
+		"The minimum level of log lines: debug, info, warn, or error. It can be changed at runtime on --health-addr, see /log-level.",
+	)
+	flagSet.IntVar(
+		&logOptions.sampleFirst,
+		"log-sample-first",
+		0,
+		"If positive, only log this many lines with the same message per second, and then every --log-sample-thereafter-th. Warnings and errors are never sampled.",
+	)
+	flagSet.IntVar(
+		&logOptions.sampleThereafter,
+		"log-sample-thereafter",
+		100,
+		"With --log-sample-first, log every this many lines with the same message after the first ones in a second. If 0, drop them all.",
+	)
+}
+
+// setUpLogging sets the default logger according to the log flags, and returns the level it
+// logs at, which can be changed at runtime.
+//
+// Every line includes the client ID, and the consumer group if there is one. Consumers and
+// producers add the topic, partition, and offset of the record at hand, see the logging
+// package.
+func setUpLogging(logOptions logOptions, config Config) (*slog.LevelVar, error) {
+	level, err := logging.ParseLevel(logOptions.level)
+	if err != nil {
+		return nil, err
+	}
+	levelVar := &slog.LevelVar{}
+	levelVar.Set(level)
+	handlerOptions := &slog.HandlerOptions{Level: levelVar}
+	var handler slog.Handler
+	switch logOptions.format {
+	case logFormatText:
+		handler = slog.NewTextHandler(os.Stderr, handlerOptions)
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(os.Stderr, handlerOptions)
+	default:
+		return nil, fmt.Errorf("invalid --log-format %q: must be %s or %s", logOptions.format, logFormatText, logFormatJSON)
+	}
+	if logOptions.sampleFirst > 0 {
+		handler = logging.NewSamplingHandler(handler, logging.Sampling{
+			First:      logOptions.sampleFirst,
+			Thereafter: logOptions.sampleThereafter,
+			Interval:   time.Second,
+		})
+	}
+	logger := slog.New(logging.NewContextHandler(handler)).With("client_id", config.Kafka.ClientID)
+	if config.Kafka.Group != "" {
+		logger = logger.With("group", config.Kafka.Group)
+	}
+	slog.SetDefault(logger)
+	return levelVar, nil
+}
+
+// logLevelHandler serves the level of the default logger: GET returns it, and PUT sets it to
+// the level in the request body, such as:
+//
+//	curl -X PUT -d debug localhost:8081/log-level
+func logLevelHandler(levelVar *slog.LevelVar) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /log-level", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, levelVar.Level())
+	})
+	mux.HandleFunc("PUT /log-level", func(w http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(request.Body, 64))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		level, err := logging.ParseLevel(string(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		previous := levelVar.Level()
+		levelVar.Set(level)
+		slog.WarnContext(request.Context(), "changed log level", "from", previous, "to", level)
+		fmt.Fprintln(w, level)
+	})
+	return mux
+}
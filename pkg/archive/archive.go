@@ -0,0 +1,183 @@
+// Package archive implements reading and writing Kafka records to local files.
+//
+// Records are stored as Bufstream's dlqv1beta1.Record message, which already captures
+// everything we need to faithfully reproduce a record: its key, value, headers, partition,
+// and timestamp. Records can be stored in one of two formats:
+//
+//   - FormatJSONL: one protojson-encoded Record per line. Keys and values are base64-encoded.
+//   - FormatDelimited: size-delimited binary Records, as written by protodelim.
+//
+// JSONL files are easy to inspect and edit by hand, which is handy for test fixtures.
+// Delimited files are smaller and faster to read and write.
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	dlqv1beta1 "buf.build/gen/go/bufbuild/bufstream/protocolbuffers/go/buf/bufstream/dlq/v1beta1"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxRecordSize is the largest encoded record we accept when reading.
+const maxRecordSize = 64 * 1024 * 1024
+
+// Format is a file format for archived records.
+type Format string
+
+const (
+	// FormatJSONL stores one protojson-encoded record per line.
+	FormatJSONL Format = "jsonl"
+	// FormatDelimited stores size-delimited binary records.
+	FormatDelimited Format = "delimited"
+)
+
+// ParseFormat returns the Format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatJSONL, FormatDelimited:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown archive format %q, expected %q or %q", name, FormatJSONL, FormatDelimited)
+	}
+}
+
+// Writer writes records to an io.Writer in a given Format.
+//
+// Writes are buffered: always call Flush when done.
+type Writer struct {
+	writer *bufio.Writer
+	format Format
+}
+
+// NewWriter returns a new Writer.
+func NewWriter(writer io.Writer, format Format) *Writer {
+	return &Writer{
+		writer: bufio.NewWriter(writer),
+		format: format,
+	}
+}
+
+// Write writes a single record.
+func (w *Writer) Write(record *kgo.Record) error {
+	archived := toArchived(record)
+	switch w.format {
+	case FormatJSONL:
+		data, err := protojson.Marshal(archived)
+		if err != nil {
+			return err
+		}
+		if _, err := w.writer.Write(data); err != nil {
+			return err
+		}
+		return w.writer.WriteByte('\n')
+	case FormatDelimited:
+		_, err := protodelim.MarshalTo(w.writer, archived)
+		return err
+	default:
+		return fmt.Errorf("unknown archive format %q", w.format)
+	}
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	return w.writer.Flush()
+}
+
+// Reader reads records from an io.Reader in a given Format.
+type Reader struct {
+	reader  *bufio.Reader
+	scanner *bufio.Scanner
+	format  Format
+}
+
+// NewReader returns a new Reader.
+func NewReader(reader io.Reader, format Format) *Reader {
+	r := &Reader{
+		format: format,
+	}
+	if format == FormatJSONL {
+		r.scanner = bufio.NewScanner(reader)
+		r.scanner.Buffer(nil, maxRecordSize)
+	} else {
+		r.reader = bufio.NewReader(reader)
+	}
+	return r
+}
+
+// Read reads the next record. The record's Topic is the topic it was exported from.
+//
+// Read returns io.EOF when there are no more records.
+func (r *Reader) Read() (*kgo.Record, error) {
+	archived := &dlqv1beta1.Record{}
+	switch r.format {
+	case FormatJSONL:
+		for {
+			if !r.scanner.Scan() {
+				if err := r.scanner.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			if len(r.scanner.Bytes()) > 0 {
+				break
+			}
+		}
+		if err := protojson.Unmarshal(r.scanner.Bytes(), archived); err != nil {
+			return nil, err
+		}
+	case FormatDelimited:
+		if err := (protodelim.UnmarshalOptions{MaxSize: maxRecordSize}).UnmarshalFrom(r.reader, archived); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", r.format)
+	}
+	return fromArchived(archived), nil
+}
+
+func toArchived(record *kgo.Record) *dlqv1beta1.Record {
+	archived := &dlqv1beta1.Record{
+		TopicName: record.Topic,
+		Partition: record.Partition,
+		Key:       record.Key,
+		Value:     record.Value,
+	}
+	if !record.Timestamp.IsZero() {
+		archived.Timestamp = timestamppb.New(record.Timestamp)
+	}
+	for _, header := range record.Headers {
+		archived.Headers = append(archived.Headers, &dlqv1beta1.RecordHeader{
+			Key:   header.Key,
+			Value: header.Value,
+		})
+	}
+	return archived
+}
+
+func fromArchived(archived *dlqv1beta1.Record) *kgo.Record {
+	record := &kgo.Record{
+		Topic:     archived.GetTopicName(),
+		Partition: archived.GetPartition(),
+		Key:       archived.GetKey(),
+		Value:     archived.GetValue(),
+	}
+	if archived.HasTimestamp() {
+		record.Timestamp = archived.GetTimestamp().AsTime()
+	}
+	for _, header := range archived.GetHeaders() {
+		record.Headers = append(record.Headers, kgo.RecordHeader{
+			Key:   header.GetKey(),
+			Value: header.GetValue(),
+		})
+	}
+	return record
+}
@@ -0,0 +1,393 @@
+// Package compat checks that new versions of the demo's Protobuf schemas can still read
+// records written with old versions, and that old versions can read new records.
+//
+// A snapshot of the schemas is saved for every released version with WriteSnapshot. Diff
+// then compares a snapshot with the current schemas, and reports every change to a
+// message or field, and whether it breaks the wire format.
+package compat
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind string
+
+const (
+	// ChangeMessageAdded is a message that only exists in the new schemas.
+	ChangeMessageAdded ChangeKind = "message added"
+	// ChangeMessageRemoved is a message that only exists in the old schemas.
+	ChangeMessageRemoved ChangeKind = "message removed"
+	// ChangeFieldAdded is a field that only exists in the new version of a message.
+	ChangeFieldAdded ChangeKind = "field added"
+	// ChangeFieldRemoved is a field that only exists in the old version of a message.
+	ChangeFieldRemoved ChangeKind = "field removed"
+	// ChangeFieldRenamed is a field whose name changed but whose number did not.
+	ChangeFieldRenamed ChangeKind = "field renamed"
+	// ChangeFieldTypeChanged is a field whose type changed.
+	ChangeFieldTypeChanged ChangeKind = "field type changed"
+	// ChangeFieldCardinalityChanged is a field that changed between singular and repeated.
+	ChangeFieldCardinalityChanged ChangeKind = "field cardinality changed"
+	// ChangeFieldOneofChanged is a field that moved into, out of, or between oneofs.
+	ChangeFieldOneofChanged ChangeKind = "field oneof changed"
+	// ChangeEnumValueRemoved is an enum value that only exists in the old schemas.
+	ChangeEnumValueRemoved ChangeKind = "enum value removed"
+)
+
+// Change is a difference between two versions of a message.
+type Change struct {
+	Kind ChangeKind
+	// Message is the full name of the changed message or enum.
+	Message protoreflect.FullName
+	// Field is the number of the changed field, or the changed enum value, if any.
+	Field protoreflect.FieldNumber
+	// Old and New describe the message or field before and after the change.
+	Old string
+	New string
+	// Breaking is true if records written with one version can be misread by the other.
+	Breaking bool
+}
+
+// String returns a one-line description of the change, such as
+// "bufstream.demo.v1.LineItem field 3: field type changed: uint64 quantity -> string quantity (breaking)".
+func (c Change) String() string {
+	var builder strings.Builder
+	builder.WriteString(string(c.Message))
+	if c.Field != 0 {
+		fmt.Fprintf(&builder, " field %d", c.Field)
+	}
+	fmt.Fprintf(&builder, ": %s", c.Kind)
+	switch {
+	case c.Old != "" && c.New != "":
+		fmt.Fprintf(&builder, ": %s -> %s", c.Old, c.New)
+	case c.Old != "":
+		fmt.Fprintf(&builder, ": %s", c.Old)
+	case c.New != "":
+		fmt.Fprintf(&builder, ": %s", c.New)
+	}
+	if c.Breaking {
+		builder.WriteString(" (breaking)")
+	}
+	return builder.String()
+}
+
+// Diff returns every change to the messages and enums of the given package between the old
+// and new files, ordered by message and field.
+func Diff(old, new *protoregistry.Files, pkg protoreflect.FullName) []Change {
+	oldMessages, oldEnums := collect(old, pkg)
+	newMessages, newEnums := collect(new, pkg)
+	var changes []Change
+	for name, oldMessage := range oldMessages {
+		newMessage, ok := newMessages[name]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeMessageRemoved, Message: name, Breaking: true})
+			continue
+		}
+		changes = append(changes, DiffMessage(oldMessage, newMessage)...)
+	}
+	for name := range newMessages {
+		if _, ok := oldMessages[name]; !ok {
+			changes = append(changes, Change{Kind: ChangeMessageAdded, Message: name})
+		}
+	}
+	for name, oldEnum := range oldEnums {
+		newEnum, ok := newEnums[name]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeMessageRemoved, Message: name, Breaking: true})
+			continue
+		}
+		changes = append(changes, diffEnum(oldEnum, newEnum)...)
+	}
+	slices.SortFunc(changes, func(a, b Change) int {
+		if c := strings.Compare(string(a.Message), string(b.Message)); c != 0 {
+			return c
+		}
+		return int(a.Field) - int(b.Field)
+	})
+	return changes
+}
+
+// DiffMessage returns every change to the fields of a message between its old and new
+// versions.
+//
+// Removing a field is breaking unless its number is reserved, so that it cannot be reused
+// with a different meaning. Renaming a field keeps the wire format, but breaks JSON.
+func DiffMessage(old, new protoreflect.MessageDescriptor) []Change {
+	var changes []Change
+	oldFields := old.Fields()
+	for i := 0; i < oldFields.Len(); i++ {
+		oldField := oldFields.Get(i)
+		newField := new.Fields().ByNumber(oldField.Number())
+		if newField == nil {
+			changes = append(changes, Change{
+				Kind:     ChangeFieldRemoved,
+				Message:  old.FullName(),
+				Field:    oldField.Number(),
+				Old:      describeField(oldField),
+				Breaking: !new.ReservedRanges().Has(oldField.Number()),
+			})
+			continue
+		}
+		changes = append(changes, diffField(old.FullName(), oldField, newField)...)
+	}
+	newFields := new.Fields()
+	for i := 0; i < newFields.Len(); i++ {
+		newField := newFields.Get(i)
+		if oldFields.ByNumber(newField.Number()) == nil {
+			changes = append(changes, Change{
+				Kind:     ChangeFieldAdded,
+				Message:  new.FullName(),
+				Field:    newField.Number(),
+				New:      describeField(newField),
+				Breaking: old.ReservedRanges().Has(newField.Number()),
+			})
+		}
+	}
+	return changes
+}
+
+// LoadSnapshot loads a snapshot written with WriteSnapshot.
+//
+// Files that a snapshot imports but does not contain, such as buf/validate/validate.proto,
+// are resolved from the global registry.
+func LoadSnapshot(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fileDescriptorSet := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, fileDescriptorSet); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	files := new(protoregistry.Files)
+	resolver := &fallbackResolver{primary: files, fallback: protoregistry.GlobalFiles}
+	for _, fileDescriptorProto := range fileDescriptorSet.GetFile() {
+		file, err := protodesc.NewFile(fileDescriptorProto, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s from snapshot %s: %w", fileDescriptorProto.GetName(), path, err)
+		}
+		if err := files.RegisterFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// WriteSnapshot writes the files of the given package from the global registry to a
+// snapshot.
+func WriteSnapshot(path string, pkg protoreflect.FullName) error {
+	var fileDescriptorProtos []*descriptorpb.FileDescriptorProto
+	protoregistry.GlobalFiles.RangeFilesByPackage(pkg, func(file protoreflect.FileDescriptor) bool {
+		fileDescriptorProtos = append(fileDescriptorProtos, protodesc.ToFileDescriptorProto(file))
+		return true
+	})
+	if len(fileDescriptorProtos) == 0 {
+		return fmt.Errorf("no files in package %s", pkg)
+	}
+	// Files are loaded in order, so files must come after the files they import.
+	fileDescriptorProtos = sortByImports(fileDescriptorProtos)
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(&descriptorpb.FileDescriptorSet{File: fileDescriptorProtos})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func diffField(message protoreflect.FullName, old, new protoreflect.FieldDescriptor) []Change {
+	var changes []Change
+	change := func(kind ChangeKind, breaking bool) {
+		changes = append(changes, Change{
+			Kind:     kind,
+			Message:  message,
+			Field:    old.Number(),
+			Old:      describeField(old),
+			New:      describeField(new),
+			Breaking: breaking,
+		})
+	}
+	if old.Name() != new.Name() {
+		change(ChangeFieldRenamed, false)
+	}
+	if old.Kind() != new.Kind() || typeName(old) != typeName(new) {
+		change(ChangeFieldTypeChanged, !isWireCompatible(old, new))
+	}
+	if old.Cardinality() != new.Cardinality() {
+		change(ChangeFieldCardinalityChanged, old.IsList() != new.IsList() || old.IsMap() != new.IsMap())
+	}
+	if oneofName(old) != oneofName(new) {
+		// Moving a field into a oneof can silently clear other fields of the oneof.
+		change(ChangeFieldOneofChanged, new.ContainingOneof() != nil && !new.ContainingOneof().IsSynthetic())
+	}
+	return changes
+}
+
+func diffEnum(old, new protoreflect.EnumDescriptor) []Change {
+	var changes []Change
+	oldValues := old.Values()
+	for i := 0; i < oldValues.Len(); i++ {
+		oldValue := oldValues.Get(i)
+		if new.Values().ByNumber(oldValue.Number()) == nil {
+			changes = append(changes, Change{
+				Kind:     ChangeEnumValueRemoved,
+				Message:  old.FullName(),
+				Field:    protoreflect.FieldNumber(oldValue.Number()),
+				Old:      string(oldValue.Name()),
+				Breaking: !new.ReservedRanges().Has(oldValue.Number()),
+			})
+		}
+	}
+	return changes
+}
+
+// wireGroups are the groups of field kinds that share an encoding, so that a field can
+// change between kinds in the same group without breaking the wire format.
+var wireGroups = [][]protoreflect.Kind{
+	{protoreflect.Int32Kind, protoreflect.Uint32Kind, protoreflect.Int64Kind, protoreflect.Uint64Kind, protoreflect.BoolKind, protoreflect.EnumKind},
+	{protoreflect.Sint32Kind, protoreflect.Sint64Kind},
+	{protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind},
+	{protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind},
+	{protoreflect.StringKind, protoreflect.BytesKind},
+}
+
+// isWireCompatible returns true if a field can change from the old to the new type without
+// breaking the wire format. Messages are only compatible with themselves, since a message
+// of another type is unlikely to mean the same thing.
+func isWireCompatible(old, new protoreflect.FieldDescriptor) bool {
+	if old.Kind() == new.Kind() {
+		return typeName(old) == typeName(new) || old.Kind() == protoreflect.EnumKind
+	}
+	for _, group := range wireGroups {
+		if slices.Contains(group, old.Kind()) && slices.Contains(group, new.Kind()) {
+			return true
+		}
+	}
+	return false
+}
+
+// collect returns the messages and enums of the given package, including nested ones.
+func collect(files *protoregistry.Files, pkg protoreflect.FullName) (map[protoreflect.FullName]protoreflect.MessageDescriptor, map[protoreflect.FullName]protoreflect.EnumDescriptor) {
+	messages := make(map[protoreflect.FullName]protoreflect.MessageDescriptor)
+	enums := make(map[protoreflect.FullName]protoreflect.EnumDescriptor)
+	var collectEnums func(protoreflect.EnumDescriptors)
+	collectEnums = func(descriptors protoreflect.EnumDescriptors) {
+		for i := 0; i < descriptors.Len(); i++ {
+			enums[descriptors.Get(i).FullName()] = descriptors.Get(i)
+		}
+	}
+	var collectMessages func(protoreflect.MessageDescriptors)
+	collectMessages = func(descriptors protoreflect.MessageDescriptors) {
+		for i := 0; i < descriptors.Len(); i++ {
+			message := descriptors.Get(i)
+			if message.IsMapEntry() {
+				continue
+			}
+			messages[message.FullName()] = message
+			collectMessages(message.Messages())
+			collectEnums(message.Enums())
+		}
+	}
+	files.RangeFilesByPackage(pkg, func(file protoreflect.FileDescriptor) bool {
+		collectMessages(file.Messages())
+		collectEnums(file.Enums())
+		return true
+	})
+	return messages, enums
+}
+
+// describeField returns a description of a field such as "repeated string sku".
+func describeField(field protoreflect.FieldDescriptor) string {
+	var builder strings.Builder
+	if field.IsList() {
+		builder.WriteString("repeated ")
+	}
+	builder.WriteString(typeName(field))
+	builder.WriteByte(' ')
+	builder.WriteString(string(field.Name()))
+	if oneof := oneofName(field); oneof != "" {
+		fmt.Fprintf(&builder, " in oneof %s", oneof)
+	}
+	return builder.String()
+}
+
+// typeName returns the name of the type of a field: a scalar kind, or a full message or
+// enum name.
+func typeName(field protoreflect.FieldDescriptor) string {
+	switch {
+	case field.IsMap():
+		return fmt.Sprintf("map<%s, %s>", typeName(field.MapKey()), typeName(field.MapValue()))
+	case field.Message() != nil:
+		return string(field.Message().FullName())
+	case field.Enum() != nil:
+		return string(field.Enum().FullName())
+	default:
+		return field.Kind().String()
+	}
+}
+
+func oneofName(field protoreflect.FieldDescriptor) protoreflect.Name {
+	if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+		return oneof.Name()
+	}
+	return ""
+}
+
+func sortByImports(fileDescriptorProtos []*descriptorpb.FileDescriptorProto) []*descriptorpb.FileDescriptorProto {
+	byName := make(map[string]*descriptorpb.FileDescriptorProto, len(fileDescriptorProtos))
+	for _, fileDescriptorProto := range fileDescriptorProtos {
+		byName[fileDescriptorProto.GetName()] = fileDescriptorProto
+	}
+	slices.SortFunc(fileDescriptorProtos, func(a, b *descriptorpb.FileDescriptorProto) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	sorted := make([]*descriptorpb.FileDescriptorProto, 0, len(fileDescriptorProtos))
+	added := make(map[string]bool, len(fileDescriptorProtos))
+	var add func(*descriptorpb.FileDescriptorProto)
+	add = func(fileDescriptorProto *descriptorpb.FileDescriptorProto) {
+		if added[fileDescriptorProto.GetName()] {
+			return
+		}
+		added[fileDescriptorProto.GetName()] = true
+		for _, dependency := range fileDescriptorProto.GetDependency() {
+			if imported, ok := byName[dependency]; ok {
+				add(imported)
+			}
+		}
+		sorted = append(sorted, fileDescriptorProto)
+	}
+	for _, fileDescriptorProto := range fileDescriptorProtos {
+		add(fileDescriptorProto)
+	}
+	return sorted
+}
+
+// fallbackResolver resolves descriptors from primary, and then from fallback.
+type fallbackResolver struct {
+	primary  *protoregistry.Files
+	fallback *protoregistry.Files
+}
+
+func (r *fallbackResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	file, err := r.primary.FindFileByPath(path)
+	if errors.Is(err, protoregistry.NotFound) {
+		return r.fallback.FindFileByPath(path)
+	}
+	return file, err
+}
+
+func (r *fallbackResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	descriptor, err := r.primary.FindDescriptorByName(name)
+	if errors.Is(err, protoregistry.NotFound) {
+		return r.fallback.FindDescriptorByName(name)
+	}
+	return descriptor, err
+}
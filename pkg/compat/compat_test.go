@@ -0,0 +1,354 @@
+package compat
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"buf.build/go/protovalidate"
+	// Registers the demo's messages.
+	_ "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Run with -snapshot=<version> after releasing a new version of the schemas, to save them
+// and the current fixtures to testdata/<version>. Existing versions are never overwritten.
+var snapshot = flag.String("snapshot", "", "write a snapshot of the current schemas and fixtures to testdata/<version>")
+
+const (
+	demoPackage  = "bufstream.demo.v1"
+	schemaFile   = "schema.binpb"
+	manifestFile = "fixtures.json"
+)
+
+// fixture is a record written with one version of the schemas.
+type fixture struct {
+	// Name is the name of the fixture, and of its file without the .binpb extension.
+	Name string `json:"name"`
+	// Message is the full name of the record's message.
+	Message string `json:"message"`
+	// Violations are the validation violations of the record, as "field.path: rule_id".
+	Violations []string `json:"violations,omitempty"`
+	// UnknownFields is true if the record has fields that are unknown to its version, as if
+	// it was written by a newer version.
+	UnknownFields bool `json:"unknown_fields,omitempty"`
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *snapshot != "" {
+		if err := writeSnapshot(filepath.Join("testdata", *snapshot)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+// TestSchemaCompatibility fails if the current schemas break the wire format of any
+// snapshot.
+func TestSchemaCompatibility(t *testing.T) {
+	t.Parallel()
+	for _, version := range versions(t) {
+		t.Run(version, func(t *testing.T) {
+			t.Parallel()
+			old, err := LoadSnapshot(filepath.Join("testdata", version, schemaFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var breaking []string
+			for _, change := range Diff(old, protoregistry.GlobalFiles, demoPackage) {
+				if change.Breaking {
+					breaking = append(breaking, change.String())
+				} else {
+					t.Log(change.String())
+				}
+			}
+			if len(breaking) > 0 {
+				t.Errorf("schemas are not wire-compatible with %s:\n  %s", version, strings.Join(breaking, "\n  "))
+			}
+		})
+	}
+}
+
+// TestFixtures decodes every fixture with the current generated types, and checks that
+// the record validates as it did when it was written, that no fields are lost, and that
+// the version that wrote it can still read it after the current version writes it back.
+func TestFixtures(t *testing.T) {
+	t.Parallel()
+	for _, version := range versions(t) {
+		old, err := LoadSnapshot(filepath.Join("testdata", version, schemaFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fixtures []fixture
+		readJSON(t, filepath.Join("testdata", version, manifestFile), &fixtures)
+		for _, fixture := range fixtures {
+			t.Run(version+"/"+fixture.Name, func(t *testing.T) {
+				t.Parallel()
+				data, err := os.ReadFile(filepath.Join("testdata", version, fixture.Name+".binpb"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				testFixture(t, old, fixture, data)
+			})
+		}
+	}
+}
+
+func testFixture(t *testing.T, old *protoregistry.Files, fixture fixture, data []byte) {
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(fixture.Message))
+	if err != nil {
+		t.Fatalf("message %s no longer exists: %v", fixture.Message, err)
+	}
+	message := messageType.New().Interface()
+	if err := proto.Unmarshal(data, message); err != nil {
+		t.Fatalf("failed to decode with the current schema: %v", err)
+	}
+
+	if diff := diffLines(fixture.Violations, violations(t, message)); diff != "" {
+		t.Errorf("validation outcome changed (-written, +current):\n%s", diff)
+	}
+	if fixture.UnknownFields && !hasUnknownFields(message.ProtoReflect()) {
+		t.Error("unknown fields were not preserved")
+	}
+
+	// Writing the record back must not lose any fields, known or unknown.
+	written, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTripped := messageType.New().Interface()
+	if err := proto.Unmarshal(written, roundTripped); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(message, roundTripped) {
+		t.Errorf("fields were lost when writing with the current schema:\n  before: %v\n  after:  %v", message, roundTripped)
+	}
+
+	// The version that wrote the record must read it back as it wrote it.
+	oldDescriptor, err := old.FindDescriptorByName(protoreflect.FullName(fixture.Message))
+	if err != nil {
+		t.Fatalf("message %s is not in the snapshot: %v", fixture.Message, err)
+	}
+	oldMessageDescriptor, ok := oldDescriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		t.Fatalf("%s is not a message in the snapshot", fixture.Message)
+	}
+	original := dynamicpb.NewMessage(oldMessageDescriptor)
+	if err := proto.Unmarshal(data, original); err != nil {
+		t.Fatalf("failed to decode with the snapshot schema: %v", err)
+	}
+	readBack := dynamicpb.NewMessage(oldMessageDescriptor)
+	if err := proto.Unmarshal(written, readBack); err != nil {
+		t.Fatalf("the snapshot schema cannot decode the record written with the current schema: %v", err)
+	}
+	if !proto.Equal(original, readBack) {
+		t.Errorf("the snapshot schema reads the record differently after the current schema writes it:\n  before: %v\n  after:  %v", original, readBack)
+	}
+}
+
+// source is a fixture to write with -snapshot, read from testdata/sources.
+type source struct {
+	// Message is the full name of the record's message.
+	Message string `json:"message"`
+	// Value is the record in protojson form.
+	Value json.RawMessage `json:"value"`
+	// AppendUnknownField appends a field that no version knows about to the record, as if it
+	// was written by a newer version.
+	AppendUnknownField bool `json:"append_unknown_field,omitempty"`
+}
+
+// unknownFieldNumber is the number of the field appended to sources with
+// append_unknown_field. It must never be used by the schemas.
+const unknownFieldNumber = 100000
+
+// readSources reads the records to write with -snapshot from testdata/sources, by fixture
+// name. Add a source there for every new message or rule worth keeping compatible.
+//
+// Sources are protojson rather than Go, so that this test still compiles after a breaking
+// change and can report it.
+func readSources() (map[string]proto.Message, error) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "sources", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	messages := make(map[string]proto.Message, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var source source
+		if err := json.Unmarshal(data, &source); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(source.Message))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		message := messageType.New().Interface()
+		if err := protojson.Unmarshal(source.Value, message); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if source.AppendUnknownField {
+			unknown := protowire.AppendTag(nil, unknownFieldNumber, protowire.VarintType)
+			message.ProtoReflect().SetUnknown(protowire.AppendVarint(unknown, 42))
+		}
+		messages[strings.TrimSuffix(filepath.Base(path), ".json")] = message
+	}
+	return messages, nil
+}
+
+// writeSnapshot writes the current schemas and fixtures to dir, which must not exist.
+func writeSnapshot(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("snapshot %s already exists", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := WriteSnapshot(filepath.Join(dir, schemaFile), demoPackage); err != nil {
+		return err
+	}
+	sources, err := readSources()
+	if err != nil {
+		return err
+	}
+	var fixtures []fixture
+	for name, message := range sources {
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".binpb"), data, 0o644); err != nil {
+			return err
+		}
+		violations, err := validate(message)
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, fixture{
+			Name:          name,
+			Message:       string(message.ProtoReflect().Descriptor().FullName()),
+			Violations:    violations,
+			UnknownFields: hasUnknownFields(message.ProtoReflect()),
+		})
+	}
+	slices.SortFunc(fixtures, func(a, b fixture) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0o644)
+}
+
+// versions returns the names of the snapshots in testdata.
+func versions(t *testing.T) []string {
+	entries, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != "sources" {
+			versions = append(versions, entry.Name())
+		}
+	}
+	if len(versions) == 0 {
+		t.Fatal("no snapshots in testdata")
+	}
+	return versions
+}
+
+func violations(t *testing.T, message proto.Message) []string {
+	violations, err := validate(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return violations
+}
+
+// validate returns the violations of a message as sorted "field.path: rule_id" strings.
+func validate(message proto.Message) ([]string, error) {
+	err := protovalidate.Validate(message)
+	if err == nil {
+		return nil, nil
+	}
+	var validationError *protovalidate.ValidationError
+	if !errors.As(err, &validationError) {
+		return nil, err
+	}
+	var violations []string
+	for _, violation := range validationError.Violations {
+		violations = append(violations, fmt.Sprintf("%s: %s", protovalidate.FieldPathString(violation.Proto.GetField()), violation.Proto.GetRuleId()))
+	}
+	slices.Sort(violations)
+	return violations, nil
+}
+
+// hasUnknownFields returns true if the message or any message within it has unknown fields.
+func hasUnknownFields(message protoreflect.Message) bool {
+	if len(message.GetUnknown()) > 0 {
+		return true
+	}
+	found := false
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsList() && field.Message() != nil:
+			list := value.List()
+			for i := 0; i < list.Len() && !found; i++ {
+				found = hasUnknownFields(list.Get(i).Message())
+			}
+		case field.IsMap() && field.MapValue().Message() != nil:
+			value.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+				found = hasUnknownFields(value.Message())
+				return !found
+			})
+		case field.Message() != nil && !field.IsList() && !field.IsMap():
+			found = hasUnknownFields(value.Message())
+		}
+		return !found
+	})
+	return found
+}
+
+// diffLines returns the lines only in want prefixed with -, and the lines only in got
+// prefixed with +, or an empty string if they have the same lines.
+func diffLines(want, got []string) string {
+	var builder strings.Builder
+	for _, line := range want {
+		if !slices.Contains(got, line) {
+			fmt.Fprintf(&builder, "  - %s\n", line)
+		}
+	}
+	for _, line := range got {
+		if !slices.Contains(want, line) {
+			fmt.Fprintf(&builder, "  + %s\n", line)
+		}
+	}
+	return builder.String()
+}
+
+func readJSON(t *testing.T, path string, value any) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		t.Fatalf("failed to parse %s: %v", path, err)
+	}
+}
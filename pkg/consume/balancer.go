@@ -0,0 +1,42 @@
+package consume
+
+import (
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// BalancerName is the name of a group balancer that can be created with NewBalancer.
+type BalancerName string
+
+const (
+	// BalancerCooperativeSticky moves as few partitions as possible on each rebalance, and
+	// only revokes the partitions that move, so members keep consuming the rest throughout.
+	// This is the default.
+	BalancerCooperativeSticky BalancerName = "cooperative-sticky"
+	// BalancerSticky moves as few partitions as possible on each rebalance, but revokes every
+	// partition of every member first.
+	BalancerSticky BalancerName = "sticky"
+	// BalancerRange assigns each member a contiguous range of each topic's partitions.
+	BalancerRange BalancerName = "range"
+	// BalancerRoundRobin assigns partitions to members one at a time, in turn.
+	BalancerRoundRobin BalancerName = "round-robin"
+)
+
+// NewBalancer returns the group balancer with the given name.
+//
+// Every member of a group must support the balancer the group uses.
+func NewBalancer(name BalancerName) (kgo.GroupBalancer, error) {
+	switch name {
+	case BalancerCooperativeSticky:
+		return kgo.CooperativeStickyBalancer(), nil
+	case BalancerSticky:
+		return kgo.StickyBalancer(), nil
+	case BalancerRange:
+		return kgo.RangeBalancer(), nil
+	case BalancerRoundRobin:
+		return kgo.RoundRobinBalancer(), nil
+	default:
+		return nil, fmt.Errorf("unknown balancer %q", name)
+	}
+}
@@ -3,14 +3,30 @@ package consume
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"reflect"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/bufbuild/bufstream-demo/pkg/encryption"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/logging"
+	"github.com/bufbuild/bufstream-demo/pkg/redact"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
 	"google.golang.org/protobuf/proto"
 )
 
+// ErrEndReached is returned by Consume once every partition has been consumed up to the
+// Consumer's end position.
+var ErrEndReached = errors.New("reached end position")
+
 // Consumer is an example consumer of a given topic using a given Protobuf message type.
 //
 // A Consume takes a Kafka client and a topic, and expects to receive Protobuf messages
@@ -18,6 +34,12 @@ import (
 // data is received (data that cannot be deserialized into the given Protobuf message type),
 // a malformed data handler is invoked.
 //
+// By default, a Consumer reads from its consumer group's committed offsets. It can instead
+// replay the topic from a start position up to an optional end position, such as to backfill
+// or reproduce an incident. See [WithStartPosition] and [WithEndPosition]. It can also skip
+// the messages that a CEL expression rejects, see [WithFilter], and decrypt encrypted
+// values, see [WithDecryption].
+//
 // This is a toy example, but shows the basics you need to receive Protobuf messages
 // from Kafka using franz-go. You can likely use this as a base to build out your own demo.
 type Consumer[M proto.Message] struct {
@@ -25,25 +47,48 @@ type Consumer[M proto.Message] struct {
 	topic                string
 	messageHandler       func(context.Context, M) error
 	malformedDataHandler func(context.Context, []byte, error) error
+	// filter selects the messages passed to messageHandler. It is nil if every message is.
+	filter                 *Filter[M]
+	rejectedMessageHandler func(context.Context, M) error
+	// decryption decrypts the values of encrypted records. It is nil if there is no key to
+	// decrypt them with.
+	decryption    *encryption.Envelope
+	startPosition kafka.Position
+	endPosition   kafka.Position
+	started       bool
+	// endOffsets holds the end offset of each partition that has not yet been consumed up
+	// to the end position. It is nil if there is no end position.
+	endOffsets  map[int32]int64
+	hooks       rebalanceHookFuncs
+	maxPollWait time.Duration
+	// partitions holds the partitions to consume from a start position. It is nil if every
+	// partition is consumed.
+	partitions []int32
 }
 
 // NewConsumer returns a new Consumer.
 //
-// Always use this constructor to construct Consumers.
+// Always use this constructor to construct Consumers. If the client's group has already
+// assigned it partitions, NewConsumer calls the assigned hooks for them before returning, see
+// [WithOnPartitionsAssigned].
 func NewConsumer[M proto.Message](
 	client *kgo.Client,
 	topic string,
 	options ...ConsumerOption[M],
 ) *Consumer[M] {
 	consumer := &Consumer[M]{
-		client:               client,
-		topic:                topic,
-		messageHandler:       defaultMessageHandler[M],
-		malformedDataHandler: defaultMalformedDataHandler,
+		client:                 client,
+		topic:                  topic,
+		messageHandler:         defaultMessageHandler[M],
+		malformedDataHandler:   defaultMalformedDataHandler,
+		rejectedMessageHandler: defaultRejectedMessageHandler[M],
 	}
 	for _, option := range options {
 		option(consumer)
 	}
+	if hooks, ok := client.Context().Value(rebalanceHooksKey{}).(*rebalanceHooks); ok {
+		hooks.register(client.Context(), topic, consumer.hooks)
+	}
 	return consumer
 }
 
@@ -56,7 +101,7 @@ type ConsumerOption[M proto.Message] func(*Consumer[M])
 // WithMessageHandler returns a new ConsumerOption that overrides the default
 // handler of received messages.
 //
-// The default handler uses slog to log incoming messages.
+// The default handler uses slog to log incoming messages, with their sensitive fields masked.
 func WithMessageHandler[M proto.Message](messageHandler func(context.Context, M) error) ConsumerOption[M] {
 	return func(consumer *Consumer[M]) {
 		consumer.messageHandler = messageHandler
@@ -75,31 +120,505 @@ func WithMalformedDataHandler[M proto.Message](
 	}
 }
 
+// WithDecryption returns a new ConsumerOption that decrypts the values of encrypted records
+// with the given Envelope before deserializing them. See the encryption package.
+//
+// Records that are not encrypted are deserialized as is, so a topic can move to encrypted
+// values without its consumers skipping a beat. Without this option, encrypted records are
+// passed to the malformed data handler.
+func WithDecryption[M proto.Message](envelope *encryption.Envelope) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.decryption = envelope
+	}
+}
+
+// WithStartPosition returns a new ConsumerOption that starts consuming from the given
+// position instead of the consumer group's committed offsets.
+//
+// A Consumer with a start position reads every partition of the topic directly rather than
+// through a consumer group, so replaying never moves a group's committed offsets. The client
+// must not be a group consumer: create it with [NewKafkaClient].
+func WithStartPosition[M proto.Message](position kafka.Position) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.startPosition = position
+	}
+}
+
+// WithEndPosition returns a new ConsumerOption that stops consuming at the given position.
+// Once every partition has been consumed up to this position, Consume returns ErrEndReached.
+//
+// The end position is resolved to offsets when consuming starts, and records at or after
+// these offsets are never handled. Offsets past a partition's open transactions are not
+// waited for, see [kafka.ResolveRange]. An end position requires a start position.
+func WithEndPosition[M proto.Message](position kafka.Position) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.endPosition = position
+	}
+}
+
+// WithPartitions returns a new ConsumerOption that only consumes the given partitions of the
+// topic. It requires a start position, as a consumer group decides which partitions each of
+// its consumers reads.
+func WithPartitions[M proto.Message](partitions ...int32) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.partitions = partitions
+	}
+}
+
+// WithOnPartitionsAssigned returns a new ConsumerOption that calls onAssigned with the
+// partitions of the topic that the consumer group assigns to this consumer, before any of
+// their records are handled.
+//
+// With the default cooperative-sticky balancer, only newly assigned partitions are passed.
+// Errors are logged, as an assignment cannot be refused. Rebalance hooks are only called if
+// the client was created with [NewKafkaClient].
+func WithOnPartitionsAssigned[M proto.Message](onAssigned func(context.Context, []int32) error) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.hooks.assigned = append(consumer.hooks.assigned, onAssigned)
+	}
+}
+
+// WithOnPartitionsRevoked returns a new ConsumerOption that calls onRevoked with the
+// partitions of the topic that the consumer group takes away from this consumer, such as when
+// another consumer joins the group or this one leaves it.
+//
+// Every handler of the revoked partitions' records has returned by the time onRevoked is
+// called, and the offsets of those records are committed after it returns, so onRevoked is
+// the place to flush any state kept for the partitions. With the default cooperative-sticky
+// balancer, only the partitions that move are revoked. Errors are logged, and the offsets are
+// committed regardless.
+func WithOnPartitionsRevoked[M proto.Message](onRevoked func(context.Context, []int32) error) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.hooks.revoked = append(consumer.hooks.revoked, onRevoked)
+	}
+}
+
+// WithOnPartitionsLost returns a new ConsumerOption that calls onLost with the partitions of
+// the topic that this consumer lost without a clean revoke, such as after it was kicked out
+// of the group for missing heartbeats.
+//
+// Another consumer may already own the partitions, so onLost must drop any state kept for
+// them without writing it anywhere. Offsets are not committed.
+func WithOnPartitionsLost[M proto.Message](onLost func(context.Context, []int32) error) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.hooks.lost = append(consumer.hooks.lost, onLost)
+	}
+}
+
+// WithHeldOffsets returns a new ConsumerOption that holds back the commit of records that are
+// not done with when their handler returns, such as records aggregated in memory until their
+// aggregates are produced.
+//
+// held returns, by partition, the lowest offset of such a record. The offsets of the records
+// handled before it are committed, but not its own or any after it, on Close, on revoke, and
+// by [Consumer.Commit]. The client must be created with [kgo.DisableAutoCommit], as
+// autocommits commit every handled record.
+func WithHeldOffsets[M proto.Message](held func() map[int32]int64) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.hooks.held = held
+	}
+}
+
+// WithMaxPollWait returns a new ConsumerOption that makes Consume return after waiting for
+// records for the given duration, even if none have arrived.
+//
+// By default, Consume waits for as long as it takes, so a loop calling it stalls while the
+// topic is idle. A maximum wait lets the loop do periodic work, such as reporting that it is
+// still alive.
+func WithMaxPollWait[M proto.Message](maxPollWait time.Duration) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.maxPollWait = maxPollWait
+	}
+}
+
+// NewKafkaClient returns a new franz-go Kafka Client for a Consumer.
+//
+// If start is zero, the client is a group consumer of the Config's topic, just as with
+// [kafka.NewKafkaClient]. Otherwise, the client does not join a group, and a Consumer
+// created with [WithStartPosition] assigns it the topic's partitions.
+//
+// The group consumer calls the rebalance hooks of the Consumer created with it, see
+// [WithOnPartitionsRevoked]. Rebalances that revoke partitions wait for Consume to finish
+// handling the records it polled, so that handlers never run for partitions that have moved
+// to another consumer. Any extra options, such as a balancer, are applied to the group
+// consumer. They are ignored if start is not zero, as the client does not join a group.
+func NewKafkaClient(config kafka.Config, start kafka.Position, extraOpts ...kgo.Opt) (*kgo.Client, error) {
+	if start.IsZero() {
+		hooks := &rebalanceHooks{}
+		return kafka.NewKafkaClient(config, true, append(hooks.opts(), extraOpts...)...)
+	}
+	return kafka.NewKafkaClient(
+		config,
+		false,
+		kgo.FetchMaxWait(time.Second),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		// Keep control records so that we can tell when we have reached the end position
+		// even if the last record before it is a transaction marker.
+		kgo.KeepControlRecords(),
+	)
+}
+
 // Consume consumes as many records as it can from the topic, deserializing them into
 // a message of type M if it can, and then invoking the message handler. It invokes the
 // malformed data handler if the record's payload cannot be deserialized into type M.
+//
+// If the Consumer has an end position, Consume returns ErrEndReached once it has been
+// reached on every partition. If ctx is canceled while waiting for records, Consume returns
+// ctx's error; once records are received, they are all handled regardless. Only a Consumer
+// with a start position, which commits nothing, stops partway: if ctx is canceled while its
+// filter is evaluated, Consume returns ctx's error.
 func (c *Consumer[M]) Consume(ctx context.Context) error {
-	fetches := c.client.PollFetches(ctx)
+	if !c.started {
+		if err := c.start(ctx); err != nil {
+			return err
+		}
+		c.started = true
+	}
+	if c.endOffsets != nil && len(c.endOffsets) == 0 {
+		return ErrEndReached
+	}
+	pollCtx := ctx
+	if c.maxPollWait > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, c.maxPollWait)
+		defer cancel()
+	}
+	fetches := c.client.PollFetches(pollCtx)
+	// Rebalances that revoke partitions are blocked from the poll until every polled record is
+	// handled. See NewKafkaClient.
+	defer c.client.AllowRebalance()
 	if errs := fetches.Errors(); len(errs) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if pollCtx.Err() != nil {
+			// No records arrived within the maximum wait.
+			return nil
+		}
 		return fmt.Errorf("failed to fetch records: %v", errs)
 	}
+	// Records that were polled are handled even if ctx is canceled meanwhile, so that a
+	// shutdown never stops halfway through them, and no record is committed unhandled. Without
+	// a group, nothing is committed, so a filter's evaluation is abandoned once ctx is canceled,
+	// such as when the reader of a stream goes away.
+	filterCtx := context.WithoutCancel(ctx)
+	if !c.startPosition.IsZero() {
+		filterCtx = ctx
+	}
+	ctx = context.WithoutCancel(ctx)
 	for _, record := range fetches.Records() {
-		message, err := c.toMessage(record.Value)
-		if err != nil {
-			if err := c.malformedDataHandler(ctx, record.Value, err); err != nil {
+		if c.endOffsets != nil {
+			end, ok := c.endOffsets[record.Partition]
+			if !ok {
+				// The partition has already reached the end position, and is paused, but
+				// records fetched before it was paused are still delivered.
+				continue
+			}
+			if record.Offset >= end {
+				// The last offsets before the end position were never delivered, such as
+				// because they were compacted away, or belong to an aborted transaction.
+				c.reachEnd(record.Partition)
+				continue
+			}
+		}
+		if !record.Attrs.IsControl() {
+			if err := c.handle(ctx, filterCtx, record); err != nil {
 				return err
 			}
-			continue
 		}
-		if err := c.messageHandler(ctx, message); err != nil {
+		c.advance(record)
+	}
+	if c.endOffsets != nil && len(c.endOffsets) == 0 {
+		return ErrEndReached
+	}
+	return nil
+}
+
+// Commit commits the offsets of every handled record, except those held back by
+// [WithHeldOffsets]. Only a Consumer created with that option needs to call it, as the
+// client otherwise commits handled records on its own.
+//
+// Commit does nothing if the client is not a group consumer.
+func (c *Consumer[M]) Commit(ctx context.Context) error {
+	if group, _ := c.client.OptValue(kgo.ConsumerGroup).(string); group == "" {
+		return nil
+	}
+	if err := commitHandled(ctx, c.client, c.topic, c.hooks.held); err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	return nil
+}
+
+// Close commits the offsets of every handled record, except those held back by
+// [WithHeldOffsets], and leaves the consumer group, so that the group reassigns this
+// consumer's partitions right away rather than once its session times out. The revoked hooks
+// are called as the group is left.
+//
+// Call Close once Consume has returned for the last time, and before closing the client.
+// Close does nothing if the client is not a group consumer.
+func (c *Consumer[M]) Close(ctx context.Context) error {
+	if group, _ := c.client.OptValue(kgo.ConsumerGroup).(string); group == "" {
+		return nil
+	}
+	if err := commitHandled(ctx, c.client, c.topic, c.hooks.held); err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	if err := c.client.LeaveGroupContext(ctx); err != nil {
+		return fmt.Errorf("failed to leave consumer group: %w", err)
+	}
+	return nil
+}
+
+// RecordFromContext returns the record being handled, when called with the context passed to
+// a message or malformed data handler.
+//
+// Handlers that need more than the message, such as the record's key, headers, or partition,
+// can use this to get them.
+func RecordFromContext(ctx context.Context) (*kgo.Record, bool) {
+	record, ok := ctx.Value(recordContextKey{}).(*kgo.Record)
+	return record, ok
+}
+
+type recordContextKey struct{}
+
+// handle handles a record, evaluating the Consumer's filter, if any, with filterCtx.
+func (c *Consumer[M]) handle(ctx context.Context, filterCtx context.Context, record *kgo.Record) error {
+	ctx = context.WithValue(ctx, recordContextKey{}, record)
+	// Everything logged while handling the record says which record it was.
+	ctx = logging.WithAttrs(ctx, logging.RecordAttrs(record)...)
+	message, err := c.toMessage(ctx, record)
+	if err != nil {
+		return c.malformedDataHandler(ctx, record.Value, err)
+	}
+	matches, err := c.filterMessage(ctx, filterCtx, message)
+	if err != nil {
+		return err
+	}
+	if !matches {
+		return c.rejectedMessageHandler(ctx, message)
+	}
+	return c.messageHandler(ctx, message)
+}
+
+// start resolves the start and end positions, if any, and starts consuming each partition
+// of the topic from its start offset.
+func (c *Consumer[M]) start(ctx context.Context) error {
+	if c.startPosition.IsZero() {
+		if !c.endPosition.IsZero() {
+			return errors.New("an end position requires a start position")
+		}
+		if c.partitions != nil {
+			return errors.New("consuming specific partitions requires a start position")
+		}
+		return nil
+	}
+	if group, _ := c.client.OptValue(kgo.ConsumerGroup).(string); group != "" {
+		return fmt.Errorf("cannot consume from a start position with consumer group %s", group)
+	}
+	admClient := kadm.NewClient(c.client)
+	startOffsets, err := kafka.ResolvePosition(ctx, admClient, c.topic, c.startPosition)
+	if err != nil {
+		return err
+	}
+	if c.partitions != nil {
+		for _, partition := range c.partitions {
+			if _, ok := startOffsets[partition]; !ok {
+				return fmt.Errorf("start position %s has no partition %d of topic %s", c.startPosition, partition, c.topic)
+			}
+		}
+		maps.DeleteFunc(startOffsets, func(partition int32, _ int64) bool {
+			return !slices.Contains(c.partitions, partition)
+		})
+	}
+	if !c.endPosition.IsZero() {
+		ranges, err := kafka.ResolveRange(ctx, admClient, c.topic, c.startPosition, c.endPosition)
+		if err != nil {
 			return err
 		}
+		c.endOffsets = make(map[int32]int64, len(startOffsets))
+		for partition := range startOffsets {
+			offsetRange, ok := ranges[partition]
+			if !ok {
+				// Nothing to consume from this partition.
+				delete(startOffsets, partition)
+				continue
+			}
+			startOffsets[partition] = offsetRange.Start
+			c.endOffsets[partition] = offsetRange.End
+		}
+	}
+	partitions := make(map[int32]kgo.Offset, len(startOffsets))
+	for partition, startOffset := range startOffsets {
+		partitions[partition] = kgo.NewOffset().At(startOffset)
 	}
+	slog.InfoContext(
+		ctx,
+		"consuming from start position",
+		"start", c.startPosition.String(),
+		"end", c.endPosition.String(),
+		"partitions", len(partitions),
+	)
+	c.client.AddConsumePartitions(map[string]map[int32]kgo.Offset{c.topic: partitions})
 	return nil
 }
 
+// advance records that the record has been consumed, and stops consuming its partition
+// once the end position has been reached.
+func (c *Consumer[M]) advance(record *kgo.Record) {
+	if c.endOffsets == nil {
+		return
+	}
+	if end, ok := c.endOffsets[record.Partition]; ok && record.Offset+1 >= end {
+		c.reachEnd(record.Partition)
+	}
+}
+
+// reachEnd stops consuming a partition that has reached the end position.
+func (c *Consumer[M]) reachEnd(partition int32) {
+	delete(c.endOffsets, partition)
+	c.client.PauseFetchPartitions(map[string][]int32{c.topic: {partition}})
+}
+
+type rebalanceHookFuncs struct {
+	assigned []func(context.Context, []int32) error
+	revoked  []func(context.Context, []int32) error
+	lost     []func(context.Context, []int32) error
+	// held returns the offsets to hold back from commits. It is nil if none are.
+	held func() map[int32]int64
+}
+
+// rebalanceHooks dispatches the rebalance callbacks of a client created with NewKafkaClient
+// to the hooks of the Consumer created with it.
+//
+// The callbacks must be set when the client is created, before the Consumer and its options
+// exist, so the client carries its rebalanceHooks in its context for NewConsumer to find. The
+// client joins its group right away, so partitions may be assigned before the Consumer is
+// created: they are kept, and passed to the Consumer's hooks once they are registered.
+type rebalanceHooks struct {
+	// mu is held while hooks are called, so that they are never called concurrently.
+	mu         sync.Mutex
+	registered bool
+	topic      string
+	funcs      rebalanceHookFuncs
+	// assigned holds the partitions assigned before the hooks were registered, by topic.
+	assigned map[string][]int32
+}
+
+type rebalanceHooksKey struct{}
+
+func (h *rebalanceHooks) register(ctx context.Context, topic string, funcs rebalanceHookFuncs) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.registered = true
+	h.topic = topic
+	h.funcs = funcs
+	callHooks(ctx, "assigned", topic, h.assigned[topic], funcs.assigned)
+	h.assigned = nil
+}
+
+func (h *rebalanceHooks) opts() []kgo.Opt {
+	return []kgo.Opt{
+		kgo.WithContext(context.WithValue(context.Background(), rebalanceHooksKey{}, h)),
+		kgo.BlockRebalanceOnPoll(),
+		kgo.OnPartitionsAssigned(func(ctx context.Context, _ *kgo.Client, assigned map[string][]int32) {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if !h.registered {
+				if h.assigned == nil {
+					h.assigned = make(map[string][]int32)
+				}
+				for topic, partitions := range assigned {
+					h.assigned[topic] = append(h.assigned[topic], partitions...)
+				}
+				return
+			}
+			callHooks(ctx, "assigned", h.topic, assigned[h.topic], h.funcs.assigned)
+		}),
+		kgo.OnPartitionsRevoked(func(ctx context.Context, client *kgo.Client, revoked map[string][]int32) {
+			h.remove(ctx, "revoked", revoked)
+			h.mu.Lock()
+			topic, held := h.topic, h.funcs.held
+			h.mu.Unlock()
+			// This callback replaces the client's default one, which commits on revoke.
+			if err := commitHandled(ctx, client, topic, held); err != nil {
+				slog.ErrorContext(ctx, "failed to commit offsets on revoke", "error", err)
+			}
+		}),
+		kgo.OnPartitionsLost(func(ctx context.Context, _ *kgo.Client, lost map[string][]int32) {
+			h.remove(ctx, "lost", lost)
+		}),
+	}
+}
+
+func (h *rebalanceHooks) remove(ctx context.Context, event string, removed map[string][]int32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.registered {
+		for topic, partitions := range removed {
+			h.assigned[topic] = slices.DeleteFunc(h.assigned[topic], func(partition int32) bool {
+				return slices.Contains(partitions, partition)
+			})
+		}
+		return
+	}
+	hookFuncs := h.funcs.revoked
+	if event == "lost" {
+		hookFuncs = h.funcs.lost
+	}
+	callHooks(ctx, event, h.topic, removed[h.topic], hookFuncs)
+}
+
+// commitHandled commits the offsets of every record polled by client, except that the
+// offsets of topic's partitions stop at those returned by held, if it is not nil.
+func commitHandled(ctx context.Context, client *kgo.Client, topic string, held func() map[int32]int64) error {
+	offsets := client.UncommittedOffsets()
+	if held != nil {
+		for partition, offset := range held() {
+			if head, ok := offsets[topic][partition]; ok && offset < head.Offset {
+				// The leader epoch of the held record is not known, and is not needed to commit.
+				offsets[topic][partition] = kgo.EpochOffset{Epoch: -1, Offset: offset}
+			}
+		}
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+	var commitErr error
+	client.CommitOffsetsSync(ctx, offsets, func(_ *kgo.Client, _ *kmsg.OffsetCommitRequest, response *kmsg.OffsetCommitResponse, err error) {
+		if err != nil {
+			commitErr = err
+			return
+		}
+		for _, topic := range response.Topics {
+			for _, partition := range topic.Partitions {
+				if err := kerr.ErrorForCode(partition.ErrorCode); err != nil {
+					commitErr = fmt.Errorf("partition %d of topic %s: %w", partition.Partition, topic.Topic, err)
+					return
+				}
+			}
+		}
+	})
+	return commitErr
+}
+
+func callHooks(ctx context.Context, event string, topic string, partitions []int32, hookFuncs []func(context.Context, []int32) error) {
+	if len(partitions) == 0 {
+		// The revoke callback is called at the end of every group session, even if nothing
+		// is revoked.
+		return
+	}
+	slices.Sort(partitions)
+	slog.InfoContext(ctx, "partitions "+event, "topic", topic, "partitions", partitions)
+	for _, hookFunc := range hookFuncs {
+		if err := hookFunc(ctx, partitions); err != nil {
+			slog.ErrorContext(ctx, "rebalance hook failed", "event", event, "partitions", partitions, "error", err)
+		}
+	}
+}
+
 func defaultMessageHandler[M proto.Message](ctx context.Context, message M) error {
-	slog.InfoContext(ctx, "consumed message", "message", message)
+	// Logs are read by more people than the data is meant for.
+	slog.InfoContext(ctx, "consumed message", "message", redact.Redact(message, redact.ModeMask))
 	return nil
 }
 
@@ -108,13 +627,28 @@ func defaultMalformedDataHandler(ctx context.Context, payload []byte, err error)
 	return nil
 }
 
-func (c *Consumer[M]) toMessage(payload []byte) (M, error) {
-	var message M
-	msgType := reflect.TypeOf(message).Elem()
-	message = reflect.New(msgType).Interface().(M)
+func (c *Consumer[M]) toMessage(ctx context.Context, record *kgo.Record) (M, error) {
+	message := newMessage[M]()
+	payload := record.Value
+	if encryption.IsEncrypted(record.Headers) {
+		if c.decryption == nil {
+			return message, errors.New("record value is encrypted, but no key to decrypt it with was configured")
+		}
+		var err error
+		payload, err = c.decryption.Decrypt(ctx, record.Topic, payload, record.Headers)
+		if err != nil {
+			return message, err
+		}
+	}
 	err := proto.Unmarshal(payload, message)
 	if err != nil {
-		err = fmt.Errorf("failed to unmarshal record value onto %s: %w", msgType.Name(), err)
+		err = fmt.Errorf("failed to unmarshal record value onto %s: %w", reflect.TypeOf(message).Elem().Name(), err)
 	}
 	return message, err
 }
+
+// newMessage returns a new, empty message of type M.
+func newMessage[M proto.Message]() M {
+	var message M
+	return reflect.New(reflect.TypeOf(message).Elem()).Interface().(M)
+}
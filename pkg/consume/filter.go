@@ -0,0 +1,166 @@
+package consume
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/google/cel-go/cel"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// maxFilterLength is the maximum length of a filter's expression.
+	maxFilterLength = 1024
+	// filterCostLimit is the maximum cost of evaluating a filter for one message, in CEL's
+	// units of cost. It is well above what filters over one message need, and well below what
+	// nested comprehensions need to pin a CPU.
+	filterCostLimit = 100_000
+	// filterInterruptCheckFrequency is how many comprehension iterations a filter evaluates
+	// between checks of whether its context is done.
+	filterInterruptCheckFrequency = 100
+)
+
+// Filter is a compiled CEL expression that selects which messages of type M a Consumer
+// handles, such as this.line_items.exists(li, li.quantity > 10).
+//
+// The message is bound to the expression as this, as in Protovalidate's CEL rules. A Filter
+// counts the messages it matches and rejects, see Stats. A Filter is safe for concurrent use.
+//
+// Filters may come from untrusted input, such as a query parameter, so their expressions are
+// limited in length, and their evaluations in cost.
+type Filter[M proto.Message] struct {
+	expression string
+	program    cel.Program
+	matched    atomic.Int64
+	rejected   atomic.Int64
+	failed     atomic.Int64
+}
+
+// FilterStats are the counts of messages a Filter has been evaluated for.
+type FilterStats struct {
+	// Matched is the number of messages the expression was true for.
+	Matched int64
+	// Rejected is the number of messages the expression was false for, or failed on.
+	Rejected int64
+	// Failed is the number of messages the expression could not be evaluated for, such as
+	// when it indexes a list out of bounds.
+	Failed int64
+}
+
+// NewFilter compiles a CEL expression over a message of type M. The expression must evaluate
+// to a bool.
+func NewFilter[M proto.Message](expression string) (*Filter[M], error) {
+	if len(expression) > maxFilterLength {
+		return nil, fmt.Errorf("invalid filter: must be at most %d bytes long, not %d", maxFilterLength, len(expression))
+	}
+	message := newMessage[M]()
+	env, err := cel.NewEnv(
+		cel.Types(message),
+		cel.Variable("this", cel.ObjectType(string(message.ProtoReflect().Descriptor().FullName()))),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expression, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("invalid filter %q: must evaluate to a bool, not %s", expression, ast.OutputType())
+	}
+	program, err := env.Program(
+		ast,
+		cel.CostLimit(filterCostLimit),
+		cel.InterruptCheckFrequency(filterInterruptCheckFrequency),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expression, err)
+	}
+	return &Filter[M]{
+		expression: expression,
+		program:    program,
+	}, nil
+}
+
+// String returns the expression of the Filter.
+func (f *Filter[M]) String() string {
+	return f.expression
+}
+
+// Matches returns true if the expression is true for the message, and counts the result.
+//
+// If the expression cannot be evaluated for the message, such as because it exceeds the cost
+// limit, Matches returns false along with the error. If ctx is done during the evaluation,
+// Matches returns false along with ctx's error, without counting the message.
+func (f *Filter[M]) Matches(ctx context.Context, message M) (bool, error) {
+	value, _, err := f.program.ContextEval(ctx, map[string]any{"this": message})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return false, ctxErr
+		}
+		f.failed.Add(1)
+		f.rejected.Add(1)
+		return false, fmt.Errorf("failed to evaluate filter %q: %w", f.expression, err)
+	}
+	if matches, _ := value.Value().(bool); matches {
+		f.matched.Add(1)
+		return true, nil
+	}
+	f.rejected.Add(1)
+	return false, nil
+}
+
+// Stats returns the counts of messages the Filter has been evaluated for.
+func (f *Filter[M]) Stats() FilterStats {
+	return FilterStats{
+		Matched:  f.matched.Load(),
+		Rejected: f.rejected.Load(),
+		Failed:   f.failed.Load(),
+	}
+}
+
+// WithFilter returns a new ConsumerOption that only invokes the message handler for messages
+// that the filter matches.
+//
+// Rejected messages are passed to the rejected message handler instead, which by default
+// does nothing, see [WithRejectedMessageHandler]. Either way, their records count as
+// consumed, and their offsets are committed.
+func WithFilter[M proto.Message](filter *Filter[M]) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.filter = filter
+	}
+}
+
+// WithRejectedMessageHandler returns a new ConsumerOption that invokes rejectedMessageHandler
+// for messages that the Consumer's filter rejects, such as to route them to another topic.
+// See [WithFilter].
+func WithRejectedMessageHandler[M proto.Message](rejectedMessageHandler func(context.Context, M) error) ConsumerOption[M] {
+	return func(consumer *Consumer[M]) {
+		consumer.rejectedMessageHandler = rejectedMessageHandler
+	}
+}
+
+// filterMessage returns true if the message should be passed to the message handler. The
+// filter is evaluated with evalCtx, and if it is done during the evaluation, filterMessage
+// returns its error.
+func (c *Consumer[M]) filterMessage(ctx context.Context, evalCtx context.Context, message M) (bool, error) {
+	if c.filter == nil {
+		return true, nil
+	}
+	matches, err := c.filter.Matches(evalCtx, message)
+	if err != nil && evalCtx.Err() != nil {
+		return false, err
+	}
+	if err != nil && c.filter.Stats().Failed == 1 {
+		// An expression that fails on one message likely fails on many, so only the first
+		// failure is logged. The rest are counted, see Filter.Stats.
+		slog.WarnContext(ctx, "rejecting message the filter failed on", "error", err)
+	}
+	return matches, nil
+}
+
+func defaultRejectedMessageHandler[M proto.Message](context.Context, M) error {
+	return nil
+}
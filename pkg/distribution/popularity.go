@@ -0,0 +1,238 @@
+// Package distribution provides the random distributions used to generate carts: which
+// products are popular, and how many of each are bought.
+package distribution
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+)
+
+// Curve is a time-of-day seasonality curve: a relative weight for each hour of the day,
+// starting at midnight.
+type Curve [24]float64
+
+// Curves are the built-in seasonality curves, by name.
+var Curves = map[string]Curve{
+	"flat": {
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	},
+	// Peaks at breakfast time.
+	"morning": {
+		0.2, 0.1, 0.1, 0.1, 0.2, 0.5, 1.5, 3, 3, 2, 1.2, 1,
+		0.9, 0.8, 0.7, 0.7, 0.7, 0.7, 0.6, 0.5, 0.4, 0.3, 0.3, 0.2,
+	},
+	// Peaks over lunch.
+	"midday": {
+		0.1, 0.1, 0.1, 0.1, 0.1, 0.2, 0.4, 0.7, 1, 1.5, 2, 3,
+		3, 2.5, 1.5, 1.2, 1, 0.9, 0.8, 0.6, 0.4, 0.3, 0.2, 0.1,
+	},
+	// Peaks after work.
+	"evening": {
+		0.3, 0.2, 0.1, 0.1, 0.1, 0.1, 0.2, 0.4, 0.6, 0.7, 0.8, 0.9,
+		1, 1, 1, 1.1, 1.3, 1.8, 2.5, 3, 3, 2.2, 1.2, 0.6,
+	},
+	// Peaks late at night.
+	"night": {
+		2.5, 2, 1.5, 1, 0.6, 0.3, 0.2, 0.2, 0.3, 0.4, 0.5, 0.5,
+		0.5, 0.5, 0.5, 0.5, 0.6, 0.7, 0.8, 1, 1.3, 1.8, 2.5, 3,
+	},
+}
+
+// ParseCurve returns the built-in Curve with the given name.
+func ParseCurve(name string) (Curve, error) {
+	curve, ok := Curves[name]
+	if !ok {
+		return Curve{}, fmt.Errorf("unknown seasonality curve %q", name)
+	}
+	return curve, nil
+}
+
+// Popularity describes how likely each product is to be added to a cart.
+//
+// A product's weight is the product of its Zipf weight, its category's weight, and its
+// category's seasonality at the current hour. The zero value picks products uniformly.
+type Popularity struct {
+	// ZipfExponent skews popularity towards the first products of the catalog: the product
+	// of rank k is weighted 1/k^ZipfExponent. If 0, every rank is equally popular. Values
+	// around 1 model the long tail of real catalogs.
+	ZipfExponent float64
+	// CategoryWeights are relative weights by category ID. Categories that are not listed
+	// have a weight of 1.
+	CategoryWeights map[string]float64
+	// CategorySeasonality are time-of-day curves by category ID. Categories that are not
+	// listed are equally popular at all hours.
+	CategorySeasonality map[string]Curve
+}
+
+// Validate returns an error if any weight is negative or not finite.
+func (p Popularity) Validate() error {
+	if p.ZipfExponent < 0 || math.IsInf(p.ZipfExponent, 0) || math.IsNaN(p.ZipfExponent) {
+		return fmt.Errorf("invalid Zipf exponent %v: must be zero or positive", p.ZipfExponent)
+	}
+	for categoryID, weight := range p.CategoryWeights {
+		if !isValidWeight(weight) {
+			return fmt.Errorf("invalid weight %v for category %s: must be zero or positive", weight, categoryID)
+		}
+	}
+	for categoryID, curve := range p.CategorySeasonality {
+		for hour, weight := range curve {
+			if !isValidWeight(weight) {
+				return fmt.Errorf("invalid seasonality %v for category %s at hour %d: must be zero or positive", weight, categoryID, hour)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateFor returns an error if every one of the products has a weight of zero at some hour
+// of the day, since no product could be picked at that hour.
+func (p Popularity) ValidateFor(products []*demov1.Product) error {
+	for hour := range len(Curve{}) {
+		var total float64
+		for i, product := range products {
+			total += p.weight(product, i+1, hour)
+		}
+		if total == 0 {
+			return fmt.Errorf("every product has a weight of zero at hour %d", hour)
+		}
+	}
+	return nil
+}
+
+// weight returns the weight of a product of the given rank, starting at 1, at the given hour.
+func (p Popularity) weight(product *demov1.Product, rank int, hour int) float64 {
+	weight := 1.0
+	if p.ZipfExponent != 0 {
+		weight = 1 / math.Pow(float64(rank), p.ZipfExponent)
+	}
+	categoryID := product.GetCategory().GetId()
+	if categoryWeight, ok := p.CategoryWeights[categoryID]; ok {
+		weight *= categoryWeight
+	}
+	if curve, ok := p.CategorySeasonality[categoryID]; ok {
+		weight *= curve[hour]
+	}
+	return weight
+}
+
+// ProductPicker picks random products according to a Popularity. It is safe for concurrent
+// use.
+//
+// Products are ranked in the order they are returned by the products function, so the first
+// product is the most popular one when the Popularity has a Zipf exponent.
+type ProductPicker struct {
+	products   func() []*demov1.Product
+	popularity Popularity
+	now        func() time.Time
+
+	lock sync.RWMutex
+	// snapshot is the products that weights were computed from.
+	snapshot []*demov1.Product
+	// cumulativeWeights[i] is the sum of the weights of snapshot[0] to snapshot[i].
+	cumulativeWeights []float64
+	// hour is the hour of the day that weights were computed for.
+	hour int
+	// stale is true when the weights must be computed again before the next pick.
+	stale bool
+}
+
+// NewProductPicker returns a new ProductPicker that picks from the products returned by the
+// products function.
+//
+// The products function is called again only after Invalidate, so catalogs that change
+// must call Invalidate whenever they do.
+func NewProductPicker(products func() []*demov1.Product, popularity Popularity) *ProductPicker {
+	return &ProductPicker{
+		products:   products,
+		popularity: popularity,
+		now:        time.Now,
+		stale:      true,
+	}
+}
+
+// Invalidate makes the ProductPicker call its products function again before the next pick.
+func (p *ProductPicker) Invalidate() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stale = true
+}
+
+// Len returns the number of products that can be picked.
+func (p *ProductPicker) Len() int {
+	p.lock.RLock()
+	if !p.stale {
+		defer p.lock.RUnlock()
+		return len(p.snapshot)
+	}
+	p.lock.RUnlock()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.refresh(p.now().Hour())
+	return len(p.snapshot)
+}
+
+// Random returns a random product, weighted by popularity at the current time, or nil if
+// there are no products or every product has a weight of zero.
+func (p *ProductPicker) Random() *demov1.Product {
+	return p.Pick(p.now())
+}
+
+// Pick returns a random product, weighted by popularity at the given time, or nil if there
+// are no products or every product has a weight of zero.
+func (p *ProductPicker) Pick(now time.Time) *demov1.Product {
+	hour := now.Hour()
+	p.lock.RLock()
+	if p.stale || p.hour != hour {
+		p.lock.RUnlock()
+		p.lock.Lock()
+		p.refresh(hour)
+		p.lock.Unlock()
+		p.lock.RLock()
+	}
+	defer p.lock.RUnlock()
+	if len(p.cumulativeWeights) == 0 {
+		return nil
+	}
+	total := p.cumulativeWeights[len(p.cumulativeWeights)-1]
+	if total <= 0 {
+		return nil
+	}
+	target := rand.Float64() * total
+	// Find the first product whose cumulative weight exceeds the target. Products with a
+	// weight of zero share the cumulative weight of the product before them, so they are
+	// never found.
+	index, _ := slices.BinarySearchFunc(p.cumulativeWeights, target, func(cumulativeWeight float64, target float64) int {
+		if cumulativeWeight <= target {
+			return -1
+		}
+		return 1
+	})
+	return p.snapshot[min(index, len(p.snapshot)-1)]
+}
+
+// refresh computes weights for the given hour, fetching products first if stale. It must
+// be called with the write lock held.
+func (p *ProductPicker) refresh(hour int) {
+	if p.stale {
+		p.snapshot = p.products()
+		p.stale = false
+	}
+	p.hour = hour
+	p.cumulativeWeights = make([]float64, len(p.snapshot))
+	var total float64
+	for i, product := range p.snapshot {
+		total += p.popularity.weight(product, i+1, hour)
+		p.cumulativeWeights[i] = total
+	}
+}
+
+func isValidWeight(weight float64) bool {
+	return weight >= 0 && !math.IsInf(weight, 0) && !math.IsNaN(weight)
+}
@@ -0,0 +1,87 @@
+package distribution
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// QuantityKind is the kind of a Quantity distribution.
+type QuantityKind string
+
+const (
+	// QuantityUniform picks quantities uniformly between 1 and Max.
+	QuantityUniform QuantityKind = "uniform"
+	// QuantityGeometric picks quantities from a geometric distribution with the given Mean:
+	// most lines buy one unit, and each extra unit is less likely than the last.
+	QuantityGeometric QuantityKind = "geometric"
+	// QuantityPoisson picks quantities from a Poisson distribution shifted by one, so that
+	// the quantities cluster around the given Mean.
+	QuantityPoisson QuantityKind = "poisson"
+	// QuantityFixed always picks Mean, rounded to the nearest whole number.
+	QuantityFixed QuantityKind = "fixed"
+)
+
+// Quantity is a distribution of line item quantities.
+type Quantity struct {
+	Kind QuantityKind
+	// Mean is the mean quantity, before clamping to Max. It is not used by QuantityUniform.
+	Mean float64
+	// Max is the largest quantity to pick.
+	Max uint64
+}
+
+// Validate returns an error if the Quantity cannot be sampled.
+func (q Quantity) Validate() error {
+	if q.Max < 1 {
+		return fmt.Errorf("invalid maximum quantity %d: must be at least 1", q.Max)
+	}
+	switch q.Kind {
+	case QuantityUniform:
+		return nil
+	case QuantityGeometric, QuantityPoisson, QuantityFixed:
+		if q.Mean < 1 || math.IsInf(q.Mean, 0) || math.IsNaN(q.Mean) {
+			return fmt.Errorf("invalid mean quantity %v: must be at least 1", q.Mean)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown quantity distribution %q", q.Kind)
+	}
+}
+
+// Sample returns a random quantity between 1 and Max.
+func (q Quantity) Sample() uint64 {
+	var quantity uint64
+	switch q.Kind {
+	case QuantityGeometric:
+		// The number of trials until the first success, with a success probability of
+		// 1/Mean, has a mean of Mean.
+		p := 1 / q.Mean
+		if p >= 1 {
+			quantity = 1
+		} else {
+			quantity = 1 + uint64(math.Floor(math.Log(1-rand.Float64())/math.Log(1-p)))
+		}
+	case QuantityPoisson:
+		quantity = 1 + samplePoisson(q.Mean-1)
+	case QuantityFixed:
+		quantity = uint64(math.Round(q.Mean))
+	default:
+		quantity = rand.Uint64N(q.Max) + 1
+	}
+	return min(max(quantity, 1), q.Max)
+}
+
+// samplePoisson returns a random number from a Poisson distribution with the given mean,
+// using Knuth's algorithm. It is only suited to the small means of cart quantities.
+func samplePoisson(mean float64) uint64 {
+	if mean <= 0 {
+		return 0
+	}
+	limit := math.Exp(-mean)
+	var k uint64
+	for p := rand.Float64(); p > limit; p *= rand.Float64() {
+		k++
+	}
+	return k
+}
@@ -0,0 +1,282 @@
+// Package encryption implements envelope encryption of record values.
+//
+// Every value is encrypted with AES-256-GCM under a data key. Data keys are generated by an
+// Envelope, and wrapped, that is encrypted, by a key encryption key held by a KeyProvider,
+// such as a KMS. The wrapped data key travels with the record in its headers, along with the
+// ID of the key encryption key that wrapped it, so that any consumer with access to the
+// KeyProvider can decrypt the record, and key encryption keys can be rotated without
+// rewriting records.
+//
+// The record's topic and encryption headers are authenticated along with its value, so an
+// encrypted value cannot be decrypted after being copied to another topic, or after its
+// headers are swapped for those of another record.
+//
+// Values are encrypted before they leave the producer, so they are stored encrypted by
+// Bufstream, including in object storage. Bufstream cannot validate an encrypted value
+// against its schema, so encrypted records must be produced to topics without
+// buf.registry.value.schema.message.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const (
+	// HeaderKeyID is the header holding the ID of the key encryption key that wrapped the
+	// record's data key.
+	HeaderKeyID = "encryption-key-id"
+	// HeaderDataKey is the header holding the record's wrapped data key.
+	HeaderDataKey = "encryption-data-key"
+	// HeaderAlgorithm is the header holding the algorithm that the record's value is encrypted
+	// with.
+	HeaderAlgorithm = "encryption-algorithm"
+	// AlgorithmAES256GCM is AES-256-GCM, with the nonce prepended to the ciphertext.
+	AlgorithmAES256GCM = "AES-256-GCM"
+)
+
+const (
+	// defaultDataKeyLifetime is how long an Envelope uses a data key before generating a new
+	// one, by default.
+	defaultDataKeyLifetime = time.Hour
+	// maxDataKeyUses is how many values an Envelope encrypts with a data key before generating
+	// a new one, however long it has had it. Nonces are random, so a key must only be used a
+	// bounded number of times for them to stay unique.
+	maxDataKeyUses = 1 << 24
+	// maxUnwrappedKeys is how many unwrapped data keys an Envelope keeps for decryption.
+	maxUnwrappedKeys = 1024
+	// dataKeySize is the size of data keys, for AES-256.
+	dataKeySize = 32
+)
+
+// KeyProvider wraps and unwraps data keys with key encryption keys that never leave it, such
+// as the keys of a KMS.
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the provider's current key encryption key, and returns
+	// the ID of that key along with the wrapped data key.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key that was wrapped by the key encryption key with the given
+	// ID.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// IsEncrypted returns true if a record with the given headers has an encrypted value.
+func IsEncrypted(headers []kgo.RecordHeader) bool {
+	_, ok := header(headers, HeaderAlgorithm)
+	return ok
+}
+
+// Envelope encrypts and decrypts record values with data keys wrapped by a KeyProvider. An
+// Envelope is safe for concurrent use.
+type Envelope struct {
+	provider        KeyProvider
+	dataKeyLifetime time.Duration
+	// mu guards current and unwrapped. It is not held while calling the provider.
+	mu      sync.Mutex
+	current *dataKey
+	// unwrapped holds the data keys unwrapped for decryption, by key ID and wrapped data key.
+	unwrapped map[string]cipher.AEAD
+}
+
+// dataKey is a data key that an Envelope encrypts values with.
+type dataKey struct {
+	aead    cipher.AEAD
+	keyID   string
+	wrapped []byte
+	created time.Time
+	uses    int
+}
+
+// NewEnvelope returns a new Envelope.
+//
+// Always use this constructor to construct Envelopes.
+func NewEnvelope(provider KeyProvider, options ...EnvelopeOption) *Envelope {
+	envelope := &Envelope{
+		provider:        provider,
+		dataKeyLifetime: defaultDataKeyLifetime,
+		unwrapped:       make(map[string]cipher.AEAD),
+	}
+	for _, option := range options {
+		option(envelope)
+	}
+	return envelope
+}
+
+// EnvelopeOption is an option when constructing a new Envelope.
+type EnvelopeOption func(*Envelope)
+
+// WithDataKeyLifetime returns a new EnvelopeOption that sets how long a data key is used for
+// encryption before a new one is generated.
+//
+// Every new data key is wrapped by the KeyProvider, so a shorter lifetime means more calls
+// to it, but less data encrypted under any one key.
+func WithDataKeyLifetime(lifetime time.Duration) EnvelopeOption {
+	return func(envelope *Envelope) {
+		envelope.dataKeyLifetime = lifetime
+	}
+}
+
+// Encrypt encrypts the value of a record produced to topic, and returns the encrypted value
+// along with the headers that must be added to its record for it to be decrypted.
+func (e *Envelope) Encrypt(ctx context.Context, topic string, plaintext []byte) ([]byte, []kgo.RecordHeader, error) {
+	key, err := e.dataKey(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(plaintext)+key.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	ciphertext := key.aead.Seal(nonce, nonce, plaintext, additionalData(topic, AlgorithmAES256GCM, key.keyID, key.wrapped))
+	headers := []kgo.RecordHeader{
+		{Key: HeaderAlgorithm, Value: []byte(AlgorithmAES256GCM)},
+		{Key: HeaderKeyID, Value: []byte(key.keyID)},
+		{Key: HeaderDataKey, Value: key.wrapped},
+	}
+	return ciphertext, headers, nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt, given the topic and headers of its record.
+func (e *Envelope) Decrypt(ctx context.Context, topic string, ciphertext []byte, headers []kgo.RecordHeader) ([]byte, error) {
+	algorithm, _ := header(headers, HeaderAlgorithm)
+	if string(algorithm) != AlgorithmAES256GCM {
+		return nil, fmt.Errorf("unsupported encryption algorithm %q", algorithm)
+	}
+	keyID, ok := header(headers, HeaderKeyID)
+	if !ok {
+		return nil, fmt.Errorf("missing %s header", HeaderKeyID)
+	}
+	wrapped, ok := header(headers, HeaderDataKey)
+	if !ok {
+		return nil, fmt.Errorf("missing %s header", HeaderDataKey)
+	}
+	aead, err := e.unwrap(ctx, string(keyID), wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, additionalData(topic, string(algorithm), string(keyID), wrapped))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// dataKey returns the data key to encrypt the next value with, generating a new one if the
+// current one has expired.
+func (e *Envelope) dataKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	if key := e.current; key != nil && key.uses < maxDataKeyUses && time.Since(key.created) < e.dataKeyLifetime {
+		key.uses++
+		e.mu.Unlock()
+		return key, nil
+	}
+	e.mu.Unlock()
+	// Concurrent callers may each generate a key here. Only one of them is kept as the current
+	// key, but every one of them is valid.
+	plainKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(plainKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	keyID, wrapped, err := e.provider.WrapKey(ctx, plainKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	aead, err := newAEAD(plainKey)
+	if err != nil {
+		return nil, err
+	}
+	key := &dataKey{
+		aead:    aead,
+		keyID:   keyID,
+		wrapped: wrapped,
+		created: time.Now(),
+		uses:    1,
+	}
+	e.mu.Lock()
+	e.current = key
+	e.mu.Unlock()
+	return key, nil
+}
+
+// unwrap returns the cipher of a wrapped data key, unwrapping it with the provider unless it
+// was unwrapped before.
+func (e *Envelope) unwrap(ctx context.Context, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	cacheKey := keyID + "/" + string(wrapped)
+	e.mu.Lock()
+	aead, ok := e.unwrapped[cacheKey]
+	e.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+	plainKey, err := e.provider.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key of key %s: %w", keyID, err)
+	}
+	aead, err = newAEAD(plainKey)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	if len(e.unwrapped) >= maxUnwrappedKeys {
+		// Data keys are used for many records in a row, so starting over costs little.
+		clear(e.unwrapped)
+	}
+	e.unwrapped[cacheKey] = aead
+	e.mu.Unlock()
+	return aead, nil
+}
+
+// additionalData returns the data authenticated along with a value: the topic of its record,
+// and the encryption headers. Each part is prefixed with its length, so that no two sets of
+// parts have the same encoding.
+func additionalData(topic string, algorithm string, keyID string, wrapped []byte) []byte {
+	var data []byte
+	for _, part := range [][]byte{[]byte(topic), []byte(algorithm), []byte(keyID), wrapped} {
+		data = binary.AppendUvarint(data, uint64(len(part)))
+		data = append(data, part...)
+	}
+	return data
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("expected a %d byte key, got %d bytes", dataKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// StripHeaders returns the headers without the encryption headers, such as to re-encrypt a
+// record with new ones.
+func StripHeaders(headers []kgo.RecordHeader) []kgo.RecordHeader {
+	return slices.DeleteFunc(slices.Clone(headers), func(header kgo.RecordHeader) bool {
+		return header.Key == HeaderKeyID || header.Key == HeaderDataKey || header.Key == HeaderAlgorithm
+	})
+}
+
+// header returns the value of the last header with the given key.
+func header(headers []kgo.RecordHeader, key string) ([]byte, bool) {
+	for i := len(headers) - 1; i >= 0; i-- {
+		if headers[i].Key == key {
+			return headers[i].Value, true
+		}
+	}
+	return nil, false
+}
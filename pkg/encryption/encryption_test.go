@@ -0,0 +1,252 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"slices"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const testTopic = "orders"
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	envelope := NewEnvelope(newTestKeyProvider(t, "dev-1", "dev-1"))
+	plaintext := []byte("a cart")
+	ciphertext, headers, err := envelope.Encrypt(ctx, testTopic, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Error("ciphertext contains the plaintext")
+	}
+	if !IsEncrypted(headers) {
+		t.Error("encryption headers are not recognized as encrypted")
+	}
+	// Decrypt with another Envelope, as a consumer would.
+	decrypted, err := NewEnvelope(envelope.provider).Decrypt(ctx, testTopic, ciphertext, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("decrypted %q, want %q", decrypted, plaintext)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	keys := map[string][]byte{"dev-1": newTestKey(t), "dev-2": newTestKey(t)}
+	before, err := NewLocalKeyProvider("dev-1", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := NewLocalKeyProvider("dev-2", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCiphertext, oldHeaders, err := NewEnvelope(before).Encrypt(ctx, testTopic, []byte("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := NewEnvelope(after)
+	newCiphertext, newHeaders, err := rotated.Encrypt(ctx, testTopic, []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID, _ := header(newHeaders, HeaderKeyID); string(keyID) != "dev-2" {
+		t.Errorf("new record has key ID %q, want dev-2", keyID)
+	}
+	for _, record := range []struct {
+		ciphertext []byte
+		headers    []kgo.RecordHeader
+		want       string
+	}{
+		{oldCiphertext, oldHeaders, "old"},
+		{newCiphertext, newHeaders, "new"},
+	} {
+		decrypted, err := rotated.Decrypt(ctx, testTopic, record.ciphertext, record.headers)
+		if err != nil {
+			t.Fatalf("failed to decrypt %s record: %v", record.want, err)
+		}
+		if string(decrypted) != record.want {
+			t.Errorf("decrypted %q, want %q", decrypted, record.want)
+		}
+	}
+	// Once the old key is removed, its records can no longer be decrypted.
+	retired, err := NewLocalKeyProvider("dev-2", map[string][]byte{"dev-2": keys["dev-2"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEnvelope(retired).Decrypt(ctx, testTopic, oldCiphertext, oldHeaders); err == nil {
+		t.Error("decrypted a record whose key was removed")
+	}
+}
+
+func TestTampered(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	envelope := NewEnvelope(newTestKeyProvider(t, "dev-1", "dev-1", "dev-2"))
+	ciphertext, headers, err := envelope.Encrypt(ctx, testTopic, []byte("a cart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wrap the same data key again, so that only the authenticated headers tell the two
+	// wrapped keys apart.
+	provider := envelope.provider.(*LocalKeyProvider)
+	dataKey, err := provider.UnwrapKey(ctx, "dev-1", mustHeader(t, headers, HeaderDataKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rewrapped, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A new Envelope generates its own data key.
+	_, otherHeaders, err := NewEnvelope(provider).Encrypt(ctx, testTopic, []byte("another cart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, test := range map[string]struct {
+		topic      string
+		ciphertext []byte
+		headers    []kgo.RecordHeader
+	}{
+		"flipped ciphertext bit": {
+			topic:      testTopic,
+			ciphertext: flipLastBit(ciphertext),
+			headers:    headers,
+		},
+		"flipped nonce bit": {
+			topic:      testTopic,
+			ciphertext: append([]byte{ciphertext[0] ^ 1}, ciphertext[1:]...),
+			headers:    headers,
+		},
+		"truncated ciphertext": {
+			topic:      testTopic,
+			ciphertext: ciphertext[:4],
+			headers:    headers,
+		},
+		"other topic": {
+			topic:      "orders.copy",
+			ciphertext: ciphertext,
+			headers:    headers,
+		},
+		"other data key": {
+			topic:      testTopic,
+			ciphertext: ciphertext,
+			headers:    withHeader(headers, HeaderDataKey, mustHeader(t, otherHeaders, HeaderDataKey)),
+		},
+		"rewrapped data key": {
+			topic:      testTopic,
+			ciphertext: ciphertext,
+			headers:    withHeader(headers, HeaderDataKey, rewrapped),
+		},
+		"flipped data key bit": {
+			topic:      testTopic,
+			ciphertext: ciphertext,
+			headers:    withHeader(headers, HeaderDataKey, flipLastBit(mustHeader(t, headers, HeaderDataKey))),
+		},
+		"other key ID": {
+			topic:      testTopic,
+			ciphertext: ciphertext,
+			headers:    withHeader(headers, HeaderKeyID, []byte("dev-2")),
+		},
+		"other algorithm": {
+			topic:      testTopic,
+			ciphertext: ciphertext,
+			headers:    withHeader(headers, HeaderAlgorithm, []byte("AES-128-GCM")),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := envelope.Decrypt(ctx, test.topic, test.ciphertext, test.headers); err == nil {
+				t.Error("decrypted a tampered record")
+			}
+		})
+	}
+	// The untampered record still decrypts.
+	if _, err := envelope.Decrypt(ctx, testTopic, ciphertext, headers); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMissingHeaders(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	envelope := NewEnvelope(newTestKeyProvider(t, "dev-1", "dev-1"))
+	ciphertext, headers, err := envelope.Encrypt(ctx, testTopic, []byte("a cart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{HeaderAlgorithm, HeaderKeyID, HeaderDataKey} {
+		t.Run(key, func(t *testing.T) {
+			t.Parallel()
+			var remaining []kgo.RecordHeader
+			for _, header := range headers {
+				if header.Key != key {
+					remaining = append(remaining, header)
+				}
+			}
+			if _, err := envelope.Decrypt(ctx, testTopic, ciphertext, remaining); err == nil {
+				t.Errorf("decrypted a record without the %s header", key)
+			}
+		})
+	}
+	if stripped := StripHeaders(append(headers, kgo.RecordHeader{Key: "trace-id"})); len(stripped) != 1 || stripped[0].Key != "trace-id" {
+		t.Errorf("StripHeaders kept %v, want only trace-id", stripped)
+	}
+}
+
+// newTestKeyProvider returns a LocalKeyProvider with a random key for every ID.
+func newTestKeyProvider(t *testing.T, current string, keyIDs ...string) *LocalKeyProvider {
+	t.Helper()
+	keys := make(map[string][]byte, len(keyIDs))
+	for _, keyID := range keyIDs {
+		keys[keyID] = newTestKey(t)
+	}
+	provider, err := NewLocalKeyProvider(current, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustHeader(t *testing.T, headers []kgo.RecordHeader, key string) []byte {
+	t.Helper()
+	value, ok := header(headers, key)
+	if !ok {
+		t.Fatalf("missing %s header", key)
+	}
+	return value
+}
+
+// withHeader returns a copy of headers with the headers with the given key set to value.
+func withHeader(headers []kgo.RecordHeader, key string, value []byte) []kgo.RecordHeader {
+	replaced := slices.Clone(headers)
+	for i := range replaced {
+		if replaced[i].Key == key {
+			replaced[i].Value = value
+		}
+	}
+	return replaced
+}
+
+func flipLastBit(data []byte) []byte {
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-1] ^= 1
+	return flipped
+}
@@ -0,0 +1,107 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// LocalKeyProvider is a KeyProvider whose key encryption keys are read from a local file.
+//
+// It is meant for development and tests. The key encryption keys sit next to the data they
+// protect, so in production, use a KeyProvider backed by a KMS instead.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewLocalKeyProvider returns a new LocalKeyProvider with the given 32 byte key encryption
+// keys, by ID. Data keys are wrapped with the key with ID current, and the others are only
+// kept to unwrap the data keys of older records.
+func NewLocalKeyProvider(current string, keys map[string][]byte) (*LocalKeyProvider, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("no key with current key ID %q", current)
+	}
+	provider := &LocalKeyProvider{
+		current: current,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+	for keyID, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", keyID, err)
+		}
+		provider.keys[keyID] = aead
+	}
+	return provider, nil
+}
+
+// LoadLocalKeyFile loads a LocalKeyProvider from a YAML file of the form:
+//
+//	current: dev-2
+//	keys:
+//	  dev-1: <base64 of 32 random bytes>
+//	  dev-2: <base64 of 32 random bytes>
+//
+// To rotate keys, add a new key and make it current. Keep the old keys for as long as records
+// whose data keys they wrapped are retained.
+func LoadLocalKeyFile(path string) (*LocalKeyProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Current string            `yaml:"current"`
+		Keys    map[string]string `yaml:"keys"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	keys := make(map[string][]byte, len(file.Keys))
+	for keyID, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid key %s: %w", path, keyID, err)
+		}
+		keys[keyID] = key
+	}
+	provider, err := NewLocalKeyProvider(file.Current, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return provider, nil
+}
+
+// WrapKey implements KeyProvider.
+func (p *LocalKeyProvider) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	aead := p.keys[p.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(dataKey)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// The key ID is authenticated, so that a wrapped data key cannot be passed off as wrapped
+	// by another key.
+	return p.current, aead.Seal(nonce, nonce, dataKey, []byte(p.current)), nil
+}
+
+// UnwrapKey implements KeyProvider.
+func (p *LocalKeyProvider) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key is too short")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(keyID))
+}
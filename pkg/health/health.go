@@ -0,0 +1,229 @@
+// Package health implements health checks, and the HTTP handler that serves them.
+//
+// Checks are registered in a Registry as either readiness or liveness checks, following the
+// Kubernetes conventions:
+//
+//   - /healthz succeeds as long as the process can serve it.
+//   - /readyz succeeds if every readiness check passes, such as the broker being reachable.
+//     A program that is not ready should be sent no traffic, but left running.
+//   - /livez succeeds if every liveness check passes, such as a loop still making progress.
+//     A program that is not live is stuck, and should be restarted.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Kind is the kind of a check.
+type Kind int
+
+const (
+	// Readiness checks whether the program can do its work.
+	Readiness Kind = iota + 1
+	// Liveness checks whether the program is stuck.
+	Liveness
+)
+
+// Checker checks one aspect of the health of a program.
+type Checker interface {
+	// Check returns an error describing why the check failed, or nil if it passed.
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is a function that implements Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// checkTimeout bounds how long each check of a request may take.
+const checkTimeout = 5 * time.Second
+
+// Registry holds the checks of a program. A Registry is safe for concurrent use.
+type Registry struct {
+	mu     sync.Mutex
+	checks map[Kind][]namedChecker
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// NewRegistry returns a new Registry without any checks.
+func NewRegistry() *Registry {
+	return &Registry{
+		checks: make(map[Kind][]namedChecker),
+	}
+}
+
+// Register registers a check of the given kind.
+//
+// The name identifies the check in responses, and should say what is checked, such as
+// "broker" or "consume-loop".
+func (r *Registry) Register(kind Kind, name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[kind] = append(r.checks[kind], namedChecker{name: name, checker: checker})
+}
+
+// Result is the result of one check.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Check runs every check of the given kind concurrently, and returns their results in the
+// order they were registered.
+func (r *Registry) Check(ctx context.Context, kind Kind) []Result {
+	r.mu.Lock()
+	checks := slices.Clone(r.checks[kind])
+	r.mu.Unlock()
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			results[i] = Result{Name: check.name, Err: check.checker.Check(ctx)}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Handler returns an HTTP handler that serves /healthz, /readyz, and /livez.
+//
+// /readyz and /livez respond with 200 if every check of their kind passes, and with 503
+// otherwise. The body lists the result of every check, one per line.
+func (r *Registry) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, request *http.Request) {
+		writeResults(w, r.Check(request.Context(), Readiness))
+	})
+	mux.HandleFunc("GET /livez", func(w http.ResponseWriter, request *http.Request) {
+		writeResults(w, r.Check(request.Context(), Liveness))
+	})
+	return mux
+}
+
+func writeResults(w http.ResponseWriter, results []Result) {
+	var body strings.Builder
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Err != nil {
+			status = http.StatusServiceUnavailable
+			fmt.Fprintf(&body, "[-] %s: %v\n", result.Name, result.Err)
+		} else {
+			fmt.Fprintf(&body, "[+] %s ok\n", result.Name)
+		}
+	}
+	if status == http.StatusOK {
+		body.WriteString("ok\n")
+	} else {
+		body.WriteString("failed\n")
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body.String()))
+}
+
+// BrokerReachable returns a Checker that passes if the client can reach any broker.
+func BrokerReachable(client *kgo.Client) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if err := client.Ping(ctx); err != nil {
+			return fmt.Errorf("no broker is reachable: %w", err)
+		}
+		return nil
+	})
+}
+
+// TopicExists returns a Checker that passes if the topic exists.
+func TopicExists(client *kgo.Client, topic string) Checker {
+	admClient := kadm.NewClient(client)
+	return CheckerFunc(func(ctx context.Context) error {
+		topicDetails, err := admClient.ListTopics(ctx, topic)
+		if err != nil {
+			return fmt.Errorf("failed to describe topic %s: %w", topic, err)
+		}
+		topicDetail, ok := topicDetails[topic]
+		if !ok {
+			return fmt.Errorf("topic %s does not exist", topic)
+		}
+		return topicDetail.Err
+	})
+}
+
+// GroupJoined returns a Checker that passes if the client is a member of its consumer group.
+//
+// A client joins its group shortly after it is created, and rejoins it on every rebalance, so
+// this check fails briefly in both cases.
+func GroupJoined(client *kgo.Client) Checker {
+	return CheckerFunc(func(context.Context) error {
+		group, _ := client.OptValue(kgo.ConsumerGroup).(string)
+		if group == "" {
+			return errors.New("client is not a group consumer")
+		}
+		if _, generation := client.GroupMetadata(); generation < 0 {
+			return fmt.Errorf("not a member of consumer group %s", group)
+		}
+		return nil
+	})
+}
+
+// Heartbeat is a Checker that passes as long as Beat was called recently, such as by a loop
+// on every iteration.
+type Heartbeat struct {
+	timeout time.Duration
+	last    atomic.Int64
+}
+
+// NewHeartbeat returns a new Heartbeat that fails once Beat has not been called for the given
+// timeout. The timeout starts when NewHeartbeat is called.
+func NewHeartbeat(timeout time.Duration) *Heartbeat {
+	heartbeat := &Heartbeat{timeout: timeout}
+	heartbeat.Beat()
+	return heartbeat
+}
+
+// Beat records that progress was made.
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Check implements Checker.
+func (h *Heartbeat) Check(context.Context) error {
+	if since := time.Since(time.Unix(0, h.last.Load())); since > h.timeout {
+		return fmt.Errorf("no progress for %v", since.Truncate(time.Second))
+	}
+	return nil
+}
+
+// RegisterClient registers the readiness checks of a Kafka client of the given topic: that a
+// broker is reachable, that the topic exists, and, for a group consumer, that it is a member
+// of its group.
+func RegisterClient(registry *Registry, client *kgo.Client, topic string) {
+	registry.Register(Readiness, "broker", BrokerReachable(client))
+	registry.Register(Readiness, "topic", TopicExists(client, topic))
+	if group, _ := client.OptValue(kgo.ConsumerGroup).(string); group != "" {
+		registry.Register(Readiness, "group", GroupJoined(client))
+	}
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// CompressionName is the name of a codec that produced record batches can be compressed
+// with.
+type CompressionName string
+
+const (
+	// CompressionNone sends record batches uncompressed.
+	CompressionNone CompressionName = "none"
+	// CompressionGzip compresses record batches with gzip, at levels 1 to 9.
+	CompressionGzip CompressionName = "gzip"
+	// CompressionSnappy compresses record batches with snappy, which has no levels. It is
+	// franz-go's default.
+	CompressionSnappy CompressionName = "snappy"
+	// CompressionLZ4 compresses record batches with lz4, at levels 1 to 9.
+	CompressionLZ4 CompressionName = "lz4"
+	// CompressionZstd compresses record batches with zstd, at levels 1 (fastest) to 4 (best
+	// compression).
+	CompressionZstd CompressionName = "zstd"
+)
+
+// maxCompressionLevels holds the highest level of each codec, or 0 if it has no levels.
+var maxCompressionLevels = map[CompressionName]int{
+	CompressionNone:   0,
+	CompressionGzip:   gzip.BestCompression,
+	CompressionSnappy: 0,
+	CompressionLZ4:    9,
+	CompressionZstd:   4,
+}
+
+// CompressionOpts returns the options that make a client compress the record batches it
+// produces with the named codec, at the given level. Level 0 is the codec's default level.
+func CompressionOpts(name CompressionName, level int) ([]kgo.Opt, error) {
+	maxLevel, ok := maxCompressionLevels[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q", name)
+	}
+	if level < 0 || level > maxLevel {
+		if maxLevel == 0 {
+			return nil, fmt.Errorf("compression %s has no levels", name)
+		}
+		return nil, fmt.Errorf("invalid level %d for compression %s: must be between 1 and %d", level, name, maxLevel)
+	}
+	var codec kgo.CompressionCodec
+	switch name {
+	case CompressionNone:
+		codec = kgo.NoCompression()
+	case CompressionGzip:
+		if level != 0 {
+			// franz-go ignores gzip levels, so compress with gzip ourselves.
+			return []kgo.Opt{kgo.WithCompressor(newGzipCompressor(level))}, nil
+		}
+		codec = kgo.GzipCompression()
+	case CompressionSnappy:
+		codec = kgo.SnappyCompression()
+	case CompressionLZ4:
+		codec = kgo.Lz4Compression()
+		if level != 0 {
+			// lz4 levels are powers of two, from 1<<9 for level 1 to 1<<17 for level 9.
+			codec = codec.WithLevel(1 << (8 + level))
+		}
+	case CompressionZstd:
+		codec = kgo.ZstdCompression().WithLevel(level)
+	}
+	return []kgo.Opt{kgo.ProducerBatchCompression(codec)}, nil
+}
+
+// gzipCompressor is a kgo.Compressor that compresses with gzip at a given level.
+type gzipCompressor struct {
+	writers sync.Pool
+}
+
+func newGzipCompressor(level int) *gzipCompressor {
+	return &gzipCompressor{
+		writers: sync.Pool{New: func() any {
+			// The level was validated by CompressionOpts.
+			writer, _ := gzip.NewWriterLevel(nil, level)
+			return writer
+		}},
+	}
+}
+
+func (c *gzipCompressor) Compress(dst *bytes.Buffer, src []byte, _ ...kgo.CompressFlag) ([]byte, kgo.CompressionCodecType) {
+	writer := c.writers.Get().(*gzip.Writer)
+	defer c.writers.Put(writer)
+	writer.Reset(dst)
+	if _, err := writer.Write(src); err != nil {
+		return nil, kgo.CodecError
+	}
+	if err := writer.Close(); err != nil {
+		return nil, kgo.CodecError
+	}
+	return dst.Bytes(), kgo.CodecGzip
+}
@@ -24,12 +24,24 @@ type Config struct {
 	ClientID         string
 	Topic            string
 	RecreateTopic    bool
+	ConfirmDelete    bool
 	TopicConfig      []string
 	TopicPartitions  int
+	TopicsFile       string
+	// Compression is the codec to compress produced record batches with. If empty, franz-go's
+	// default of snappy is used.
+	Compression CompressionName
+	// CompressionLevel is the level of Compression. If 0, the codec's default level is used.
+	CompressionLevel int
 }
 
 // NewKafkaClient returns a new franz-go Kafka Client for the given Config.
-func NewKafkaClient(config Config, consumer bool) (*kgo.Client, error) {
+//
+// If consumer is true, the client joins the Config's consumer group and consumes the
+// Config's topic. Any extra options are applied after the options derived from the Config,
+// which lets tools that need more control, such as consuming specific partitions without a
+// group, still share the same connection setup.
+func NewKafkaClient(config Config, consumer bool, extraOpts ...kgo.Opt) (*kgo.Client, error) {
 	opts := []kgo.Opt{
 		kgo.SeedBrokers(config.BootstrapServers...),
 		kgo.ClientID(config.ClientID),
@@ -45,6 +57,16 @@ func NewKafkaClient(config Config, consumer bool) (*kgo.Client, error) {
 		)
 	}
 
+	if config.Compression != "" {
+		compressionOpts, err := CompressionOpts(config.Compression, config.CompressionLevel)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, compressionOpts...)
+	} else if config.CompressionLevel != 0 {
+		return nil, errors.New("a compression level requires a compression")
+	}
+
 	if config.RootCAPath != "" {
 		dialerTLSConfig, err := buildDialerTLSConfig(config.RootCAPath)
 		if err != nil {
@@ -54,6 +76,7 @@ func NewKafkaClient(config Config, consumer bool) (*kgo.Client, error) {
 		opts = append(opts, kgo.DialTLSConfig(dialerTLSConfig))
 	}
 
+	opts = append(opts, extraOpts...)
 	return kgo.NewClient(opts...)
 }
 
@@ -0,0 +1,272 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+type positionKind int
+
+const (
+	positionEarliest positionKind = iota + 1
+	positionLatest
+	positionOffset
+	positionPartitionOffsets
+	positionTime
+)
+
+// Position is a position within the partitions of a topic, such as where to start or stop
+// consuming.
+//
+// Positions are resolved to concrete per-partition offsets with [ResolvePosition].
+type Position struct {
+	kind    positionKind
+	offset  int64
+	offsets map[int32]int64
+	time    time.Time
+}
+
+// PositionEarliest is the first available offset of every partition.
+var PositionEarliest = Position{kind: positionEarliest}
+
+// PositionLatest is the end offset of every partition.
+var PositionLatest = Position{kind: positionLatest}
+
+// PositionOffset returns a Position at the given offset in every partition.
+func PositionOffset(offset int64) Position {
+	return Position{kind: positionOffset, offset: offset}
+}
+
+// PositionPartitionOffsets returns a Position at the given offset in each given partition.
+//
+// Partitions that are not in offsets are omitted when the Position is resolved.
+func PositionPartitionOffsets(offsets map[int32]int64) Position {
+	return Position{kind: positionPartitionOffsets, offsets: offsets}
+}
+
+// PositionTime returns a Position at the first record at or after the given time in
+// every partition.
+func PositionTime(t time.Time) Position {
+	return Position{kind: positionTime, time: t}
+}
+
+// ParsePosition parses a Position from a flag value. The following forms are accepted:
+//
+//   - "earliest" or "latest".
+//   - An offset applied to every partition, such as "1000".
+//   - Comma-separated partition:offset pairs, such as "0:1000,1:1200".
+//   - An RFC 3339 timestamp, such as "2025-01-02T15:04:05Z".
+func ParsePosition(value string) (Position, error) {
+	switch value {
+	case "earliest":
+		return PositionEarliest, nil
+	case "latest":
+		return PositionLatest, nil
+	}
+	if offset, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if offset < 0 {
+			return Position{}, fmt.Errorf("invalid position %q: offset must not be negative", value)
+		}
+		return PositionOffset(offset), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return PositionTime(t), nil
+	}
+	if strings.Contains(value, ":") {
+		offsets := make(map[int32]int64)
+		for pair := range strings.SplitSeq(value, ",") {
+			partitionString, offsetString, _ := strings.Cut(pair, ":")
+			partition, err := strconv.ParseInt(partitionString, 10, 32)
+			if err != nil || partition < 0 {
+				return Position{}, fmt.Errorf("invalid position %q: bad partition %q", value, partitionString)
+			}
+			offset, err := strconv.ParseInt(offsetString, 10, 64)
+			if err != nil || offset < 0 {
+				return Position{}, fmt.Errorf("invalid position %q: bad offset %q", value, offsetString)
+			}
+			offsets[int32(partition)] = offset
+		}
+		return PositionPartitionOffsets(offsets), nil
+	}
+	return Position{}, fmt.Errorf(
+		"invalid position %q: expected earliest, latest, an offset, partition:offset pairs, or an RFC 3339 timestamp",
+		value,
+	)
+}
+
+// Set implements pflag.Value.
+func (p *Position) Set(value string) error {
+	position, err := ParsePosition(value)
+	if err != nil {
+		return err
+	}
+	*p = position
+	return nil
+}
+
+// Type implements pflag.Value.
+func (p *Position) Type() string {
+	return "position"
+}
+
+// IsZero returns true if the Position was never set.
+func (p Position) IsZero() bool {
+	return p.kind == 0
+}
+
+// String returns the Position in the form accepted by [ParsePosition].
+func (p Position) String() string {
+	switch p.kind {
+	case positionEarliest:
+		return "earliest"
+	case positionLatest:
+		return "latest"
+	case positionOffset:
+		return strconv.FormatInt(p.offset, 10)
+	case positionPartitionOffsets:
+		pairs := make([]string, 0, len(p.offsets))
+		for _, partition := range slices.Sorted(maps.Keys(p.offsets)) {
+			pairs = append(pairs, fmt.Sprintf("%d:%d", partition, p.offsets[partition]))
+		}
+		return strings.Join(pairs, ",")
+	case positionTime:
+		return p.time.Format(time.RFC3339)
+	default:
+		return ""
+	}
+}
+
+// ResolvePosition resolves a Position to an offset for each partition of the topic.
+//
+// Resolved offsets are always within the partition's available offsets: an offset before
+// the start of a partition resolves to its first available offset, and an offset past the
+// end resolves to its end offset. A time after the last record in a partition resolves to
+// the partition's end offset.
+func ResolvePosition(
+	ctx context.Context,
+	admClient *kadm.Client,
+	topic string,
+	position Position,
+) (map[int32]int64, error) {
+	startOffsets, err := listOffsets(ctx, admClient.ListStartOffsets, topic)
+	if err != nil {
+		return nil, err
+	}
+	endOffsets, err := listOffsets(ctx, admClient.ListEndOffsets, topic)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make(map[int32]int64, len(startOffsets))
+	switch position.kind {
+	case positionEarliest:
+		return startOffsets, nil
+	case positionLatest:
+		return endOffsets, nil
+	case positionOffset:
+		for partition, start := range startOffsets {
+			resolved[partition] = min(max(position.offset, start), endOffsets[partition])
+		}
+	case positionPartitionOffsets:
+		for partition, offset := range position.offsets {
+			start, ok := startOffsets[partition]
+			if !ok {
+				return nil, fmt.Errorf("topic %s has no partition %d", topic, partition)
+			}
+			resolved[partition] = min(max(offset, start), endOffsets[partition])
+		}
+	case positionTime:
+		timeOffsets, err := listOffsets(
+			ctx,
+			func(ctx context.Context, topics ...string) (kadm.ListedOffsets, error) {
+				return admClient.ListOffsetsAfterMilli(ctx, position.time.UnixMilli(), topics...)
+			},
+			topic,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for partition, offset := range timeOffsets {
+			if offset < 0 {
+				offset = endOffsets[partition]
+			}
+			resolved[partition] = offset
+		}
+	default:
+		return nil, errors.New("position is not set")
+	}
+	return resolved, nil
+}
+
+// OffsetRange is a range of offsets within a partition, from Start (inclusive) to End
+// (exclusive).
+type OffsetRange struct {
+	Start int64
+	End   int64
+}
+
+// ResolveRange resolves a start and an end Position to the range of offsets between them in
+// each partition of the topic. Partitions that the end Position omits end at their end
+// offset, and partitions without any offsets between start and end are omitted.
+//
+// Ends are capped at the partition's last stable offset, as records after it cannot be read
+// with the read-committed isolation level until their transactions complete, so a consumer
+// could otherwise wait for them forever.
+func ResolveRange(
+	ctx context.Context,
+	admClient *kadm.Client,
+	topic string,
+	start Position,
+	end Position,
+) (map[int32]OffsetRange, error) {
+	startOffsets, err := ResolvePosition(ctx, admClient, topic, start)
+	if err != nil {
+		return nil, err
+	}
+	endOffsets, err := ResolvePosition(ctx, admClient, topic, end)
+	if err != nil {
+		return nil, err
+	}
+	stableOffsets, err := listOffsets(ctx, admClient.ListCommittedOffsets, topic)
+	if err != nil {
+		return nil, err
+	}
+	ranges := make(map[int32]OffsetRange, len(startOffsets))
+	for partition, startOffset := range startOffsets {
+		endOffset, ok := endOffsets[partition]
+		if !ok {
+			endOffset = stableOffsets[partition]
+		}
+		endOffset = min(endOffset, stableOffsets[partition])
+		if startOffset < endOffset {
+			ranges[partition] = OffsetRange{Start: startOffset, End: endOffset}
+		}
+	}
+	return ranges, nil
+}
+
+func listOffsets(
+	ctx context.Context,
+	list func(context.Context, ...string) (kadm.ListedOffsets, error),
+	topic string,
+) (map[int32]int64, error) {
+	listedOffsets, err := list(ctx, topic)
+	if err == nil {
+		err = listedOffsets.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets for topic %s: %w", topic, err)
+	}
+	offsets := make(map[int32]int64)
+	listedOffsets.Each(func(listedOffset kadm.ListedOffset) {
+		offsets[listedOffset.Partition] = listedOffset.Offset
+	})
+	return offsets, nil
+}
@@ -0,0 +1,146 @@
+// Package logging implements slog handlers shared by the producer and consumer.
+//
+// Attributes that identify what a log line is about, such as the topic, partition, and offset
+// of the record being handled, are carried in the context with WithAttrs rather than repeated
+// at every call site. Any handler wrapped with NewContextHandler adds them to every line
+// logged with that context, such as with slog.InfoContext.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// WithAttrs returns a copy of ctx whose log lines include the given attributes, in addition
+// to any attributes already in ctx.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsContextKey{}).([]slog.Attr)
+	return context.WithValue(ctx, attrsContextKey{}, append(slices.Clip(existing), attrs...))
+}
+
+type attrsContextKey struct{}
+
+// RecordAttrs returns the attributes that identify a record: its topic, partition, and
+// offset.
+func RecordAttrs(record *kgo.Record) []slog.Attr {
+	return []slog.Attr{
+		slog.String("topic", record.Topic),
+		slog.Int("partition", int(record.Partition)),
+		slog.Int64("offset", record.Offset),
+	}
+}
+
+// NewContextHandler returns a handler that adds the attributes of the context, see WithAttrs,
+// to every record before passing it to handler.
+func NewContextHandler(handler slog.Handler) slog.Handler {
+	return contextHandler{handler: handler}
+}
+
+type contextHandler struct {
+	handler slog.Handler
+}
+
+func (h contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsContextKey{}).([]slog.Attr); ok && len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{handler: h.handler.WithGroup(name)}
+}
+
+// Sampling limits how many lines with the same message are logged.
+//
+// In every interval, the first First lines with a given message are logged, and after that
+// only every Thereafter-th line. Warnings and errors are never sampled.
+type Sampling struct {
+	First      int
+	Thereafter int
+	Interval   time.Duration
+}
+
+// NewSamplingHandler returns a handler that passes records to handler, dropping those that
+// the sampling excludes.
+func NewSamplingHandler(handler slog.Handler, sampling Sampling) slog.Handler {
+	return samplingHandler{
+		handler:  handler,
+		sampling: sampling,
+		counts:   &sampleCounts{counts: make(map[string]int)},
+	}
+}
+
+type samplingHandler struct {
+	handler  slog.Handler
+	sampling Sampling
+	// counts is shared by the handlers derived with WithAttrs and WithGroup, so that the same
+	// line is sampled the same way whichever logger logs it.
+	counts *sampleCounts
+}
+
+type sampleCounts struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func (h samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn || h.counts.sample(record.Message, record.Time, h.sampling) {
+		return h.handler.Handle(ctx, record)
+	}
+	return nil
+}
+
+func (h samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return samplingHandler{handler: h.handler.WithAttrs(attrs), sampling: h.sampling, counts: h.counts}
+}
+
+func (h samplingHandler) WithGroup(name string) slog.Handler {
+	return samplingHandler{handler: h.handler.WithGroup(name), sampling: h.sampling, counts: h.counts}
+}
+
+// sample returns true if the line with the given message should be logged.
+func (c *sampleCounts) sample(message string, now time.Time, sampling Sampling) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.windowStart) >= sampling.Interval {
+		c.windowStart = now
+		clear(c.counts)
+	}
+	c.counts[message]++
+	n := c.counts[message]
+	if n <= sampling.First {
+		return true
+	}
+	return sampling.Thereafter > 0 && (n-sampling.First)%sampling.Thereafter == 0
+}
+
+// ParseLevel parses a level such as "debug", "info", "warn", or "error".
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: must be debug, info, warn, or error", s)
+	}
+	return level, nil
+}
@@ -0,0 +1,67 @@
+package produce
+
+import (
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// PartitionerName is the name of a partitioner that can be created with NewPartitioner.
+type PartitionerName string
+
+const (
+	// PartitionerMurmur2 hashes keys with murmur2, exactly like the Java client's default
+	// partitioner, so records with the same key land on the same partition no matter which
+	// client produced them. Records without a key stick to one partition per batch.
+	PartitionerMurmur2 PartitionerName = "murmur2"
+	// PartitionerRoundRobin ignores keys and sends each record to the next partition.
+	PartitionerRoundRobin PartitionerName = "round-robin"
+	// PartitionerLeastBackup ignores keys and sends each record to the partition with the
+	// fewest buffered records, which steers load away from slow brokers.
+	PartitionerLeastBackup PartitionerName = "least-backup"
+	// PartitionerManual sends each record to the partition set on it. See WithPartition.
+	PartitionerManual PartitionerName = "manual"
+)
+
+// NewPartitioner returns the partitioner with the given name.
+func NewPartitioner(name PartitionerName) (kgo.Partitioner, error) {
+	switch name {
+	case PartitionerMurmur2:
+		return kgo.StickyKeyPartitioner(nil), nil
+	case PartitionerRoundRobin:
+		return kgo.RoundRobinPartitioner(), nil
+	case PartitionerLeastBackup:
+		return kgo.LeastBackupPartitioner(), nil
+	case PartitionerManual:
+		return kgo.ManualPartitioner(), nil
+	default:
+		return nil, fmt.Errorf("unknown partitioner %q", name)
+	}
+}
+
+// NewTopicPartitioner returns a partitioner that partitions the records of topic with
+// partitioner, and the records of every other topic with the client's default partitioner.
+//
+// This lets a client that is shared by several producers use, for example, a manual
+// partitioner for one topic without sending the records of every other topic to partition 0.
+func NewTopicPartitioner(topic string, partitioner kgo.Partitioner) kgo.Partitioner {
+	return &topicPartitioner{
+		topic:       topic,
+		partitioner: partitioner,
+		// The default of kgo.RecordPartitioner.
+		fallback: kgo.UniformBytesPartitioner(64<<10, true, true, nil),
+	}
+}
+
+type topicPartitioner struct {
+	topic       string
+	partitioner kgo.Partitioner
+	fallback    kgo.Partitioner
+}
+
+func (p *topicPartitioner) ForTopic(topic string) kgo.TopicPartitioner {
+	if topic == p.topic {
+		return p.partitioner.ForTopic(topic)
+	}
+	return p.fallback.ForTopic(topic)
+}
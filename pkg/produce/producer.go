@@ -4,7 +4,10 @@ package produce
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
+	"github.com/bufbuild/bufstream-demo/pkg/encryption"
+	"github.com/bufbuild/bufstream-demo/pkg/logging"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"google.golang.org/protobuf/proto"
 )
@@ -19,8 +22,11 @@ import (
 // This is a toy example, but shows the basics you need to send Protobuf messages
 // to Kafka using franz-go.
 type Producer[M proto.Message] struct {
-	client *kgo.Client
-	topic  string
+	client    *kgo.Client
+	topic     string
+	partition int32
+	// encryption encrypts every value before it is sent. It is nil if values are sent as is.
+	encryption *encryption.Envelope
 }
 
 // NewProducer returns a new Producer.
@@ -29,40 +35,133 @@ type Producer[M proto.Message] struct {
 func NewProducer[M proto.Message](
 	client *kgo.Client,
 	topic string,
+	options ...ProducerOption[M],
 ) *Producer[M] {
-	return &Producer[M]{
+	producer := &Producer[M]{
 		client: client,
 		topic:  topic,
 	}
+	for _, option := range options {
+		option(producer)
+	}
+	return producer
+}
+
+// ProducerOption is an option when constructing a new Producer.
+//
+// All parameters except options are required. ProducerOptions allow
+// for optional parameters.
+type ProducerOption[M proto.Message] func(*Producer[M])
+
+// WithPartition returns a new ProducerOption that sends every record to the given
+// partition.
+//
+// The partition is only used if the client partitions the producer's topic with
+// kgo.ManualPartitioner. Other partitioners choose the partition themselves.
+func WithPartition[M proto.Message](partition int32) ProducerOption[M] {
+	return func(producer *Producer[M]) {
+		producer.partition = partition
+	}
+}
+
+// WithEncryption returns a new ProducerOption that encrypts the value of every record with the
+// given Envelope, and adds the headers needed to decrypt it. See the encryption package.
+//
+// Bufstream cannot validate encrypted values, so only use this for topics without
+// buf.registry.value.schema.message.
+func WithEncryption[M proto.Message](envelope *encryption.Envelope) ProducerOption[M] {
+	return func(producer *Producer[M]) {
+		producer.encryption = envelope
+	}
 }
 
 // ProduceProtobufMessage serializes the given Protobuf messages, and synchronously
-// sends it to the Producer's topic with the given key.
-func (p *Producer[M]) ProduceProtobufMessage(ctx context.Context, key string, message M) error {
+// sends it to the Producer's topic with the given key and optional headers.
+//
+// If the key is empty, the record is sent without a key, and the client's partitioner
+// decides how to spread keyless records across partitions.
+func (p *Producer[M]) ProduceProtobufMessage(
+	ctx context.Context,
+	key string,
+	message M,
+	headers ...kgo.RecordHeader,
+) error {
+	_, err := p.ProduceProtobufMessageRecord(ctx, key, message, headers...)
+	return err
+}
+
+// ProduceProtobufMessageRecord is like ProduceProtobufMessage, but also returns the produced
+// record, which holds the partition and offset it was assigned.
+func (p *Producer[M]) ProduceProtobufMessageRecord(
+	ctx context.Context,
+	key string,
+	message M,
+	headers ...kgo.RecordHeader,
+) (*kgo.Record, error) {
 	payload, err := proto.Marshal(message)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return p.produce(ctx, key, payload)
+	return p.produce(ctx, key, payload, headers)
+}
+
+// NewProtobufMessageRecord returns the record that ProduceProtobufMessage would send, without
+// sending it, such as to produce many records asynchronously.
+func (p *Producer[M]) NewProtobufMessageRecord(
+	ctx context.Context,
+	key string,
+	message M,
+	headers ...kgo.RecordHeader,
+) (*kgo.Record, error) {
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return p.newRecord(ctx, key, payload, headers)
 }
 
 // ProduceInvalid synchronously sends data to the Producer's topic that could
 // never be interpreted as a Protobuf message.
 func (p *Producer[M]) ProduceInvalid(ctx context.Context, key string) error {
-	return p.produce(ctx, key, []byte("\x00foobar"))
+	_, err := p.produce(ctx, key, []byte("\x00foobar"), nil)
+	return err
 }
 
-func (p *Producer[M]) produce(ctx context.Context, key string, payload []byte) error {
-	produceResults := p.client.ProduceSync(
-		ctx,
-		&kgo.Record{
-			Key:   []byte(key),
-			Value: payload,
-			Topic: p.topic,
-		},
-	)
-	if err := produceResults.FirstErr(); err != nil {
-		return fmt.Errorf("failed to produce: %w", err)
+func (p *Producer[M]) produce(ctx context.Context, key string, payload []byte, headers []kgo.RecordHeader) (*kgo.Record, error) {
+	record, err := p.newRecord(ctx, key, payload, headers)
+	if err != nil {
+		return nil, err
+	}
+	record, err = p.client.ProduceSync(ctx, record).First()
+	if err != nil {
+		return nil, fmt.Errorf("failed to produce to topic %s: %w", p.topic, err)
+	}
+	slog.DebugContext(logging.WithAttrs(ctx, logging.RecordAttrs(record)...), "produced record", "key", key)
+	return record, nil
+}
+
+// newRecord returns the record to send with the given key, payload, and headers, encrypting
+// the payload if the Producer has encryption.
+func (p *Producer[M]) newRecord(ctx context.Context, key string, payload []byte, headers []kgo.RecordHeader) (*kgo.Record, error) {
+	if p.encryption != nil {
+		encrypted, encryptionHeaders, err := p.encryption.Encrypt(ctx, p.topic, payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt record value: %w", err)
+		}
+		// Headers copied from an encrypted record, such as one being forwarded, describe the
+		// old encryption rather than this one.
+		payload = encrypted
+		headers = append(encryption.StripHeaders(headers), encryptionHeaders...)
+	}
+	var recordKey []byte
+	if key != "" {
+		recordKey = []byte(key)
 	}
-	return nil
+	return &kgo.Record{
+		Key:       recordKey,
+		Value:     payload,
+		Headers:   headers,
+		Topic:     p.topic,
+		Partition: p.partition,
+	}, nil
 }
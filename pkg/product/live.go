@@ -0,0 +1,240 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"github.com/bufbuild/bufstream-demo/pkg/consume"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/produce"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"google.golang.org/protobuf/proto"
+)
+
+// CatalogTopicSpec returns the Spec of a catalog topic with the given name.
+//
+// A catalog topic is compacted and keyed by product_id, so it always retains the latest
+// version of every product: it is a changelog of the catalog table.
+func CatalogTopicSpec(name string) topics.Spec {
+	compact := "compact"
+	schema := string((&demov1.Product{}).ProtoReflect().Descriptor().FullName())
+	return topics.Spec{
+		Name: name,
+		Configs: map[string]*string{
+			"cleanup.policy":                &compact,
+			topics.ValueSchemaMessageConfig: &schema,
+		},
+	}
+}
+
+// Publish produces every product to a catalog topic, keyed by product_id.
+func Publish(ctx context.Context, producer *produce.Producer[*demov1.Product], products []*demov1.Product) error {
+	for _, product := range products {
+		if err := producer.ProduceProtobufMessage(ctx, product.GetProductId(), product); err != nil {
+			return fmt.Errorf("failed to publish product %s: %w", product.GetProductId(), err)
+		}
+	}
+	return nil
+}
+
+// CatalogChange is a change to a LiveCatalog.
+type CatalogChange struct {
+	// ProductID is the product_id of the changed product.
+	ProductID string
+	// Old is the product before the change, or nil if it was added.
+	Old *demov1.Product
+	// New is the product after the change, or nil if it was removed.
+	New *demov1.Product
+}
+
+// LiveCatalog is an in-memory view of a catalog topic that is kept up to date as the topic
+// changes. It is safe for concurrent use.
+//
+// Products returned from a LiveCatalog are shared and must not be modified. To change a
+// product, publish a modified copy to the catalog topic instead.
+type LiveCatalog struct {
+	lock        sync.RWMutex
+	products    map[string]*demov1.Product
+	productIDs  []string
+	subscribers map[int]func(CatalogChange)
+	nextID      int
+	ready       chan struct{}
+}
+
+// NewLiveCatalog returns a new, empty LiveCatalog. Call Follow to fill it.
+func NewLiveCatalog() *LiveCatalog {
+	return &LiveCatalog{
+		products:    make(map[string]*demov1.Product),
+		subscribers: make(map[int]func(CatalogChange)),
+		ready:       make(chan struct{}),
+	}
+}
+
+// Follow consumes the catalog topic named by config.Topic into the LiveCatalog until ctx
+// is done.
+//
+// Every instance reads the entire topic, so Follow does not use a consumer group. It first
+// loads every product that is already in the topic, then closes the Ready channel, and then
+// keeps applying changes as they arrive.
+func (c *LiveCatalog) Follow(ctx context.Context, config kafka.Config) error {
+	endOffsets, err := c.resolveEndOffsets(ctx, config)
+	if err != nil {
+		return err
+	}
+	end := kafka.PositionPartitionOffsets(endOffsets)
+	if err := c.consume(ctx, config, kafka.PositionEarliest, end); err != nil {
+		return err
+	}
+	close(c.ready)
+	return c.consume(ctx, config, end, kafka.Position{})
+}
+
+// Ready returns a channel that is closed once Follow has loaded every product that was in
+// the catalog topic when it started.
+func (c *LiveCatalog) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// Get returns the product with the given product_id.
+func (c *LiveCatalog) Get(productID string) (*demov1.Product, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	product, ok := c.products[productID]
+	return product, ok
+}
+
+// Len returns the number of products in the catalog.
+func (c *LiveCatalog) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.productIDs)
+}
+
+// Products returns a snapshot of every product in the catalog, ordered by product_id.
+func (c *LiveCatalog) Products() []*demov1.Product {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	products := make([]*demov1.Product, 0, len(c.productIDs))
+	for _, productID := range c.productIDs {
+		products = append(products, c.products[productID])
+	}
+	return products
+}
+
+// Random returns a randomly selected product, or nil if the catalog is empty.
+func (c *LiveCatalog) Random() *demov1.Product {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if len(c.productIDs) == 0 {
+		return nil
+	}
+	return c.products[c.productIDs[rand.IntN(len(c.productIDs))]]
+}
+
+// Subscribe registers fn to be called with every subsequent change to the catalog, and
+// returns a function that unregisters it.
+//
+// fn is called synchronously as changes are applied, so it must not block or call back into
+// the LiveCatalog.
+func (c *LiveCatalog) Subscribe(fn func(CatalogChange)) func() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	id := c.nextID
+	c.nextID++
+	c.subscribers[id] = fn
+	return func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		delete(c.subscribers, id)
+	}
+}
+
+// apply sets the product with the given record key, or removes it if product is nil.
+func (c *LiveCatalog) apply(productID string, product *demov1.Product) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	old, existed := c.products[productID]
+	index, _ := slices.BinarySearch(c.productIDs, productID)
+	switch {
+	case product == nil && !existed:
+		return
+	case product == nil:
+		delete(c.products, productID)
+		c.productIDs = slices.Delete(c.productIDs, index, index+1)
+	case !existed:
+		c.products[productID] = product
+		c.productIDs = slices.Insert(c.productIDs, index, productID)
+	default:
+		if proto.Equal(old, product) {
+			return
+		}
+		c.products[productID] = product
+	}
+	change := CatalogChange{ProductID: productID, Old: old, New: product}
+	for _, subscriber := range c.subscribers {
+		subscriber(change)
+	}
+}
+
+func (c *LiveCatalog) handleProduct(ctx context.Context, product *demov1.Product) error {
+	record, ok := consume.RecordFromContext(ctx)
+	if !ok {
+		return errors.New("no record in context")
+	}
+	// The topic is compacted by record key, so the key, rather than the value, identifies the
+	// product that a record replaces or removes.
+	productID := string(record.Key)
+	if record.Value == nil {
+		// A tombstone: the product was removed from the catalog.
+		c.apply(productID, nil)
+		return nil
+	}
+	if product.GetProductId() != productID {
+		slog.WarnContext(ctx, "catalog record key does not match its product_id", "key", productID, "product_id", product.GetProductId())
+	}
+	c.apply(productID, product)
+	return nil
+}
+
+func (c *LiveCatalog) consume(ctx context.Context, config kafka.Config, start kafka.Position, end kafka.Position) error {
+	client, err := consume.NewKafkaClient(config, start)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	consumer := consume.NewConsumer(
+		client,
+		config.Topic,
+		consume.WithMessageHandler(c.handleProduct),
+		consume.WithStartPosition[*demov1.Product](start),
+		consume.WithEndPosition[*demov1.Product](end),
+	)
+	for {
+		if err := consumer.Consume(ctx); err != nil {
+			if errors.Is(err, consume.ErrEndReached) {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (c *LiveCatalog) resolveEndOffsets(ctx context.Context, config kafka.Config) (map[int32]int64, error) {
+	client, err := kafka.NewKafkaClient(config, false)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return kafka.ResolvePosition(ctx, kadm.NewClient(client), config.Topic, kafka.PositionLatest)
+}
@@ -0,0 +1,244 @@
+package product
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"buf.build/go/protovalidate"
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"go.yaml.in/yaml/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// CatalogFormat is the file format of a catalog.
+type CatalogFormat string
+
+const (
+	// CatalogFormatJSON is a JSON array of Products, each in protojson form.
+	CatalogFormatJSON CatalogFormat = "json"
+	// CatalogFormatJSONL is one protojson-encoded Product per line.
+	CatalogFormatJSONL CatalogFormat = "jsonl"
+	// CatalogFormatYAML is a YAML sequence of Products, using the same field names as protojson.
+	CatalogFormatYAML CatalogFormat = "yaml"
+	// CatalogFormatCSV is a CSV file with a header row naming the columns product_id, sku,
+	// name, category_id, category_name, and unit_price_cents, in any order.
+	CatalogFormatCSV CatalogFormat = "csv"
+)
+
+// csvColumns are the columns required in a CSV catalog.
+var csvColumns = []string{"product_id", "sku", "name", "category_id", "category_name", "unit_price_cents"}
+
+// LoadCatalog loads a catalog from a file, choosing the format from the file's extension:
+// .json, .jsonl, .yaml or .yml, or .csv.
+//
+// See ParseCatalog for how catalogs are validated.
+func LoadCatalog(path string) ([]*demov1.Product, error) {
+	var format CatalogFormat
+	switch extension := strings.ToLower(filepath.Ext(path)); extension {
+	case ".json":
+		format = CatalogFormatJSON
+	case ".jsonl", ".ndjson":
+		format = CatalogFormatJSONL
+	case ".yaml", ".yml":
+		format = CatalogFormatYAML
+	case ".csv":
+		format = CatalogFormatCSV
+	default:
+		return nil, fmt.Errorf("unknown catalog file extension %q", extension)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	products, err := ParseCatalog(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load catalog %s: %w", path, err)
+	}
+	return products, nil
+}
+
+// ParseCatalog parses a catalog in the given format, and validates it with ValidateCatalog.
+func ParseCatalog(data []byte, format CatalogFormat) ([]*demov1.Product, error) {
+	var products []*demov1.Product
+	var err error
+	switch format {
+	case CatalogFormatJSON:
+		products, err = parseJSONCatalog(data)
+	case CatalogFormatJSONL:
+		products, err = parseJSONLCatalog(data)
+	case CatalogFormatYAML:
+		products, err = parseYAMLCatalog(data)
+	case CatalogFormatCSV:
+		products, err = parseCSVCatalog(data)
+	default:
+		err = fmt.Errorf("unknown catalog format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateCatalog(products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ValidateCatalog validates every Product and its Category with Protovalidate, and checks
+// that no two Products share a product_id or sku, and that Products in the same Category
+// agree on its name.
+//
+// All problems are reported, not just the first.
+func ValidateCatalog(products []*demov1.Product) error {
+	if len(products) == 0 {
+		return errors.New("catalog has no products")
+	}
+	var errs []error
+	productIDs := make(map[string]int, len(products))
+	skus := make(map[string]int, len(products))
+	categoryNames := make(map[string]string)
+	for i, product := range products {
+		if err := protovalidate.Validate(product); err != nil {
+			errs = append(errs, fmt.Errorf("product %d: %w", i, err))
+			continue
+		}
+		if j, ok := productIDs[product.GetProductId()]; ok {
+			errs = append(errs, fmt.Errorf("product %d: product_id %s is already used by product %d", i, product.GetProductId(), j))
+		} else {
+			productIDs[product.GetProductId()] = i
+		}
+		if j, ok := skus[product.GetSku()]; ok {
+			errs = append(errs, fmt.Errorf("product %d: sku %s is already used by product %d", i, product.GetSku(), j))
+		} else {
+			skus[product.GetSku()] = i
+		}
+		category := product.GetCategory()
+		if name, ok := categoryNames[category.GetId()]; ok && name != category.GetName() {
+			errs = append(errs, fmt.Errorf("product %d: category %s is named both %q and %q", i, category.GetId(), name, category.GetName()))
+		} else {
+			categoryNames[category.GetId()] = category.GetName()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func parseJSONCatalog(data []byte) ([]*demov1.Product, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of products: %w", err)
+	}
+	products := make([]*demov1.Product, 0, len(elements))
+	for i, element := range elements {
+		product := &demov1.Product{}
+		if err := protojson.Unmarshal(element, product); err != nil {
+			return nil, fmt.Errorf("product %d: %w", i, err)
+		}
+		products = append(products, product)
+	}
+	return products, nil
+}
+
+func parseJSONLCatalog(data []byte) ([]*demov1.Product, error) {
+	var products []*demov1.Product
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		product := &demov1.Product{}
+		if err := protojson.Unmarshal(line, product); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		products = append(products, product)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func parseYAMLCatalog(data []byte) ([]*demov1.Product, error) {
+	// YAML is converted element by element to JSON, so that it can be parsed with
+	// protojson and share its field names and rules.
+	var elements []map[string]any
+	if err := yaml.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("expected a YAML sequence of products: %w", err)
+	}
+	products := make([]*demov1.Product, 0, len(elements))
+	for i, element := range elements {
+		data, err := json.Marshal(element)
+		if err != nil {
+			return nil, fmt.Errorf("product %d: %w", i, err)
+		}
+		product := &demov1.Product{}
+		if err := protojson.Unmarshal(data, product); err != nil {
+			return nil, fmt.Errorf("product %d: %w", i, err)
+		}
+		products = append(products, product)
+	}
+	return products, nil
+}
+
+func parseCSVCatalog(data []byte) ([]*demov1.Product, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range csvColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header is missing column %s", name)
+		}
+	}
+	// Products in the same category share a single Category message.
+	categories := make(map[string]*demov1.Category)
+	var products []*demov1.Product
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		unitPriceCents, err := strconv.ParseUint(row[columns["unit_price_cents"]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid unit_price_cents: %w", line, err)
+		}
+		categoryID := row[columns["category_id"]]
+		category, ok := categories[categoryID]
+		if !ok {
+			category = &demov1.Category{
+				Id:   categoryID,
+				Name: row[columns["category_name"]],
+			}
+			categories[categoryID] = category
+		} else if category.GetName() != row[columns["category_name"]] {
+			return nil, fmt.Errorf("line %d: category %s is named both %q and %q", line, categoryID, category.GetName(), row[columns["category_name"]])
+		}
+		products = append(products, &demov1.Product{
+			ProductId:      row[columns["product_id"]],
+			Sku:            row[columns["sku"]],
+			Name:           row[columns["name"]],
+			Category:       category,
+			UnitPriceCents: unitPriceCents,
+		})
+	}
+	return products, nil
+}
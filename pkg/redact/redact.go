@@ -0,0 +1,153 @@
+// Package redact implements redaction of the sensitive fields of Protobuf messages.
+//
+// A field is sensitive if it is annotated with the bufstream.demo.v1.sensitive option:
+//
+//	string email = 2 [(bufstream.demo.v1.sensitive) = true];
+//
+// Messages are walked with protoreflect, so any message can be redacted, including messages
+// nested in lists and maps. Redact a message before showing it to anyone who should not see
+// its sensitive data, such as when logging it, or printing a DLQ record.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Mode is how sensitive fields are redacted.
+type Mode string
+
+const (
+	// ModeMask replaces sensitive strings with a fixed mask, so that it is clear that a value
+	// was there, but not how long it was. Other sensitive fields are cleared.
+	ModeMask Mode = "mask"
+	// ModeHash replaces sensitive strings and bytes with their SHA-256 hash, so that values
+	// can still be matched across messages without being shown. Other sensitive fields are
+	// cleared. Values that are easy to guess, such as email addresses, can be recovered from
+	// their hash by hashing guesses, so only use this for engineers who may be trusted with
+	// that.
+	ModeHash Mode = "hash"
+	// ModeDrop clears sensitive fields.
+	ModeDrop Mode = "drop"
+)
+
+// mask is what ModeMask replaces sensitive strings with.
+const mask = "***"
+
+// ParseMode returns the Mode with the given name.
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(name); mode {
+	case ModeMask, ModeHash, ModeDrop:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown redaction mode %q, expected %q, %q, or %q", name, ModeMask, ModeHash, ModeDrop)
+	}
+}
+
+// IsSensitive returns true if the field is annotated as sensitive.
+func IsSensitive(field protoreflect.FieldDescriptor) bool {
+	sensitive, _ := proto.GetExtension(field.Options(), demov1.E_Sensitive).(bool)
+	return sensitive
+}
+
+// Redact returns a copy of the message with every sensitive field redacted in the given mode.
+// The message itself is never modified.
+//
+// Unknown fields are dropped, as they may be sensitive fields of a newer version of the
+// schema.
+func Redact[M proto.Message](message M, mode Mode) M {
+	if !message.ProtoReflect().IsValid() {
+		return message
+	}
+	redacted := proto.Clone(message)
+	redactMessage(redacted.ProtoReflect(), mode)
+	return redacted.(M)
+}
+
+func redactMessage(message protoreflect.Message, mode Mode) {
+	message.SetUnknown(nil)
+	// Fields are redacted once Range is done, as fields must not be set while ranging over them.
+	var fields []protoreflect.FieldDescriptor
+	message.Range(func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, field)
+		return true
+	})
+	for _, field := range fields {
+		value := message.Get(field)
+		if IsSensitive(field) {
+			redactField(message, field, value, mode)
+			continue
+		}
+		if field.Message() == nil {
+			continue
+		}
+		switch {
+		case field.IsList():
+			list := value.List()
+			for i := range list.Len() {
+				redactMessage(list.Get(i).Message(), mode)
+			}
+		case field.IsMap():
+			if field.MapValue().Message() != nil {
+				value.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+					redactMessage(value.Message(), mode)
+					return true
+				})
+			}
+		default:
+			redactMessage(value.Message(), mode)
+		}
+	}
+}
+
+// redactField redacts a sensitive field that is set.
+func redactField(message protoreflect.Message, field protoreflect.FieldDescriptor, value protoreflect.Value, mode Mode) {
+	if mode == ModeDrop || field.IsMap() {
+		message.Clear(field)
+		return
+	}
+	if field.IsList() {
+		list := value.List()
+		for i := range list.Len() {
+			redacted, ok := redactScalar(field, list.Get(i), mode)
+			if !ok {
+				message.Clear(field)
+				return
+			}
+			list.Set(i, redacted)
+		}
+		return
+	}
+	redacted, ok := redactScalar(field, value, mode)
+	if !ok {
+		message.Clear(field)
+		return
+	}
+	message.Set(field, redacted)
+}
+
+// redactScalar returns the redacted value of a string or bytes field, and false if the field
+// has another kind, and must be cleared instead.
+func redactScalar(field protoreflect.FieldDescriptor, value protoreflect.Value, mode Mode) (protoreflect.Value, bool) {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		if mode == ModeHash {
+			sum := sha256.Sum256([]byte(value.String()))
+			return protoreflect.ValueOfString(hex.EncodeToString(sum[:])), true
+		}
+		return protoreflect.ValueOfString(mask), true
+	case protoreflect.BytesKind:
+		if mode == ModeHash {
+			sum := sha256.Sum256(value.Bytes())
+			return protoreflect.ValueOfBytes(sum[:]), true
+		}
+		return protoreflect.Value{}, false
+	default:
+		return protoreflect.Value{}, false
+	}
+}
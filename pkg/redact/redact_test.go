@@ -0,0 +1,263 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	demov1 "github.com/bufbuild/bufstream-demo/gen/bufstream/demo/v1"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// testMessage is a message with sensitive fields of every shape, nested in lists and maps:
+//
+//	message Account {
+//	  string id = 1;
+//	  string email = 2 [(sensitive) = true];
+//	  bytes token = 3 [(sensitive) = true];
+//	  int64 pin = 4 [(sensitive) = true];
+//	  repeated string phones = 5 [(sensitive) = true];
+//	  map<string, string> labels = 6 [(sensitive) = true];
+//	  Account parent = 7;
+//	  repeated Account children = 8;
+//	  map<string, Account> by_name = 9;
+//	}
+var testMessage = newTestMessageDescriptor()
+
+const testInput = `
+id: "a"
+email: "a@example.com"
+token: "secret"
+pin: 1234
+phones: "555-0100"
+phones: "555-0101"
+labels: { key: "tier" value: "gold" }
+parent: { id: "p" email: "p@example.com" }
+children: { id: "c1" email: "c1@example.com" }
+children: { id: "c2" phones: "555-0102" }
+by_name: { key: "n" value: { id: "n" email: "n@example.com" } }
+`
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		mode Mode
+		want string
+	}{
+		{
+			mode: ModeMask,
+			want: `
+id: "a"
+email: "***"
+phones: "***"
+phones: "***"
+parent: { id: "p" email: "***" }
+children: { id: "c1" email: "***" }
+children: { id: "c2" phones: "***" }
+by_name: { key: "n" value: { id: "n" email: "***" } }
+`,
+		},
+		{
+			mode: ModeHash,
+			want: `
+id: "a"
+email: "` + hashString("a@example.com") + `"
+token: "` + hashBytes("secret") + `"
+phones: "` + hashString("555-0100") + `"
+phones: "` + hashString("555-0101") + `"
+parent: { id: "p" email: "` + hashString("p@example.com") + `" }
+children: { id: "c1" email: "` + hashString("c1@example.com") + `" }
+children: { id: "c2" phones: "` + hashString("555-0102") + `" }
+by_name: { key: "n" value: { id: "n" email: "` + hashString("n@example.com") + `" } }
+`,
+		},
+		{
+			mode: ModeDrop,
+			want: `
+id: "a"
+parent: { id: "p" }
+children: { id: "c1" }
+children: { id: "c2" }
+by_name: { key: "n" value: { id: "n" } }
+`,
+		},
+	} {
+		t.Run(string(test.mode), func(t *testing.T) {
+			t.Parallel()
+			input := newTestMessage(t, testInput)
+			original := proto.Clone(input)
+			redacted := Redact(input, test.mode)
+			if want := newTestMessage(t, test.want); !proto.Equal(redacted, want) {
+				t.Errorf("redacted to:\n%v\nwant:\n%v", prototext.Format(redacted), prototext.Format(want))
+			}
+			if !proto.Equal(input, original) {
+				t.Errorf("input was modified to:\n%v", prototext.Format(input))
+			}
+		})
+	}
+}
+
+func TestRedactGenerated(t *testing.T) {
+	t.Parallel()
+	input := &demov1.OrderEvent{
+		EventId: "e",
+		Event: &demov1.OrderEvent_CustomerRegistered{
+			CustomerRegistered: &demov1.Customer{
+				CustomerId: "c",
+				Email:      "c@example.com",
+				Name:       "Customer",
+			},
+		},
+	}
+	original := proto.Clone(input)
+	redacted := Redact(input, ModeMask)
+	want := &demov1.OrderEvent{
+		EventId: "e",
+		Event: &demov1.OrderEvent_CustomerRegistered{
+			CustomerRegistered: &demov1.Customer{
+				CustomerId: "c",
+				Email:      mask,
+				Name:       mask,
+			},
+		},
+	}
+	if !proto.Equal(redacted, want) {
+		t.Errorf("redacted to %v, want %v", redacted, want)
+	}
+	if !proto.Equal(input, original) {
+		t.Errorf("input was modified to %v", input)
+	}
+}
+
+func TestRedactUnknownFields(t *testing.T) {
+	t.Parallel()
+	input := &demov1.Customer{CustomerId: "c"}
+	// Field 100 is unknown to Customer, and may be a sensitive field of a newer schema.
+	input.ProtoReflect().SetUnknown(protoreflect.RawFields{0xa2, 0x06, 0x01, 'x'})
+	redacted := Redact(input, ModeMask)
+	if len(redacted.ProtoReflect().GetUnknown()) != 0 {
+		t.Error("unknown fields were kept")
+	}
+	if len(input.ProtoReflect().GetUnknown()) == 0 {
+		t.Error("unknown fields were dropped from the input")
+	}
+}
+
+func TestRedactNil(t *testing.T) {
+	t.Parallel()
+	if redacted := Redact((*demov1.Customer)(nil), ModeMask); redacted != nil {
+		t.Errorf("redacted nil to %v", redacted)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	t.Parallel()
+	for _, mode := range []Mode{ModeMask, ModeHash, ModeDrop} {
+		parsed, err := ParseMode(string(mode))
+		if err != nil {
+			t.Error(err)
+		}
+		if parsed != mode {
+			t.Errorf("parsed %q as %q", mode, parsed)
+		}
+	}
+	if _, err := ParseMode("encrypt"); err == nil {
+		t.Error("parsed an unknown mode")
+	}
+}
+
+func newTestMessage(t *testing.T, text string) proto.Message {
+	t.Helper()
+	message := dynamicpb.NewMessage(testMessage)
+	if err := prototext.Unmarshal([]byte(text), message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func newTestMessageDescriptor() protoreflect.MessageDescriptor {
+	sensitive := func() *descriptorpb.FieldOptions {
+		options := &descriptorpb.FieldOptions{}
+		proto.SetExtension(options, demov1.E_Sensitive, true)
+		return options
+	}
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     kind.Enum(),
+			Label:    label.Enum(),
+		}
+	}
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		str      = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		message  = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	withOptions := func(field *descriptorpb.FieldDescriptorProto, options *descriptorpb.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		field.Options = options
+		return field
+	}
+	withType := func(field *descriptorpb.FieldDescriptorProto, typeName string) *descriptorpb.FieldDescriptorProto {
+		field.TypeName = proto.String(typeName)
+		return field
+	}
+	mapEntry := func(name string, value *descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name:    proto.String(name),
+			Field:   []*descriptorpb.FieldDescriptorProto{field("key", 1, str, optional), value},
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("redact_test.proto"),
+		Package: proto.String("redact.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Account"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, str, optional),
+				withOptions(field("email", 2, str, optional), sensitive()),
+				withOptions(field("token", 3, descriptorpb.FieldDescriptorProto_TYPE_BYTES, optional), sensitive()),
+				withOptions(field("pin", 4, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional), sensitive()),
+				withOptions(field("phones", 5, str, repeated), sensitive()),
+				withOptions(withType(field("labels", 6, message, repeated), ".redact.test.Account.LabelsEntry"), sensitive()),
+				withType(field("parent", 7, message, optional), ".redact.test.Account"),
+				withType(field("children", 8, message, repeated), ".redact.test.Account"),
+				withType(field("by_name", 9, message, repeated), ".redact.test.Account.ByNameEntry"),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{
+				mapEntry("LabelsEntry", field("value", 2, str, optional)),
+				mapEntry("ByNameEntry", withType(field("value", 2, message, optional), ".redact.test.Account")),
+			},
+		}},
+	}
+	descriptor, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	return descriptor.Messages().Get(0)
+}
+
+func hashString(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashBytes returns the hash of a bytes value, escaped for the text format.
+func hashBytes(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	var escaped []byte
+	for _, b := range sum {
+		escaped = append(escaped, []byte(`\x`+hex.EncodeToString([]byte{b}))...)
+	}
+	return string(escaped)
+}
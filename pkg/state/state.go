@@ -0,0 +1,397 @@
+// Package state implements key-value stores for stateful consumers that survive restarts and
+// rebalances.
+//
+// Each partition of the consumed topic has its own Store, held in memory. Every write to a
+// Store is mirrored to the same partition of a compacted changelog topic, keyed by the Store's
+// key, and a delete is mirrored as a tombstone. When a consumer is assigned a partition, its
+// Store is restored by reading that partition of the changelog, so whichever consumer owns a
+// partition next picks up its state where the last owner left off.
+//
+// This is the same approach that stream processing frameworks take, without the framework:
+// a Stores only needs the consumer to call it back on rebalances, see [ConsumerOptions].
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/bufstream-demo/pkg/consume"
+	"github.com/bufbuild/bufstream-demo/pkg/kafka"
+	"github.com/bufbuild/bufstream-demo/pkg/topics"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrRevoked is returned when writing to a Store whose partition is no longer assigned to
+// this consumer.
+var ErrRevoked = errors.New("partition is no longer assigned")
+
+// Store is the key-value store of one partition of the consumed topic.
+//
+// Reads are served from memory. Writes are produced to the changelog before they are applied,
+// and only return once the changelog has acknowledged them, so that any offset committed after
+// a write is never ahead of the state. Reads are not blocked while a write waits for the
+// changelog. A Store is safe for concurrent use.
+type Store struct {
+	stores    *Stores
+	partition int32
+	// writeMu is held for the whole of a write, so that writes are applied in the order they
+	// are written to the changelog.
+	writeMu sync.Mutex
+	// mu guards data and revoked. It is not held while writing to the changelog.
+	mu      sync.RWMutex
+	data    map[string][]byte
+	revoked bool
+}
+
+// Partition returns the partition of the consumed topic that the Store holds the state of.
+func (s *Store) Partition() int32 {
+	return s.partition
+}
+
+// Get returns the value of the key, and whether it is set.
+func (s *Store) Get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.data[key]
+	return value, ok
+}
+
+// Len returns the number of keys in the Store.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data)
+}
+
+// All returns every key and value in the Store, ordered by key.
+func (s *Store) All() iter.Seq2[string, []byte] {
+	s.mu.RLock()
+	keys := slices.Sorted(maps.Keys(s.data))
+	s.mu.RUnlock()
+	return func(yield func(string, []byte) bool) {
+		for _, key := range keys {
+			value, ok := s.Get(key)
+			if ok && !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// Put sets the value of the key.
+//
+// The value must not be modified after it is put.
+func (s *Store) Put(ctx context.Context, key string, value []byte) error {
+	return s.PutAll(ctx, map[string][]byte{key: value})
+}
+
+// PutAll sets the value of every key in values, with a single write to the changelog, such as
+// to update every key that one message changes at once.
+//
+// The values must not be modified after they are put.
+func (s *Store) PutAll(ctx context.Context, values map[string][]byte) error {
+	writes := make(map[string][]byte, len(values))
+	for key, value := range values {
+		if value == nil {
+			// A nil value would be written as a tombstone.
+			value = []byte{}
+		}
+		writes[key] = value
+	}
+	return s.write(ctx, writes)
+}
+
+// Delete deletes the key.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.write(ctx, map[string][]byte{key: nil})
+}
+
+// write writes values to the changelog, and then applies them. A nil value deletes its key.
+func (s *Store) write(ctx context.Context, values map[string][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	keys := slices.Sorted(maps.Keys(values))
+	s.mu.RLock()
+	revoked := s.revoked
+	s.mu.RUnlock()
+	if revoked {
+		return fmt.Errorf("failed to write %q to the store of partition %d: %w", keys, s.partition, ErrRevoked)
+	}
+	records := make([]*kgo.Record, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, &kgo.Record{
+			Topic:     s.stores.changelogTopic,
+			Partition: s.partition,
+			Key:       []byte(key),
+			Value:     values[key],
+		})
+	}
+	if err := s.stores.client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		return fmt.Errorf("failed to write %q to the changelog of partition %d: %w", keys, s.partition, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range values {
+		if value == nil {
+			delete(s.data, key)
+		} else {
+			s.data[key] = value
+		}
+	}
+	return nil
+}
+
+func (s *Store) revoke() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked = true
+}
+
+// Stores holds the Stores of the partitions assigned to a consumer.
+type Stores struct {
+	client         *kgo.Client
+	topic          string
+	changelogTopic string
+	mu             sync.Mutex
+	stores         map[int32]*Store
+}
+
+// ChangelogTopicSpec returns the Spec of a changelog topic with the given name and number of
+// partitions.
+func ChangelogTopicSpec(name string, partitions int32) topics.Spec {
+	compact := "compact"
+	return topics.Spec{
+		Name:       name,
+		Partitions: partitions,
+		Configs: map[string]*string{
+			"cleanup.policy": &compact,
+		},
+	}
+}
+
+// New returns new Stores for the partitions of the Config's topic, mirrored to the given
+// changelog topic.
+//
+// The changelog topic is created if it does not exist, with as many partitions as the
+// consumed topic, so that partition N of the consumed topic is always mirrored to partition N
+// of the changelog. Stores use their own client, which must be closed with Close.
+func New(ctx context.Context, config kafka.Config, changelogTopic string) (_ *Stores, retErr error) {
+	client, err := kafka.NewKafkaClient(
+		config,
+		false,
+		kgo.RecordPartitioner(kgo.ManualPartitioner()),
+		kgo.FetchMaxWait(time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			client.Close()
+		}
+	}()
+	admClient := kadm.NewClient(client)
+	topicDetails, err := admClient.ListTopics(ctx, config.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe topic %s: %w", config.Topic, err)
+	}
+	topicDetail, ok := topicDetails[config.Topic]
+	if !ok {
+		return nil, fmt.Errorf("topic %s does not exist", config.Topic)
+	}
+	if topicDetail.Err != nil {
+		return nil, fmt.Errorf("failed to describe topic %s: %w", config.Topic, topicDetail.Err)
+	}
+	spec := ChangelogTopicSpec(changelogTopic, int32(len(topicDetail.Partitions)))
+	plan, err := topics.NewPlan(ctx, admClient, []topics.Spec{spec})
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.Apply(ctx, admClient, false); err != nil {
+		return nil, err
+	}
+	return &Stores{
+		client:         client,
+		topic:          config.Topic,
+		changelogTopic: changelogTopic,
+		stores:         make(map[int32]*Store),
+	}, nil
+}
+
+// Close closes the client of the Stores.
+func (s *Stores) Close() {
+	s.client.Close()
+}
+
+// Get returns the Store of the given partition, if it is assigned.
+func (s *Stores) Get(partition int32) (*Store, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	store, ok := s.stores[partition]
+	return store, ok
+}
+
+// All returns the Stores of every assigned partition, ordered by partition.
+func (s *Stores) All() []*Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stores := make([]*Store, 0, len(s.stores))
+	for _, partition := range slices.Sorted(maps.Keys(s.stores)) {
+		stores = append(stores, s.stores[partition])
+	}
+	return stores
+}
+
+// FromContext returns the Store of the partition of the record being handled, when called
+// with the context passed to a message handler. See [consume.RecordFromContext].
+func (s *Stores) FromContext(ctx context.Context) (*Store, error) {
+	record, ok := consume.RecordFromContext(ctx)
+	if !ok {
+		return nil, errors.New("no record in context")
+	}
+	store, ok := s.Get(record.Partition)
+	if !ok {
+		return nil, fmt.Errorf("no store for partition %d: %w", record.Partition, ErrRevoked)
+	}
+	return store, nil
+}
+
+// Handler returns a message handler that calls handler with the Store of the partition of
+// each message's record.
+func Handler[M proto.Message](stores *Stores, handler func(context.Context, *Store, M) error) func(context.Context, M) error {
+	return func(ctx context.Context, message M) error {
+		store, err := stores.FromContext(ctx)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, store, message)
+	}
+}
+
+// Assign restores the Stores of the given partitions from the changelog.
+//
+// Partitions that already have a Store are left as they are. If restoring fails, the
+// partitions have no Store, and handlers of their records fail with ErrRevoked until they are
+// assigned again.
+func (s *Stores) Assign(ctx context.Context, partitions []int32) error {
+	s.mu.Lock()
+	var toRestore []int32
+	for _, partition := range partitions {
+		if _, ok := s.stores[partition]; !ok {
+			toRestore = append(toRestore, partition)
+		}
+	}
+	s.mu.Unlock()
+	if len(toRestore) == 0 {
+		return nil
+	}
+	restored, err := s.restore(ctx, toRestore)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for partition, data := range restored {
+		s.stores[partition] = &Store{
+			stores:    s,
+			partition: partition,
+			data:      data,
+		}
+		slog.InfoContext(ctx, "restored state", "partition", partition, "keys", len(data))
+	}
+	return nil
+}
+
+// Revoke drops the Stores of the given partitions. Writes to them fail with ErrRevoked.
+func (s *Stores) Revoke(partitions []int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, partition := range partitions {
+		if store, ok := s.stores[partition]; ok {
+			store.revoke()
+			delete(s.stores, partition)
+		}
+	}
+}
+
+// ConsumerOptions returns the options to create a Consumer with, so that Stores are restored
+// when partitions are assigned and dropped when they are revoked or lost.
+func ConsumerOptions[M proto.Message](stores *Stores) []consume.ConsumerOption[M] {
+	revoke := func(_ context.Context, partitions []int32) error {
+		stores.Revoke(partitions)
+		return nil
+	}
+	return []consume.ConsumerOption[M]{
+		consume.WithOnPartitionsAssigned[M](stores.Assign),
+		consume.WithOnPartitionsRevoked[M](revoke),
+		consume.WithOnPartitionsLost[M](revoke),
+	}
+}
+
+// restore reads the given partitions of the changelog up to their end offsets.
+func (s *Stores) restore(ctx context.Context, partitions []int32) (map[int32]map[string][]byte, error) {
+	endOffsets, err := kadm.NewClient(s.client).ListEndOffsets(ctx, s.changelogTopic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list end offsets of changelog %s: %w", s.changelogTopic, err)
+	}
+	restored := make(map[int32]map[string][]byte, len(partitions))
+	remaining := make(map[int32]int64)
+	startOffsets := make(map[int32]kgo.Offset)
+	for _, partition := range partitions {
+		restored[partition] = make(map[string][]byte)
+		endOffset, ok := endOffsets.Lookup(s.changelogTopic, partition)
+		if !ok {
+			return nil, fmt.Errorf("changelog %s has no partition %d", s.changelogTopic, partition)
+		}
+		if endOffset.Err != nil {
+			return nil, fmt.Errorf("failed to list end offset of changelog %s partition %d: %w", s.changelogTopic, partition, endOffset.Err)
+		}
+		if endOffset.Offset > 0 {
+			remaining[partition] = endOffset.Offset
+			startOffsets[partition] = kgo.NewOffset().AtStart()
+		}
+	}
+	if len(startOffsets) == 0 {
+		return restored, nil
+	}
+	s.client.AddConsumePartitions(map[string]map[int32]kgo.Offset{s.changelogTopic: startOffsets})
+	defer s.client.RemoveConsumePartitions(map[string][]int32{s.changelogTopic: slices.Collect(maps.Keys(startOffsets))})
+	// Compaction never removes the last record of a partition, so every partition eventually
+	// reaches its end offset.
+	for len(remaining) > 0 {
+		fetches := s.client.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return nil, fmt.Errorf("failed to fetch changelog %s: %v", s.changelogTopic, errs)
+		}
+		for _, record := range fetches.Records() {
+			endOffset, ok := remaining[record.Partition]
+			if !ok || record.Offset >= endOffset {
+				continue
+			}
+			if record.Value == nil {
+				delete(restored[record.Partition], string(record.Key))
+			} else {
+				restored[record.Partition][string(record.Key)] = record.Value
+			}
+			if record.Offset+1 >= endOffset {
+				delete(remaining, record.Partition)
+			}
+		}
+	}
+	return restored, nil
+}
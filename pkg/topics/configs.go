@@ -0,0 +1,71 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+// ValidateModeConfig is the topic config that controls how Bufstream handles records that
+// fail schema validation.
+const ValidateModeConfig = "bufstream.validate.mode"
+
+// ValueSchemaMessageConfig is the topic config that names the Protobuf message of the
+// topic's record values, such as bufstream.demo.v1.Cart.
+const ValueSchemaMessageConfig = "buf.registry.value.schema.message"
+
+// DescribeConfigs returns the effective configs of each topic, keyed by topic and then by
+// config name.
+func DescribeConfigs(ctx context.Context, admClient *kadm.Client, names ...string) (map[string]map[string]kadm.Config, error) {
+	resourceConfigs, err := admClient.DescribeTopicConfigs(ctx, names...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe topic configs: %w", err)
+	}
+	configs := make(map[string]map[string]kadm.Config, len(names))
+	for _, name := range names {
+		resourceConfig, err := resourceConfigs.On(name, nil)
+		if err == nil {
+			err = resourceConfig.Err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe configs of topic %s: %w", name, err)
+		}
+		configs[name] = make(map[string]kadm.Config, len(resourceConfig.Configs))
+		for _, config := range resourceConfig.Configs {
+			configs[name][config.Key] = config
+		}
+	}
+	return configs, nil
+}
+
+// AlterConfigs sets the given configs on every topic. A nil value resets the config to its
+// default. Configs that are not given are left unchanged.
+func AlterConfigs(ctx context.Context, admClient *kadm.Client, configs map[string]*string, names ...string) error {
+	alterConfigs := make([]kadm.AlterConfig, 0, len(configs))
+	for _, name := range slices.Sorted(maps.Keys(configs)) {
+		if value := configs[name]; value != nil {
+			alterConfigs = append(alterConfigs, kadm.AlterConfig{Op: kadm.SetConfig, Name: name, Value: value})
+		} else {
+			alterConfigs = append(alterConfigs, kadm.AlterConfig{Op: kadm.DeleteConfig, Name: name})
+		}
+	}
+	resps, err := admClient.AlterTopicConfigs(ctx, alterConfigs, names...)
+	if err != nil {
+		return fmt.Errorf("failed to alter topic configs: %w", err)
+	}
+	var errs []error
+	for _, name := range names {
+		resp, err := resps.On(name, nil)
+		if err == nil {
+			err = resp.Err
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to alter configs of topic %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
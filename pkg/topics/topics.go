@@ -0,0 +1,371 @@
+// Package topics implements declarative topic provisioning.
+//
+// Topics are declared as Specs, typically loaded from a YAML file with LoadFile. NewPlan
+// compares Specs against the cluster and returns the Changes needed to make the cluster
+// match, and Plan.Apply makes them. Changes that delete data are never applied unless the
+// caller explicitly confirms them.
+//
+// Provisioning only ever touches what is declared: topics that are not declared are left
+// alone, and so are configs that are not declared on a declared topic. This lets other
+// tools, such as the validation mode switch, manage configs the file does not mention.
+package topics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"go.yaml.in/yaml/v3"
+)
+
+const defaultPartitions = 1
+
+const defaultReplicationFactor = 1
+
+// Spec declares the desired state of a topic.
+type Spec struct {
+	// Name is the topic name.
+	Name string `yaml:"name"`
+	// Partitions is the number of partitions. If zero, the topic is created with one
+	// partition, and the partition count of an existing topic is left unchanged.
+	Partitions int32 `yaml:"partitions"`
+	// ReplicationFactor is the replication factor. If zero, the topic is created with a
+	// replication factor of one. The replication factor of an existing topic cannot be changed.
+	ReplicationFactor int16 `yaml:"replication_factor"`
+	// Configs are the topic configs to set. A nil value resets the config to its default.
+	Configs map[string]*string `yaml:"configs"`
+	// Delete deletes the topic if it exists.
+	Delete bool `yaml:"delete"`
+	// Recreate deletes the topic if it exists, and then creates it again.
+	Recreate bool `yaml:"recreate"`
+}
+
+// LoadFile loads Specs from a YAML file of the form:
+//
+//	topics:
+//	  - name: orders
+//	    partitions: 4
+//	    configs:
+//	      buf.registry.value.schema.message: bufstream.demo.v1.Cart
+//	      bufstream.validate.mode: dlq
+func LoadFile(path string) ([]Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Topics []Spec `yaml:"topics"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	names := make(map[string]struct{}, len(file.Topics))
+	for _, spec := range file.Topics {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("%s: every topic must have a name", path)
+		}
+		if _, ok := names[spec.Name]; ok {
+			return nil, fmt.Errorf("%s: topic %s is declared more than once", path, spec.Name)
+		}
+		names[spec.Name] = struct{}{}
+		if spec.Partitions < 0 || spec.ReplicationFactor < 0 {
+			return nil, fmt.Errorf("%s: topic %s has a negative partition count or replication factor", path, spec.Name)
+		}
+		if spec.Delete && spec.Recreate {
+			return nil, fmt.Errorf("%s: topic %s cannot set both delete and recreate", path, spec.Name)
+		}
+	}
+	return file.Topics, nil
+}
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	// ChangeDeleteTopic deletes a topic.
+	ChangeDeleteTopic ChangeKind = iota + 1
+	// ChangeCreateTopic creates a topic.
+	ChangeCreateTopic
+	// ChangeAddPartitions adds partitions to a topic.
+	ChangeAddPartitions
+	// ChangeSetConfig sets a topic config.
+	ChangeSetConfig
+	// ChangeResetConfig resets a topic config to its default.
+	ChangeResetConfig
+)
+
+// Change is a single change to the cluster.
+type Change struct {
+	Kind  ChangeKind
+	Topic string
+	// Partitions is the partition count of a created topic, or the new partition count of
+	// a topic that partitions are added to.
+	Partitions int32
+	// CurrentPartitions is the current partition count of a topic that partitions are added to.
+	CurrentPartitions int32
+	// ReplicationFactor is the replication factor of a created topic.
+	ReplicationFactor int16
+	// Configs are the configs of a created topic.
+	Configs map[string]*string
+	// Config is the name of the config that is set or reset.
+	Config string
+	// CurrentValue is the current value of the config that is set or reset, or nil if unset.
+	CurrentValue *string
+	// Value is the new value of the config that is set.
+	Value *string
+}
+
+// String returns a human-readable description of the Change.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeDeleteTopic:
+		return fmt.Sprintf("- delete topic %s", c.Topic)
+	case ChangeCreateTopic:
+		description := fmt.Sprintf("+ create topic %s (partitions: %d, replication factor: %d)", c.Topic, c.Partitions, c.ReplicationFactor)
+		for _, name := range slices.Sorted(maps.Keys(c.Configs)) {
+			description += fmt.Sprintf("\n    %s: %s", name, formatValue(c.Configs[name]))
+		}
+		return description
+	case ChangeAddPartitions:
+		return fmt.Sprintf("~ add partitions to topic %s: %d -> %d", c.Topic, c.CurrentPartitions, c.Partitions)
+	case ChangeSetConfig:
+		return fmt.Sprintf("~ set config %s on topic %s: %s -> %s", c.Config, c.Topic, formatValue(c.CurrentValue), formatValue(c.Value))
+	case ChangeResetConfig:
+		return fmt.Sprintf("~ reset config %s on topic %s: %s -> (default)", c.Config, c.Topic, formatValue(c.CurrentValue))
+	default:
+		return fmt.Sprintf("? unknown change to topic %s", c.Topic)
+	}
+}
+
+// Plan is the set of changes needed to make the cluster match a set of Specs.
+type Plan struct {
+	Changes []Change
+}
+
+// NewPlan compares the Specs against the cluster and returns the Plan to make the cluster
+// match them.
+//
+// NewPlan returns an error if a Spec cannot be satisfied without recreating the topic, such as
+// reducing its partition count or changing its replication factor.
+func NewPlan(ctx context.Context, admClient *kadm.Client, specs []Spec) (*Plan, error) {
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	topicDetails, err := admClient.ListTopics(ctx, names...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+	plan := &Plan{}
+	var existing []Spec
+	for _, spec := range specs {
+		topicDetail, ok := topicDetails[spec.Name]
+		exists := ok && topicDetail.Err == nil
+		if ok && topicDetail.Err != nil && !isUnknownTopic(topicDetail.Err) {
+			return nil, fmt.Errorf("failed to describe topic %s: %w", spec.Name, topicDetail.Err)
+		}
+		if exists && (spec.Delete || spec.Recreate) {
+			plan.Changes = append(plan.Changes, Change{Kind: ChangeDeleteTopic, Topic: spec.Name})
+		}
+		if spec.Delete {
+			continue
+		}
+		if !exists || spec.Recreate {
+			plan.Changes = append(plan.Changes, newCreateChange(spec))
+			continue
+		}
+		partitionChanges, err := planPartitions(spec, topicDetail)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, partitionChanges...)
+		existing = append(existing, spec)
+	}
+	configChanges, err := planConfigs(ctx, admClient, existing)
+	if err != nil {
+		return nil, err
+	}
+	plan.Changes = append(plan.Changes, configChanges...)
+	return plan, nil
+}
+
+// Deletes returns the topics the Plan deletes.
+func (p *Plan) Deletes() []string {
+	var topics []string
+	for _, change := range p.Changes {
+		if change.Kind == ChangeDeleteTopic {
+			topics = append(topics, change.Topic)
+		}
+	}
+	return topics
+}
+
+// String returns a human-readable description of every Change in the Plan.
+func (p *Plan) String() string {
+	if len(p.Changes) == 0 {
+		return "no changes"
+	}
+	descriptions := make([]string, 0, len(p.Changes))
+	for _, change := range p.Changes {
+		descriptions = append(descriptions, change.String())
+	}
+	return strings.Join(descriptions, "\n")
+}
+
+// Apply applies every Change in the Plan, in order.
+//
+// If the Plan deletes any topics, Apply refuses to apply any Change unless confirmDelete is true.
+func (p *Plan) Apply(ctx context.Context, admClient *kadm.Client, confirmDelete bool) error {
+	if deletes := p.Deletes(); len(deletes) > 0 && !confirmDelete {
+		return fmt.Errorf("plan deletes topics %s and all of their data: explicit confirmation is required", strings.Join(deletes, ", "))
+	}
+	for _, change := range p.Changes {
+		if err := applyChange(ctx, admClient, change); err != nil {
+			return fmt.Errorf("failed to apply change %q: %w", change.String(), err)
+		}
+	}
+	return nil
+}
+
+func applyChange(ctx context.Context, admClient *kadm.Client, change Change) error {
+	switch change.Kind {
+	case ChangeDeleteTopic:
+		resp, err := admClient.DeleteTopic(ctx, change.Topic)
+		if err == nil {
+			err = resp.Err
+		}
+		if isUnknownTopic(err) {
+			return nil // already gone
+		}
+		return err
+	case ChangeCreateTopic:
+		resp, err := admClient.CreateTopic(ctx, change.Partitions, change.ReplicationFactor, change.Configs, change.Topic)
+		if err == nil {
+			err = resp.Err
+		}
+		return err
+	case ChangeAddPartitions:
+		resps, err := admClient.UpdatePartitions(ctx, int(change.Partitions), change.Topic)
+		if err == nil {
+			err = resps.Error()
+		}
+		return err
+	case ChangeSetConfig, ChangeResetConfig:
+		return AlterConfigs(ctx, admClient, map[string]*string{change.Config: change.Value}, change.Topic)
+	default:
+		return errors.New("unknown change")
+	}
+}
+
+func newCreateChange(spec Spec) Change {
+	change := Change{
+		Kind:              ChangeCreateTopic,
+		Topic:             spec.Name,
+		Partitions:        spec.Partitions,
+		ReplicationFactor: spec.ReplicationFactor,
+		Configs:           spec.Configs,
+	}
+	if change.Partitions == 0 {
+		change.Partitions = defaultPartitions
+	}
+	if change.ReplicationFactor == 0 {
+		change.ReplicationFactor = defaultReplicationFactor
+	}
+	return change
+}
+
+func planPartitions(spec Spec, topicDetail kadm.TopicDetail) ([]Change, error) {
+	currentPartitions := int32(len(topicDetail.Partitions))
+	if spec.ReplicationFactor > 0 && currentPartitions > 0 {
+		if replicationFactor := len(topicDetail.Partitions[0].Replicas); replicationFactor != int(spec.ReplicationFactor) {
+			return nil, fmt.Errorf(
+				"topic %s has replication factor %d, and changing it to %d is not supported",
+				spec.Name, replicationFactor, spec.ReplicationFactor,
+			)
+		}
+	}
+	switch {
+	case spec.Partitions == 0 || spec.Partitions == currentPartitions:
+		return nil, nil
+	case spec.Partitions < currentPartitions:
+		return nil, fmt.Errorf(
+			"topic %s has %d partitions, and partitions cannot be removed: recreate the topic to have %d",
+			spec.Name, currentPartitions, spec.Partitions,
+		)
+	default:
+		return []Change{{
+			Kind:              ChangeAddPartitions,
+			Topic:             spec.Name,
+			Partitions:        spec.Partitions,
+			CurrentPartitions: currentPartitions,
+		}}, nil
+	}
+}
+
+func planConfigs(ctx context.Context, admClient *kadm.Client, specs []Spec) ([]Change, error) {
+	var names []string
+	for _, spec := range specs {
+		if len(spec.Configs) > 0 {
+			names = append(names, spec.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	currentConfigs, err := DescribeConfigs(ctx, admClient, names...)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, spec := range specs {
+		current := currentConfigs[spec.Name]
+		for _, name := range slices.Sorted(maps.Keys(spec.Configs)) {
+			value := spec.Configs[name]
+			config, ok := current[name]
+			isSet := ok && config.Source == kmsg.ConfigSourceDynamicTopicConfig
+			switch {
+			case value == nil && isSet:
+				changes = append(changes, Change{
+					Kind:         ChangeResetConfig,
+					Topic:        spec.Name,
+					Config:       name,
+					CurrentValue: config.Value,
+				})
+			case value != nil && (!isSet || config.Value == nil || *config.Value != *value):
+				change := Change{
+					Kind:   ChangeSetConfig,
+					Topic:  spec.Name,
+					Config: name,
+					Value:  value,
+				}
+				if ok {
+					change.CurrentValue = config.Value
+				}
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes, nil
+}
+
+func formatValue(value *string) string {
+	if value == nil {
+		return "(default)"
+	}
+	return fmt.Sprintf("%q", *value)
+}
+
+func isUnknownTopic(err error) bool {
+	var kError *kerr.Error
+	return errors.As(err, &kError) && kError.Code == kerr.UnknownTopicOrPartition.Code
+}
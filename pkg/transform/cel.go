@@ -0,0 +1,245 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// newEnv returns the CEL environment that expressions are compiled in. The input message is
+// bound as this, and the messages of the input and output files can be constructed, such as
+// bufstream.demo.v1.PublicLineItem{product_id: li.product.product_id}.
+func newEnv(input protoreflect.MessageType, output protoreflect.MessageType) (*cel.Env, error) {
+	env, err := cel.NewEnv(
+		cel.Types(input.New().Interface(), output.New().Interface()),
+		cel.Variable("this", cel.ObjectType(string(input.Descriptor().FullName()))),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	return env, nil
+}
+
+func newCELStep(env *cel.Env, expression string, field path) (step, error) {
+	if !field.isSingular() {
+		return nil, errors.New("cannot set a field through a repeated field")
+	}
+	if field.last().IsMap() {
+		return nil, errors.New("cannot set a map field with cel")
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("invalid cel %q: %w", expression, issues.Err())
+	}
+	expected := fieldType(field.last())
+	if !isAssignable(expected, ast.OutputType()) {
+		return nil, fmt.Errorf("cel %q evaluates to %s, which cannot be set on %s", expression, ast.OutputType(), field)
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cel %q: %w", expression, err)
+	}
+	return func(input protoreflect.Message, output protoreflect.Message) error {
+		result, _, err := program.Eval(map[string]any{"this": input.Interface()})
+		if err != nil {
+			return fmt.Errorf("failed to evaluate cel %q for %s: %w", expression, field, err)
+		}
+		target := field.mutable(output)
+		value, err := toFieldValue(target, field.last(), result)
+		if err != nil {
+			return fmt.Errorf("failed to set %s: %w", field, err)
+		}
+		target.Set(field.last(), value)
+		return nil
+	}, nil
+}
+
+// fieldType returns the CEL type of the values of a field that is not a map.
+func fieldType(field protoreflect.FieldDescriptor) *cel.Type {
+	var elementType *cel.Type
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		elementType = cel.BoolType
+	case protoreflect.StringKind:
+		elementType = cel.StringType
+	case protoreflect.BytesKind:
+		elementType = cel.BytesType
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.EnumKind:
+		elementType = cel.IntType
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		elementType = cel.UintType
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		elementType = cel.DoubleType
+	default:
+		switch field.Message().FullName() {
+		case "google.protobuf.Timestamp":
+			elementType = cel.TimestampType
+		case "google.protobuf.Duration":
+			elementType = cel.DurationType
+		default:
+			elementType = cel.ObjectType(string(field.Message().FullName()))
+		}
+	}
+	if field.IsList() {
+		return cel.ListType(elementType)
+	}
+	return elementType
+}
+
+// isAssignable returns true if a value of type actual may be set on a field of type
+// expected. Ints and uints are interchangeable, as CEL functions such as size() return ints
+// even for values that can never be negative, and are range checked when they are set.
+func isAssignable(expected *cel.Type, actual *cel.Type) bool {
+	if actual.Kind() == types.DynKind || actual.Kind() == types.AnyKind {
+		return true
+	}
+	if isInteger(expected) && isInteger(actual) {
+		return true
+	}
+	if expected.Kind() == types.ListKind && actual.Kind() == types.ListKind {
+		return isAssignable(expected.Parameters()[0], actual.Parameters()[0])
+	}
+	return expected.IsAssignableType(actual)
+}
+
+func isInteger(t *cel.Type) bool {
+	return t.Kind() == types.IntKind || t.Kind() == types.UintKind
+}
+
+// toFieldValue converts the result of an expression to a value of field, for setting on
+// message.
+func toFieldValue(message protoreflect.Message, field protoreflect.FieldDescriptor, result ref.Val) (protoreflect.Value, error) {
+	if !field.IsList() {
+		return toScalarValue(message, field, result)
+	}
+	lister, ok := result.(traits.Lister)
+	if !ok {
+		return protoreflect.Value{}, fmt.Errorf("expected a list, got %s", result.Type())
+	}
+	list := message.NewField(field).List()
+	for it := lister.Iterator(); it.HasNext() == types.True; {
+		element, err := toScalarValue(message, field, it.Next())
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		list.Append(element)
+	}
+	return protoreflect.ValueOfList(list), nil
+}
+
+// nativeTypes are the Go types of the kinds of fields that CEL values can be converted to
+// directly.
+var nativeTypes = map[protoreflect.Kind]reflect.Type{
+	protoreflect.BoolKind:   reflect.TypeFor[bool](),
+	protoreflect.StringKind: reflect.TypeFor[string](),
+	protoreflect.BytesKind:  reflect.TypeFor[[]byte](),
+	protoreflect.FloatKind:  reflect.TypeFor[float32](),
+	protoreflect.DoubleKind: reflect.TypeFor[float64](),
+}
+
+// toScalarValue converts a value that is not a list to a value of field.
+func toScalarValue(message protoreflect.Message, field protoreflect.FieldDescriptor, result ref.Val) (protoreflect.Value, error) {
+	if types.IsError(result) {
+		return protoreflect.Value{}, result.(*types.Err)
+	}
+	if nativeType, ok := nativeTypes[field.Kind()]; ok {
+		native, err := result.ConvertToNative(nativeType)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOf(native), nil
+	}
+	switch field.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.EnumKind:
+		value, err := toInt64(result, math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if field.Kind() == protoreflect.EnumKind {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(value)), nil
+		}
+		return protoreflect.ValueOfInt32(int32(value)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		value, err := toInt64(result, math.MinInt64, math.MaxInt64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(value), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		value, err := toUint64(result, math.MaxUint32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint32(uint32(value)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		value, err := toUint64(result, math.MaxUint64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint64(value), nil
+	default:
+		var target proto.Message
+		if field.IsList() {
+			target = message.NewField(field).List().NewElement().Message().Interface()
+		} else {
+			target = message.NewField(field).Message().Interface()
+		}
+		native, err := result.ConvertToNative(reflect.TypeOf(target))
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		converted, ok := native.(proto.Message)
+		if !ok || converted.ProtoReflect().Descriptor().FullName() != field.Message().FullName() {
+			return protoreflect.Value{}, fmt.Errorf("expected a %s, got %s", field.Message().FullName(), result.Type())
+		}
+		return protoreflect.ValueOfMessage(proto.Clone(converted).ProtoReflect()), nil
+	}
+}
+
+func toInt64(result ref.Val, minValue int64, maxValue int64) (int64, error) {
+	var value int64
+	switch native := result.Value().(type) {
+	case int64:
+		value = native
+	case uint64:
+		if native > math.MaxInt64 {
+			return 0, fmt.Errorf("%d is out of range", native)
+		}
+		value = int64(native)
+	default:
+		return 0, fmt.Errorf("expected an int, got %s", result.Type())
+	}
+	if value < minValue || value > maxValue {
+		return 0, fmt.Errorf("%d is out of range", value)
+	}
+	return value, nil
+}
+
+func toUint64(result ref.Val, maxValue uint64) (uint64, error) {
+	var value uint64
+	switch native := result.Value().(type) {
+	case uint64:
+		value = native
+	case int64:
+		if native < 0 {
+			return 0, fmt.Errorf("%d is out of range", native)
+		}
+		value = uint64(native)
+	default:
+		return 0, fmt.Errorf("expected a uint, got %s", result.Type())
+	}
+	if value > maxValue {
+		return 0, fmt.Errorf("%d is out of range", value)
+	}
+	return value, nil
+}
@@ -0,0 +1,222 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// path is a resolved field path, such as customer.email. Every field but the last is a
+// message field.
+type path []protoreflect.FieldDescriptor
+
+// resolvePath resolves a dot-separated field path against a message.
+func resolvePath(message protoreflect.MessageDescriptor, value string) (path, error) {
+	if value == "" {
+		return nil, errors.New("field must be set")
+	}
+	var resolved path
+	for name := range strings.SplitSeq(value, ".") {
+		if message == nil {
+			return nil, fmt.Errorf("%s: %s is not a message field", value, resolved[len(resolved)-1].Name())
+		}
+		field := message.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			return nil, fmt.Errorf("%s: %s has no field %s", value, message.FullName(), name)
+		}
+		resolved = append(resolved, field)
+		message = nil
+		if field.Message() != nil && !field.IsMap() {
+			message = field.Message()
+		}
+	}
+	return resolved, nil
+}
+
+func (p path) String() string {
+	names := make([]string, len(p))
+	for i, field := range p {
+		names[i] = string(field.Name())
+	}
+	return strings.Join(names, ".")
+}
+
+func (p path) last() protoreflect.FieldDescriptor {
+	return p[len(p)-1]
+}
+
+// isSingular returns true if the path refers to one field, rather than the field of every
+// element of a repeated field.
+func (p path) isSingular() bool {
+	for _, field := range p[:len(p)-1] {
+		if field.IsList() {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the message holding the last field of the path, or nil if it is not set.
+func (p path) get(message protoreflect.Message) protoreflect.Message {
+	for _, field := range p[:len(p)-1] {
+		if !message.Has(field) {
+			return nil
+		}
+		message = message.Get(field).Message()
+	}
+	return message
+}
+
+// mutable returns the message holding the last field of the path, setting every message on
+// the way that is not set.
+func (p path) mutable(message protoreflect.Message) protoreflect.Message {
+	for _, field := range p[:len(p)-1] {
+		message = message.Mutable(field).Message()
+	}
+	return message
+}
+
+// each calls fn with every message holding the last field of the path, going through every
+// element of repeated fields.
+func (p path) each(message protoreflect.Message, fn func(protoreflect.Message)) {
+	if len(p) == 1 {
+		fn(message)
+		return
+	}
+	field, rest := p[0], p[1:]
+	if !message.Has(field) {
+		return
+	}
+	if field.IsList() {
+		list := message.Get(field).List()
+		for i := range list.Len() {
+			rest.each(list.Get(i).Message(), fn)
+		}
+		return
+	}
+	rest.each(message.Mutable(field).Message(), fn)
+}
+
+func newCopyStep(input protoreflect.MessageDescriptor, from string, to path) (step, error) {
+	fromPath, err := resolvePath(input, from)
+	if err != nil {
+		return nil, fmt.Errorf("from %w", err)
+	}
+	if !fromPath.isSingular() || !to.isSingular() {
+		return nil, errors.New("cannot copy through a repeated field, use cel to map over it instead")
+	}
+	if !sameType(fromPath.last(), to.last()) {
+		return nil, fmt.Errorf("cannot copy %s to %s, as they have different types", fromPath, to)
+	}
+	return func(input protoreflect.Message, output protoreflect.Message) error {
+		field := to.last()
+		source := fromPath.get(input)
+		if source == nil || !source.Has(fromPath.last()) {
+			if target := to.get(output); target != nil {
+				target.Clear(field)
+			}
+			return nil
+		}
+		target := to.mutable(output)
+		target.Set(field, copyValue(target, field, source.Get(fromPath.last())))
+		return nil
+	}, nil
+}
+
+func newMaskStep(field path, mask Mask) (step, error) {
+	if field.last().Kind() != protoreflect.StringKind || field.last().IsMap() {
+		return nil, fmt.Errorf("cannot mask %s, as it is not a string field", field)
+	}
+	if mask.KeepLast < 0 {
+		return nil, errors.New("keep_last must not be negative")
+	}
+	return func(_ protoreflect.Message, output protoreflect.Message) error {
+		field.each(output, func(message protoreflect.Message) {
+			last := field.last()
+			if !message.Has(last) {
+				return
+			}
+			if last.IsList() {
+				list := message.Mutable(last).List()
+				for i := range list.Len() {
+					list.Set(i, protoreflect.ValueOfString(maskString(list.Get(i).String(), mask)))
+				}
+				return
+			}
+			message.Set(last, protoreflect.ValueOfString(maskString(message.Get(last).String(), mask)))
+		})
+		return nil
+	}, nil
+}
+
+func maskString(value string, mask Mask) string {
+	runes := []rune(value)
+	keep := min(mask.KeepLast, len(runes))
+	return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+}
+
+func newDropStep(field path) step {
+	return func(_ protoreflect.Message, output protoreflect.Message) error {
+		field.each(output, func(message protoreflect.Message) {
+			message.Clear(field.last())
+		})
+		return nil
+	}
+}
+
+// sameType returns true if values of field a can be set on field b.
+func sameType(a protoreflect.FieldDescriptor, b protoreflect.FieldDescriptor) bool {
+	if a.IsList() != b.IsList() || a.IsMap() != b.IsMap() {
+		return false
+	}
+	if a.IsMap() {
+		return sameType(a.MapKey(), b.MapKey()) && sameType(a.MapValue(), b.MapValue())
+	}
+	if a.Kind() != b.Kind() {
+		return false
+	}
+	switch a.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return a.Message().FullName() == b.Message().FullName()
+	case protoreflect.EnumKind:
+		return a.Enum().FullName() == b.Enum().FullName()
+	default:
+		return true
+	}
+}
+
+// copyValue returns a deep copy of a value of field, for setting on message.
+func copyValue(message protoreflect.Message, field protoreflect.FieldDescriptor, value protoreflect.Value) protoreflect.Value {
+	switch {
+	case field.IsList():
+		list := message.NewField(field).List()
+		source := value.List()
+		for i := range source.Len() {
+			list.Append(copyScalar(source.Get(i)))
+		}
+		return protoreflect.ValueOfList(list)
+	case field.IsMap():
+		copied := message.NewField(field).Map()
+		value.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			copied.Set(key, copyScalar(value))
+			return true
+		})
+		return protoreflect.ValueOfMap(copied)
+	default:
+		return copyScalar(value)
+	}
+}
+
+// copyScalar returns a deep copy of a value that is not a list or a map.
+func copyScalar(value protoreflect.Value) protoreflect.Value {
+	if message, ok := value.Interface().(protoreflect.Message); ok {
+		return protoreflect.ValueOfMessage(proto.Clone(message.Interface()).ProtoReflect())
+	}
+	if bytes, ok := value.Interface().([]byte); ok {
+		return protoreflect.ValueOfBytes(append([]byte(nil), bytes...))
+	}
+	return value
+}
@@ -0,0 +1,185 @@
+// Package transform implements transformations of Protobuf messages that are configured
+// rather than programmed, such as to project carts into a slimmer message for a public topic.
+//
+// A transformation is a list of rules, applied in order to build an output message from an
+// input message. Each rule targets one field of the output message, and either:
+//
+//   - copies a field of the input message to it, such as to rename a field,
+//   - sets it to the result of a CEL expression over the input message, bound as this,
+//   - masks it, keeping only its last characters, or
+//   - drops it.
+//
+// If the output message has the same type as the input message, it starts out as a copy of
+// the input message, so that rules only need to say what changes. Otherwise, it starts out
+// empty, and only has the fields that rules set.
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"go.yaml.in/yaml/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Config is the configuration of a transformation.
+type Config struct {
+	// Output is the full name of the output message, such as bufstream.demo.v1.PublicCart.
+	// If empty, the output message has the same type as the input message.
+	Output string `yaml:"output"`
+	// Rules are applied in order.
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is a rule of a transformation. Exactly one of From, CEL, Mask, and Drop must be set.
+//
+// Fields are referred to by path, such as line_items or customer.email. A path that goes
+// through a repeated message field, such as line_items.product.name, refers to the field of
+// every element, and can only be masked or dropped.
+type Rule struct {
+	// Field is the path of the output field that the rule targets.
+	Field string `yaml:"field"`
+	// From is the path of an input field to copy to Field. It must have the same type.
+	From string `yaml:"from"`
+	// CEL is an expression over the input message, bound as this, to set Field to.
+	CEL string `yaml:"cel"`
+	// Mask masks Field, which must be a string field.
+	Mask *Mask `yaml:"mask"`
+	// Drop clears Field.
+	Drop bool `yaml:"drop"`
+}
+
+// Mask replaces the characters of a string with asterisks.
+type Mask struct {
+	// KeepLast is the number of characters at the end of the string to keep.
+	KeepLast int `yaml:"keep_last"`
+}
+
+// LoadFile loads a Config from a YAML file of the form:
+//
+//	output: bufstream.demo.v1.PublicCart
+//	rules:
+//	  - field: cart_id
+//	    from: cart_id
+//	  - field: cart_id
+//	    mask:
+//	      keep_last: 4
+//	  - field: line_item_count
+//	    cel: this.line_items.size()
+func LoadFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Transformer transforms messages of type M as configured by a Config. A Transformer is safe
+// for concurrent use.
+type Transformer[M proto.Message] struct {
+	input  protoreflect.MessageDescriptor
+	output protoreflect.MessageType
+	steps  []step
+}
+
+// step applies one rule to the output message.
+type step func(input protoreflect.Message, output protoreflect.Message) error
+
+// New returns a new Transformer for the given Config.
+//
+// Every rule is checked against the input and output messages, and every CEL expression is
+// compiled, so that a Config that could never work is rejected here rather than on the first
+// message.
+func New[M proto.Message](config Config) (*Transformer[M], error) {
+	var zero M
+	input := reflect.New(reflect.TypeOf(zero).Elem()).Interface().(M).ProtoReflect().Type()
+	output := input
+	if config.Output != "" {
+		var err error
+		output, err = protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(config.Output))
+		if err != nil {
+			return nil, fmt.Errorf("unknown output message %s: %w", config.Output, err)
+		}
+	}
+	transformer := &Transformer[M]{
+		input:  input.Descriptor(),
+		output: output,
+	}
+	env, err := newEnv(input, output)
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range config.Rules {
+		step, err := newStep(env, input.Descriptor(), output.Descriptor(), rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, rule.Field, err)
+		}
+		transformer.steps = append(transformer.steps, step)
+	}
+	return transformer, nil
+}
+
+// OutputType returns the type of the messages that the Transformer outputs.
+func (t *Transformer[M]) OutputType() protoreflect.MessageType {
+	return t.output
+}
+
+// Transform returns the output message for the given input message. The input message is
+// never modified.
+func (t *Transformer[M]) Transform(message M) (proto.Message, error) {
+	input := message.ProtoReflect()
+	var output protoreflect.Message
+	if t.output.Descriptor().FullName() == t.input.FullName() {
+		output = proto.Clone(message).ProtoReflect()
+	} else {
+		output = t.output.New()
+	}
+	for _, step := range t.steps {
+		if err := step(input, output); err != nil {
+			return nil, err
+		}
+	}
+	return output.Interface(), nil
+}
+
+func newStep(
+	env *cel.Env,
+	input protoreflect.MessageDescriptor,
+	output protoreflect.MessageDescriptor,
+	rule Rule,
+) (step, error) {
+	set := 0
+	for _, isSet := range []bool{rule.From != "", rule.CEL != "", rule.Mask != nil, rule.Drop} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("exactly one of from, cel, mask, and drop must be set")
+	}
+	field, err := resolvePath(output, rule.Field)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case rule.From != "":
+		return newCopyStep(input, rule.From, field)
+	case rule.CEL != "":
+		return newCELStep(env, rule.CEL, field)
+	case rule.Mask != nil:
+		return newMaskStep(field, *rule.Mask)
+	default:
+		return newDropStep(field), nil
+	}
+}